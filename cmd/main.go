@@ -2,18 +2,30 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"pg-summary-service/internal/logger"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 	"pg-summary-service/internal/config"
+	"pg-summary-service/internal/domain"
 	"pg-summary-service/internal/handler"
+	"pg-summary-service/internal/repository/alert"
+	"pg-summary-service/internal/repository/audit"
 	"pg-summary-service/internal/repository/external"
 	"pg-summary-service/internal/repository/local"
+	"pg-summary-service/internal/repository/webhook"
+	server2 "pg-summary-service/internal/server"
 	"pg-summary-service/internal/service"
 )
 
@@ -33,35 +45,190 @@ func main() {
 	}
 }
 
+// stores are the repositories the services are built on, close releases them on shutdown
+type stores struct {
+	local    local.Local
+	webhooks webhook.Store
+	alerts   alert.Store
+	audit    audit.Store
+	close    func()
+}
+
 func startServer() error {
 
-	// created db pool and Initialize tables
-	pool, err := initDB()
+	// Instantiate Repositories
+	st, err := initStores()
 	if err != nil {
 		return err
 	}
-	defer pool.Close()
+	defer st.close()
 
-	// Instantiate Repositories
-	extRepo := external.NewExternalRepository(config.GetExternalDbUrl(), config.GetRetries())
-	localRepo := local.NewLocalRepository(pool)
+	// summaries are immutable, so lookups by id are served from a cache in front of the store
+	var cache *local.CachedRepository
+	if cacheConf := config.GetCacheConfig(); cacheConf.Enabled {
+		cache = local.NewCachedRepository(st.local, cacheConf)
+		st.local = cache
+	}
+
+	// every provider has its own breaker, the router fails over between them
+	parser := external.NewParser(external.ParseMode(config.GetExternalParseMode()))
+	security, err := external.NewSecurity(config.GetExternalSecurity())
+	if err != nil {
+		return err
+	}
+	extRepo := external.NewRouter(config.GetExternalProviders(), config.GetRetries(),
+		external.WithParser(parser),
+		external.WithBreaker(config.GetExternalBreaker()),
+		external.WithSecurity(security),
+	)
 
 	// Service
-	svc := service.NewSummaryService(extRepo, localRepo)
+	maxAttempts, baseBackoff := config.GetWebhookRetry()
+	webhookSvc := service.NewWebhookService(st.webhooks, maxAttempts, baseBackoff)
+	defer webhookSvc.Close()
+	alertSvc := service.NewAlertService(st.alerts, st.local, webhookSvc)
+	events := service.NewEventStream(config.GetEventBufferSize())
+	auditLog := service.NewAuditLog(st.audit)
+	svc := service.NewSummaryService(extRepo, st.local,
+		service.WithEventSinks(webhookSvc, events),
+		service.WithAlertEvaluator(alertSvc),
+		service.WithAuditLog(auditLog),
+	)
 
-	// Register routes
-	handler.RegisterRoutes(http.HandleFunc, *svc)
+	// stop on SIGINT/SIGTERM so the deferred cleanups (snapshot, pools) run
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Retention janitor runs in the background for the lifetime of the server
+	janitor := service.NewJanitor(svc, config.GetRetentionPolicy())
+	go janitor.Start(ctx)
+
+	// Routes
+	services := handler.Services{Summary: svc, Webhooks: webhookSvc, Alerts: alertSvc, Janitor: janitor, Breakers: extRepo, Providers: extRepo,
+		Events: events, JobStreamTimeout: config.GetJobStreamTimeout(), Audit: auditLog, Legacy: config.GetLegacyRoutes(), TrustedProxies: config.GetTrustedProxies()}
+	if cache != nil {
+		services.Cache = cache
+	}
 
 	// Start server
 	port := fmt.Sprintf(":%s", config.GetPort())
-	logger.Log.Info("Server starting", zap.String("port", port))
+	// requests inherit ctx, so a shutdown also cancels in-flight syncs and their retries
+	server := &http.Server{Addr: port, Handler: handler.NewServer(services), BaseContext: func(net.Listener) context.Context { return ctx }}
+	tlsConf := config.GetServerTLS()
+	logger.Log.Info("Server starting", zap.String("port", port), zap.String("storage", config.GetStorage()), zap.Bool("tls", tlsConf.Enabled()))
 	fmt.Println("*************************************************| Starting server |*************************************************")
 
-	if err := http.ListenAndServe(port, nil); err != nil {
-		return fmt.Errorf("error starting server: %w", err)
+	servers := []*http.Server{server}
+	serveErr := make(chan error, 2)
+	if tlsConf.Enabled() {
+		if server.TLSConfig, err = initTLS(ctx, tlsConf); err != nil {
+			return err
+		}
+		go func() {
+			// the certificate comes from TLSConfig.GetCertificate, so no files here
+			serveErr <- server.ListenAndServeTLS("", "")
+		}()
+		if tlsConf.RedirectPort != "" {
+			redirect := &http.Server{Addr: ":" + tlsConf.RedirectPort, Handler: server2.RedirectHandler(config.GetPort())}
+			servers = append(servers, redirect)
+			go func() {
+				serveErr <- redirect.ListenAndServe()
+			}()
+		}
+	} else {
+		go func() {
+			serveErr <- server.ListenAndServe()
+		}()
+	}
+
+	// a server that fails to start takes the others down with it
+	var startErr error
+	select {
+	case err := <-serveErr:
+		startErr = fmt.Errorf("error starting server: %w", err)
+	case <-ctx.Done():
+	}
+
+	logger.Log.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// every server is shut down side by side within the same deadline, one failing does not keep the others up
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, s := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Shutdown(shutdownCtx); err != nil {
+				errs[i] = fmt.Errorf("error shutting down server %s: %w", s.Addr, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(append([]error{startErr}, errs...)...)
+}
+
+// initTLS loads the server certificate and reloads it on SIGHUP or when its files change, until ctx is done
+func initTLS(ctx context.Context, conf domain.ServerTLS) (*tls.Config, error) {
+	reloader, err := server2.NewCertReloader(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := server2.NewTLSConfig(conf, reloader)
+	if err != nil {
+		return nil, err
+	}
+	go reloader.Watch(ctx, conf.ReloadInterval)
+	return tlsConfig, nil
+}
+
+func initStores() (*stores, error) {
+	if config.GetStorage() == config.StorageMemory {
+		return initMemoryStores()
 	}
 
-	return nil
+	// created db pool and Initialize tables
+	pool, err := initDB()
+	if err != nil {
+		return nil, err
+	}
+	return &stores{
+		local:    local.NewLocalRepository(pool),
+		webhooks: webhook.NewWebhookRepository(pool),
+		alerts:   alert.NewAlertRepository(pool),
+		audit:    audit.NewAuditRepository(pool),
+		close:    pool.Close,
+	}, nil
+}
+
+// initMemoryStores keeps everything in memory, summaries are loaded from and saved to the snapshot file if one is set
+func initMemoryStores() (*stores, error) {
+	snapshotFile := config.GetMemorySnapshotFile()
+
+	localRepo := local.NewMemoryRepository()
+	if snapshotFile != "" {
+		var err error
+		if localRepo, err = local.LoadMemorySnapshot(snapshotFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return &stores{
+		local:    localRepo,
+		webhooks: webhook.NewMemoryRepository(),
+		alerts:   alert.NewMemoryRepository(),
+		audit:    audit.NewMemoryRepository(),
+		close: func() {
+			if snapshotFile == "" {
+				return
+			}
+			if err := localRepo.SaveSnapshot(snapshotFile); err != nil {
+				logger.Log.Error("failed to save memory snapshot", zap.Error(err))
+				return
+			}
+			logger.Log.Info("memory snapshot saved", zap.String("file", snapshotFile))
+		},
+	}, nil
 }
 
 func initDB() (*pgxpool.Pool, error) {
@@ -85,14 +252,14 @@ func initDB() (*pgxpool.Pool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS summaries (id VARCHAR PRIMARY KEY, source_info VARCHAR, synced_at TIMESTAMP)`,
-		`CREATE TABLE IF NOT EXISTS schemas (id VARCHAR PRIMARY KEY, summary_id VARCHAR REFERENCES summaries(id), name VARCHAR)`,
-		`CREATE TABLE IF NOT EXISTS tables (id VARCHAR PRIMARY KEY, schema_id VARCHAR REFERENCES schemas(id), name VARCHAR, row_count BIGINT, size_mb FLOAT)`,
-	}
-
-	for _, q := range queries {
-		if _, err := pool.Exec(ctx, q); err != nil {
+	// each repository creates the tables it owns
+	for _, createTables := range []func(context.Context, *pgxpool.Pool) error{
+		local.CreateTables,
+		webhook.CreateTables,
+		alert.CreateTables,
+		audit.CreateTables,
+	} {
+		if err := createTables(ctx, pool); err != nil {
 			return nil, fmt.Errorf("failed to init DB: %w", err)
 		}
 	}
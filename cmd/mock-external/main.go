@@ -0,0 +1,45 @@
+// mock-external serves a fake external summary API, point EXTERNAL_API_URL at it for local development:
+//
+//	go run ./cmd/mock-external -addr :3000 -error-rate 0.2 -latency 200ms
+//	EXTERNAL_API_URL=http://localhost:3000/api/summary go run ./cmd
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"pg-summary-service/pkg/mockexternal"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	var conf mockexternal.Config
+	addr := flag.String("addr", ":3000", "listen address")
+	path := flag.String("path", "/api/summary", "path of the summary endpoint")
+	statuses := flag.String("error-statuses", "500,502,503", "comma separated statuses a failed request gets")
+	flag.IntVar(&conf.MaxSchemas, "schemas", 3, "max schemas per database")
+	flag.IntVar(&conf.MaxTables, "tables", 8, "max tables per schema")
+	flag.DurationVar(&conf.Latency, "latency", 0, "latency added to every response")
+	flag.DurationVar(&conf.Jitter, "jitter", 0, "random extra latency, up to this much")
+	flag.Float64Var(&conf.ErrorRate, "error-rate", 0, "share of requests that fail, 0 to 1")
+	flag.DurationVar(&conf.RetryAfter, "retry-after", 0, "Retry-After sent with 429 and 503 responses")
+	flag.Uint64Var(&conf.Seed, "seed", 1, "seed of the error draws and the jitter")
+	flag.Parse()
+
+	for _, raw := range strings.Split(*statuses, ",") {
+		status, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			log.Fatalf("invalid -error-statuses %q: %v", *statuses, err)
+		}
+		conf.ErrorStatuses = append(conf.ErrorStatuses, status)
+	}
+	if conf.ErrorRate < 0 || conf.ErrorRate > 1 {
+		log.Fatalf("-error-rate must be between 0 and 1, got %v", conf.ErrorRate)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(*path, mockexternal.New(conf))
+	log.Printf("mock external summary API listening on %s%s", *addr, *path)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
@@ -0,0 +1,24 @@
+// pgsummary is the command line client of the summary service, run pgsummary help for the commands
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"pg-summary-service/internal/cli"
+	"pg-summary-service/internal/logger"
+	"syscall"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func main() {
+	// -in-process runs the service code, which logs; only errors, on stderr, so stdout stays parseable
+	logger.Log = zap.New(zapcore.NewCore(zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()), zapcore.Lock(os.Stderr), zap.ErrorLevel))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := cli.Run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"pg-summary-service/internal/config"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/repository/external"
+	"pg-summary-service/internal/repository/local"
+	"pg-summary-service/internal/service"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Backend is what the commands run against, the API of a server or a SummaryService in this process
+type Backend interface {
+	Sync(ctx context.Context, details domain.RemoteDBDetails) error
+	List(ctx context.Context, offset, limit int) ([]domain.LocalSummaryListItem, error)
+	Show(ctx context.Context, id string) (*domain.LocalSummaryByIdResp, error)
+	Tables(ctx context.Context, id string) ([]domain.SummaryTable, error)
+	Export(ctx context.Context, source string) ([]domain.SummaryTable, error)
+	Close() error
+}
+
+// apiVersion is the API version the client speaks
+const apiVersion = "/v1"
+
+// HTTPBackend calls the API of a running server, Token is sent as a bearer token when set
+type HTTPBackend struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func NewHTTPBackend(serverURL, token string) *HTTPBackend {
+	return &HTTPBackend{URL: strings.TrimRight(serverURL, "/"), Token: token, Client: &http.Client{Timeout: time.Minute}}
+}
+
+func (b *HTTPBackend) Sync(ctx context.Context, details domain.RemoteDBDetails) error {
+	body, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	return b.do(ctx, http.MethodPost, "/summary/sync", strings.NewReader(string(body)), nil)
+}
+
+func (b *HTTPBackend) List(ctx context.Context, offset, limit int) ([]domain.LocalSummaryListItem, error) {
+	var items []domain.LocalSummaryListItem
+	err := b.do(ctx, http.MethodGet, fmt.Sprintf("/summaries?offset=%d&limit=%d", offset, limit), nil, &items)
+	return items, err
+}
+
+func (b *HTTPBackend) Show(ctx context.Context, id string) (*domain.LocalSummaryByIdResp, error) {
+	var summary domain.LocalSummaryByIdResp
+	if err := b.do(ctx, http.MethodGet, "/summaries/"+url.PathEscape(id), nil, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+func (b *HTTPBackend) Tables(ctx context.Context, id string) ([]domain.SummaryTable, error) {
+	var tables []domain.SummaryTable
+	err := b.do(ctx, http.MethodGet, "/summaries/"+url.PathEscape(id)+"/tables?format=json", nil, &tables)
+	return tables, err
+}
+
+func (b *HTTPBackend) Export(ctx context.Context, source string) ([]domain.SummaryTable, error) {
+	var tables []domain.SummaryTable
+	err := b.do(ctx, http.MethodGet, "/exports/summaries?format=json&source="+url.QueryEscape(source), nil, &tables)
+	return tables, err
+}
+
+func (b *HTTPBackend) Close() error { return nil }
+
+// do sends a request and decodes a JSON answer into out, a status other than 2xx is returned as an AppError
+func (b *HTTPBackend) do(ctx context.Context, method, path string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, b.URL+apiVersion+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if b.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.Token)
+	}
+
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", b.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return &domain.AppError{Code: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from %s%s: %w", b.URL, path, err)
+	}
+	return nil
+}
+
+// ServiceBackend runs the commands on a SummaryService in this process
+type ServiceBackend struct {
+	svc   *service.SummaryService
+	close func() error
+}
+
+// NewServiceBackend wires a SummaryService from the same environment the server reads (STORAGE, LOCAL_DB_URL,
+// EXTERNAL_API_URL, ...). With STORAGE=memory and MEMORY_SNAPSHOT_FILE the summaries outlive the run.
+func NewServiceBackend(ctx context.Context) (*ServiceBackend, error) {
+	if err := config.LoadConfig(); err != nil {
+		return nil, err
+	}
+
+	var repo local.Local
+	var closeRepo func() error
+	if config.GetStorage() == config.StorageMemory {
+		memRepo, snapshotFile := local.NewMemoryRepository(), config.GetMemorySnapshotFile()
+		if snapshotFile != "" {
+			var err error
+			if memRepo, err = local.LoadMemorySnapshot(snapshotFile); err != nil {
+				return nil, err
+			}
+		}
+		repo = memRepo
+		closeRepo = func() error {
+			if snapshotFile == "" {
+				return nil
+			}
+			return memRepo.SaveSnapshot(snapshotFile)
+		}
+	} else {
+		pool, err := pgxpool.New(ctx, config.GetLocalDbUrl())
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to local DB: %w", err)
+		}
+		if err = local.CreateTables(ctx, pool); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("failed to init DB: %w", err)
+		}
+		repo = local.NewLocalRepository(pool)
+		closeRepo = func() error {
+			pool.Close()
+			return nil
+		}
+	}
+
+	security, err := external.NewSecurity(config.GetExternalSecurity())
+	if err != nil {
+		_ = closeRepo()
+		return nil, err
+	}
+	extRepo := external.NewRouter(config.GetExternalProviders(), config.GetRetries(),
+		external.WithParser(external.NewParser(external.ParseMode(config.GetExternalParseMode()))),
+		external.WithSecurity(security),
+	)
+	return &ServiceBackend{svc: service.NewSummaryService(extRepo, repo), close: closeRepo}, nil
+}
+
+func (b *ServiceBackend) Sync(ctx context.Context, details domain.RemoteDBDetails) error {
+	_, err := b.svc.SyncSummary(ctx, details)
+	return err
+}
+
+func (b *ServiceBackend) List(ctx context.Context, offset, limit int) ([]domain.LocalSummaryListItem, error) {
+	return b.svc.GetSummaries(ctx, offset, limit)
+}
+
+func (b *ServiceBackend) Show(ctx context.Context, id string) (*domain.LocalSummaryByIdResp, error) {
+	return b.svc.GetSummaryByID(ctx, id)
+}
+
+func (b *ServiceBackend) Tables(ctx context.Context, id string) ([]domain.SummaryTable, error) {
+	return b.svc.GetSummaryTables(ctx, id)
+}
+
+func (b *ServiceBackend) Export(ctx context.Context, source string) ([]domain.SummaryTable, error) {
+	var tables []domain.SummaryTable
+	err := b.svc.ExportSourceTables(ctx, source, func(table domain.SummaryTable) error {
+		tables = append(tables, table)
+		return nil
+	})
+	return tables, err
+}
+
+// Close releases the store, with MEMORY_SNAPSHOT_FILE it fails when the summaries could not be saved
+func (b *ServiceBackend) Close() error {
+	return b.close()
+}
@@ -0,0 +1,381 @@
+// Package cli is the pgsummary command line client, it runs against the API of a server or, with -in-process,
+// a SummaryService wired from the server's environment.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/utils"
+	"strconv"
+	"time"
+)
+
+const usage = `usage: pgsummary <command> [flags] [args]
+
+commands:
+  sync      sync a database: -host -port -user -password -dbname (or PG* env, ~/.pgpass)
+  list      list summaries: -offset -limit
+  show      show the schemas of a summary: show <id>
+  tables    list the tables of a summary: tables <id>
+  diff      compare the tables of two summaries: diff <before-id> <after-id> [-changed]
+  export    list the tables of every summary of a source: export <host:dbname>
+  watch     print summaries as they are stored: -interval [-sync with the sync flags] [-count]
+
+common flags:
+  -server      server url (PGSUMMARY_SERVER, default http://localhost:8080)
+  -token       bearer token (PGSUMMARY_TOKEN)
+  -in-process  run on a SummaryService in this process, configured like the server
+  -o           output: table, json or csv
+`
+
+// command is what one subcommand needs once its flags are parsed
+type command struct {
+	ctx     context.Context
+	backend Backend
+	args    []string
+	out     io.Writer
+	output  string
+}
+
+type commandSpec struct {
+	flags func(fs *flag.FlagSet) // registers the flags of the command, optional
+	run   func(c *command) error
+}
+
+// Run runs one pgsummary command and returns the exit code
+func Run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var details domain.RemoteDBDetails
+	var offset, limit, count int
+	var interval time.Duration
+	var changedOnly, syncFirst bool
+	dbFlags := func(fs *flag.FlagSet) {
+		fs.StringVar(&details.Host, "host", "", "database host")
+		fs.IntVar(&details.Port, "port", 0, "database port")
+		fs.StringVar(&details.User, "user", "", "database user")
+		fs.StringVar(&details.Password, "password", "", "database password")
+		fs.StringVar(&details.DBName, "dbname", "", "database name")
+	}
+
+	commands := map[string]commandSpec{
+		"sync": {flags: dbFlags, run: func(c *command) error {
+			return runSync(c, credentials(details))
+		}},
+		"list": {
+			flags: func(fs *flag.FlagSet) {
+				fs.IntVar(&offset, "offset", 0, "summaries to skip")
+				fs.IntVar(&limit, "limit", 20, "summaries to list")
+			},
+			run: func(c *command) error { return runList(c, offset, limit) },
+		},
+		"show":   {run: runShow},
+		"tables": {run: runTables},
+		"diff": {
+			flags: func(fs *flag.FlagSet) {
+				fs.BoolVar(&changedOnly, "changed", false, "only list added, removed and changed tables")
+			},
+			run: func(c *command) error { return runDiff(c, changedOnly) },
+		},
+		"export": {run: runExport},
+		"watch": {
+			flags: func(fs *flag.FlagSet) {
+				dbFlags(fs)
+				fs.DurationVar(&interval, "interval", 30*time.Second, "how often to poll")
+				fs.BoolVar(&syncFirst, "sync", false, "sync the database before every poll")
+				fs.IntVar(&count, "count", 0, "polls before exiting, 0 runs until interrupted")
+			},
+			run: func(c *command) error {
+				var target *domain.RemoteDBDetails
+				if syncFirst {
+					creds := credentials(details)
+					target = &creds
+				}
+				return runWatch(c, interval, count, target)
+			},
+		},
+	}
+
+	spec, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("pgsummary "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	server := fs.String("server", envOr("PGSUMMARY_SERVER", "http://localhost:8080"), "server url")
+	token := fs.String("token", os.Getenv("PGSUMMARY_TOKEN"), "bearer token")
+	inProcess := fs.Bool("in-process", false, "run on a SummaryService in this process")
+	output := fs.String("o", outputTable, "output: table, json or csv")
+	if spec.flags != nil {
+		spec.flags(fs)
+	}
+	positional, err := parseInterspersed(fs, args[1:])
+	if err != nil {
+		return 2
+	}
+
+	var backend Backend
+	if *inProcess {
+		if backend, err = NewServiceBackend(ctx); err != nil {
+			fmt.Fprintln(stderr, "error:", err)
+			return 1
+		}
+	} else {
+		backend = NewHTTPBackend(*server, *token)
+	}
+
+	code := 0
+	if err = spec.run(&command{ctx: ctx, backend: backend, args: positional, out: stdout, output: *output}); err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		code = 1
+		var usageErr *usageError
+		if errors.As(err, &usageErr) {
+			code = 2
+		}
+	}
+	// e.g. the memory snapshot could not be saved, what the command stored is lost
+	if err = backend.Close(); err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		code = max(code, 1)
+	}
+	return code
+}
+
+// parseInterspersed parses flags before and after the positional args, e.g. show <id> -o json
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func envOr(key, defaultVal string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return defaultVal
+}
+
+// usageError is returned for missing or extra arguments, it exits with 2
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func (c *command) arg(n int, names ...string) error {
+	if len(c.args) != n {
+		return &usageError{msg: fmt.Sprintf("expected %d argument(s) %v, got %d", n, names, len(c.args))}
+	}
+	return nil
+}
+
+func runSync(c *command, details domain.RemoteDBDetails) error {
+	if err := utils.ValidateDBDetails(details); err != nil {
+		return err
+	}
+	if err := c.backend.Sync(c.ctx, details); err != nil {
+		return err
+	}
+
+	source := fmt.Sprintf("%s:%s", details.Host, details.DBName)
+	p, err := newPrinter(c.out, c.output, "status", "source")
+	if err != nil {
+		return err
+	}
+	return p.print([][]string{{"synced", source}}, map[string]string{"status": "synced", "source": source})
+}
+
+func runList(c *command, offset, limit int) error {
+	if offset < 0 || limit < 1 {
+		return &usageError{msg: "offset cannot be negative and limit must be at least 1"}
+	}
+	items, err := c.backend.List(c.ctx, offset, limit)
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(c.out, c.output, "id", "db_name", "synced_at")
+	if err != nil {
+		return err
+	}
+	return printSummaryRows(p, items)
+}
+
+func printSummaryRows(p *printer, items []domain.LocalSummaryListItem) error {
+	rows := make([][]string, 0, len(items))
+	values := make([]any, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, []string{item.ID, item.DBName, item.SyncedAt.Format(time.RFC3339)})
+		values = append(values, item)
+	}
+	return p.print(rows, values)
+}
+
+func runShow(c *command) error {
+	if err := c.arg(1, "id"); err != nil {
+		return err
+	}
+	summary, err := c.backend.Show(c.ctx, c.args[0])
+	if err != nil {
+		return err
+	}
+
+	p, err := newPrinter(c.out, c.output, "schema", "tables", "total_rows", "total_size_mb")
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for _, s := range summary.Schemas {
+		rows = append(rows, []string{s.Name, strconv.Itoa(s.TableCount), strconv.FormatInt(s.TotalRows, 10), formatFloat(s.TotalSizeMB)})
+	}
+	return p.print(rows, summary)
+}
+
+func runTables(c *command) error {
+	if err := c.arg(1, "id"); err != nil {
+		return err
+	}
+	tables, err := c.backend.Tables(c.ctx, c.args[0])
+	if err != nil {
+		return err
+	}
+	return printTables(c, tables, false)
+}
+
+func runExport(c *command) error {
+	if err := c.arg(1, "source"); err != nil {
+		return err
+	}
+	tables, err := c.backend.Export(c.ctx, c.args[0])
+	if err != nil {
+		return err
+	}
+	return printTables(c, tables, true)
+}
+
+func printTables(c *command, tables []domain.SummaryTable, withSummary bool) error {
+	header := []string{"schema", "name", "row_count", "size_mb"}
+	if withSummary {
+		header = append([]string{"summary_id", "synced_at"}, header...)
+	}
+	p, err := newPrinter(c.out, c.output, header...)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(tables))
+	for _, t := range tables {
+		row := []string{t.Schema, t.Name, strconv.FormatInt(t.TotalRows, 10), formatFloat(t.SizeMB)}
+		if withSummary {
+			row = append([]string{t.SummaryId, t.SyncedAt.Format(time.RFC3339)}, row...)
+		}
+		rows = append(rows, row)
+	}
+	if tables == nil {
+		tables = []domain.SummaryTable{}
+	}
+	return p.print(rows, tables)
+}
+
+func runDiff(c *command, changedOnly bool) error {
+	if err := c.arg(2, "before-id", "after-id"); err != nil {
+		return err
+	}
+	before, err := c.backend.Tables(c.ctx, c.args[0])
+	if err != nil {
+		return err
+	}
+	after, err := c.backend.Tables(c.ctx, c.args[1])
+	if err != nil {
+		return err
+	}
+
+	p, err := newPrinter(c.out, c.output, "schema", "name", "status", "rows_before", "rows_after", "rows_delta", "size_mb_delta")
+	if err != nil {
+		return err
+	}
+	diffs := []TableDiff{}
+	var rows [][]string
+	for _, d := range DiffTables(before, after) {
+		if changedOnly && d.Status == DiffUnchanged {
+			continue
+		}
+		diffs = append(diffs, d)
+		rows = append(rows, []string{d.Schema, d.Name, string(d.Status), strconv.FormatInt(d.RowsBefore, 10),
+			strconv.FormatInt(d.RowsAfter, 10), fmt.Sprintf("%+d", d.RowsDelta), fmt.Sprintf("%+.2f", d.SizeDelta)})
+	}
+	return p.print(rows, diffs)
+}
+
+// watchPage is how many of the newest summaries each poll looks at
+const watchPage = 100
+
+// runWatch prints every summary stored after it started, target is synced before every poll when set
+func runWatch(c *command, interval time.Duration, count int, target *domain.RemoteDBDetails) error {
+	if target != nil {
+		if err := utils.ValidateDBDetails(*target); err != nil {
+			return err
+		}
+	}
+	p, err := newPrinter(c.out, c.output, "id", "db_name", "synced_at")
+	if err != nil {
+		return err
+	}
+	p.stream = true
+
+	seen := map[string]bool{}
+	items, err := c.backend.List(c.ctx, 0, watchPage)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		seen[item.ID] = true
+	}
+
+	for polls := 0; count == 0 || polls < count; polls++ {
+		if err = utils.Sleep(c.ctx, interval); err != nil {
+			return nil // interrupted
+		}
+		if target != nil {
+			if err = c.backend.Sync(c.ctx, *target); err != nil {
+				return err
+			}
+		}
+		if items, err = c.backend.List(c.ctx, 0, watchPage); err != nil {
+			return err
+		}
+
+		var fresh []domain.LocalSummaryListItem
+		for i := len(items) - 1; i >= 0; i-- { // oldest first
+			if !seen[items[i].ID] {
+				seen[items[i].ID] = true
+				fresh = append(fresh, items[i])
+			}
+		}
+		if len(fresh) > 0 {
+			if err = printSummaryRows(p, fresh); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"pg-summary-service/internal/domain"
+	"sort"
+)
+
+type DiffStatus string
+
+const (
+	DiffAdded     DiffStatus = "added"
+	DiffRemoved   DiffStatus = "removed"
+	DiffChanged   DiffStatus = "changed"
+	DiffUnchanged DiffStatus = "unchanged"
+)
+
+// TableDiff compares one table between two summaries
+type TableDiff struct {
+	Schema     string     `json:"schema"`
+	Name       string     `json:"name"`
+	Status     DiffStatus `json:"status"`
+	RowsBefore int64      `json:"rows_before"`
+	RowsAfter  int64      `json:"rows_after"`
+	RowsDelta  int64      `json:"rows_delta"`
+	SizeBefore float64    `json:"size_mb_before"`
+	SizeAfter  float64    `json:"size_mb_after"`
+	SizeDelta  float64    `json:"size_mb_delta"`
+}
+
+// DiffTables compares the tables of two summaries by schema and name, sorted the same way
+func DiffTables(before, after []domain.SummaryTable) []TableDiff {
+	type key struct{ schema, name string }
+	diffs := map[key]*TableDiff{}
+
+	for _, t := range before {
+		diffs[key{t.Schema, t.Name}] = &TableDiff{Schema: t.Schema, Name: t.Name, Status: DiffRemoved, RowsBefore: t.TotalRows, SizeBefore: t.SizeMB}
+	}
+	for _, t := range after {
+		d, ok := diffs[key{t.Schema, t.Name}]
+		if !ok {
+			d = &TableDiff{Schema: t.Schema, Name: t.Name, Status: DiffAdded}
+			diffs[key{t.Schema, t.Name}] = d
+		} else if d.RowsBefore == t.TotalRows && d.SizeBefore == t.SizeMB {
+			d.Status = DiffUnchanged
+		} else {
+			d.Status = DiffChanged
+		}
+		d.RowsAfter, d.SizeAfter = t.TotalRows, t.SizeMB
+	}
+
+	result := make([]TableDiff, 0, len(diffs))
+	for _, d := range diffs {
+		d.RowsDelta = d.RowsAfter - d.RowsBefore
+		d.SizeDelta = d.SizeAfter - d.SizeBefore
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Schema != result[j].Schema {
+			return result[i].Schema < result[j].Schema
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
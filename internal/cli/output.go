@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputCSV   = "csv"
+)
+
+// printer writes rows as an aligned table, CSV or JSON. The header goes out once, so a command that prints
+// several batches (watch) reads as one table; JSON prints the values of a batch as an array, or one per line in stream mode.
+type printer struct {
+	w       io.Writer
+	format  string
+	header  []string
+	stream  bool
+	started bool
+}
+
+func newPrinter(w io.Writer, format string, header ...string) (*printer, error) {
+	switch format {
+	case outputTable, outputJSON, outputCSV:
+	default:
+		return nil, fmt.Errorf("unknown output %q, expected table, json or csv", format)
+	}
+	return &printer{w: w, format: format, header: header}, nil
+}
+
+// print writes one batch, rows are the table and CSV cells and values what JSON encodes
+func (p *printer) print(rows [][]string, values any) error {
+	defer func() { p.started = true }()
+
+	switch p.format {
+	case outputJSON:
+		enc := json.NewEncoder(p.w)
+		if !p.stream {
+			enc.SetIndent("", "  ")
+			return enc.Encode(values)
+		}
+		items, ok := values.([]any)
+		if !ok {
+			return enc.Encode(values)
+		}
+		for _, item := range items {
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case outputCSV:
+		cw := csv.NewWriter(p.w)
+		if !p.started {
+			_ = cw.Write(p.header)
+		}
+		_ = cw.WriteAll(rows)
+		return cw.Error()
+	default:
+		tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+		if !p.started {
+			fmt.Fprintln(tw, strings.ToUpper(strings.Join(p.header, "\t")))
+		}
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+func formatFloat(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
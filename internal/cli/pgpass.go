@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"pg-summary-service/internal/domain"
+	"strconv"
+	"strings"
+)
+
+// credentials fills the unset fields of details from the libpq environment (PGHOST, PGPORT, PGUSER, PGPASSWORD,
+// PGDATABASE) and then the password from the pgpass file, flags win over both
+func credentials(details domain.RemoteDBDetails) domain.RemoteDBDetails {
+	if details.Host == "" {
+		details.Host = os.Getenv("PGHOST")
+	}
+	if details.Port == 0 {
+		details.Port, _ = strconv.Atoi(os.Getenv("PGPORT"))
+	}
+	if details.Port == 0 {
+		details.Port = 5432
+	}
+	if details.User == "" {
+		details.User = os.Getenv("PGUSER")
+	}
+	if details.DBName == "" {
+		details.DBName = os.Getenv("PGDATABASE")
+	}
+	if details.Password == "" {
+		details.Password = os.Getenv("PGPASSWORD")
+	}
+	if details.Password == "" {
+		details.Password = PgpassLookup(pgpassFile(), details)
+	}
+	return details
+}
+
+// pgpassFile is PGPASSFILE or ~/.pgpass
+func pgpassFile() string {
+	if file := os.Getenv("PGPASSFILE"); file != "" {
+		return file
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pgpass")
+}
+
+// PgpassLookup returns the password of the first hostname:port:database:username:password line of file matching
+// details, * matches anything and \ escapes : and \
+func PgpassLookup(file string, details domain.RemoteDBDetails) string {
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	want := []string{details.Host, strconv.Itoa(details.Port), details.DBName, details.User}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fields := splitPgpassLine(line)
+		if len(fields) != 5 {
+			continue
+		}
+		matched := true
+		for i, value := range want {
+			if fields[i] != "*" && fields[i] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return fields[4]
+		}
+	}
+	return ""
+}
+
+func splitPgpassLine(line string) []string {
+	var fields []string
+	var field strings.Builder
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			field.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == ':' && len(fields) < 4:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(c)
+		}
+	}
+	return append(fields, field.String())
+}
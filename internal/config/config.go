@@ -1,23 +1,54 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
+	"path"
 	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/metrics"
+	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
 type config struct {
+	env                   string
 	port                  string
+	storage               string
+	memorySnapshotFile    string
 	localDbUrl            string
 	maxConnections        int
 	maxIdleConnections    int
 	maxConnectionLifeTime time.Duration
-	externalDbUrl         string
+	externalProviders     []domain.ExternalProvider
 	retries               int
 	isDebug               bool
 	logDir                string
 	logFile               string
+	metricsLimits         metrics.Limits
+	webhookMaxAttempts    int
+	webhookBaseBackoff    time.Duration
+	retention             domain.RetentionPolicy
+	cache                 domain.CacheConfig
+	externalParseMode     string
+	externalBreaker       domain.BreakerConfig
+	externalSecurity      domain.OutboundSecurity
+	serverTLS             domain.ServerTLS
+	legacyRoutes          domain.LegacyRoutes
+	eventBufferSize       int
+	jobStreamTimeout      time.Duration
+	trustedProxies        []netip.Prefix
 }
 
 var conf config
@@ -26,33 +57,226 @@ var conf config
 // note: we can load these less sensitive values from config and sensitive values from os env, for now just hardcoded
 // note: config is initialized once at startup; getters are safe for concurrent use
 func LoadConfig() error {
+	storage := getEnv("STORAGE", StoragePostgres)
+	if storage != StoragePostgres && storage != StorageMemory {
+		return fmt.Errorf("unknown STORAGE %q, expected %s or %s", storage, StoragePostgres, StorageMemory)
+	}
+
+	// the in-memory store needs no database
 	localDb := os.Getenv("LOCAL_DB_URL")
-	if localDb == "" {
+	if localDb == "" && storage == StoragePostgres {
 		fmt.Println("No external db url set, using default")
 		return fmt.Errorf("no external db url set, using default")
 	}
 
-	externalDb := os.Getenv("EXTERNAL_API_URL")
-	if externalDb == "" {
-		fmt.Println("No external api url set, using default")
-		//externalDb = "http://localhost:3000/api/summary" //"http://external-service.local/api/summary" // fallback default
-		return fmt.Errorf("no external db url set, using default")
+	// EXTERNAL_PROVIDERS lists several external APIs, otherwise EXTERNAL_API_URL is the only one
+	providers, err := getExternalProviders()
+	if err != nil {
+		return err
+	}
+
+	env := getEnv("APP_ENV", EnvDevelopment)
+	if env != EnvDevelopment && env != EnvProduction {
+		return fmt.Errorf("unknown APP_ENV %q, expected %s or %s", env, EnvDevelopment, EnvProduction)
+	}
+
+	// database credentials go to the external API, so production only talks to it over https by default
+	security := domain.OutboundSecurity{
+		CAFile:      os.Getenv("EXTERNAL_CA_FILE"),
+		CertFile:    os.Getenv("EXTERNAL_CLIENT_CERT"),
+		KeyFile:     os.Getenv("EXTERNAL_CLIENT_KEY"),
+		BearerToken: os.Getenv("EXTERNAL_BEARER_TOKEN"),
+		HMACSecret:  os.Getenv("EXTERNAL_HMAC_SECRET"),
+	}
+	if (security.CertFile == "") != (security.KeyFile == "") {
+		return fmt.Errorf("EXTERNAL_CLIENT_CERT and EXTERNAL_CLIENT_KEY must be set together")
+	}
+	if security.RequireHTTPS, err = getEnvBool("EXTERNAL_REQUIRE_HTTPS", env == EnvProduction); err != nil {
+		return err
+	}
+	if security.RequireHTTPS {
+		for _, p := range providers {
+			if u, err := url.Parse(p.URL); err != nil || !strings.EqualFold(u.Scheme, "https") {
+				return fmt.Errorf("external provider %s is not https, set EXTERNAL_REQUIRE_HTTPS=false to allow it", p.Name)
+			}
+		}
 	}
 
 	port := getEnv("PORT", "8080") // default is fine
 
+	// with a cert and key PORT serves https, HTTP_REDIRECT_PORT can send plain http clients there
+	serverTLS := domain.ServerTLS{
+		CertFile:     os.Getenv("TLS_CERT_FILE"),
+		KeyFile:      os.Getenv("TLS_KEY_FILE"),
+		ClientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+		ClientAuth:   getEnv("TLS_CLIENT_AUTH", "require"),
+		MinVersion:   getEnv("TLS_MIN_VERSION", "1.2"),
+		RedirectPort: os.Getenv("HTTP_REDIRECT_PORT"),
+	}
+	if (serverTLS.CertFile == "") != (serverTLS.KeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if !serverTLS.Enabled() && (serverTLS.ClientCAFile != "" || serverTLS.RedirectPort != "") {
+		return fmt.Errorf("TLS_CLIENT_CA_FILE and HTTP_REDIRECT_PORT need TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	if serverTLS.ClientAuth != "optional" && serverTLS.ClientAuth != "require" {
+		return fmt.Errorf("unknown TLS_CLIENT_AUTH %q, expected optional or require", serverTLS.ClientAuth)
+	}
+	if serverTLS.MinVersion != "1.2" && serverTLS.MinVersion != "1.3" {
+		return fmt.Errorf("unknown TLS_MIN_VERSION %q, expected 1.2 or 1.3", serverTLS.MinVersion)
+	}
+	if ciphers := os.Getenv("TLS_CIPHER_SUITES"); ciphers != "" {
+		serverTLS.CipherSuites = strings.Split(ciphers, ",")
+	}
+	if serverTLS.ReloadInterval, err = getEnvDuration("TLS_RELOAD_INTERVAL", 30*time.Second); err != nil {
+		return err
+	}
+
+	maxTablesPerSource, err := getEnvInt("METRICS_MAX_TABLES_PER_SOURCE", 1000)
+	if err != nil {
+		return err
+	}
+	maxTableSeries, err := getEnvInt("METRICS_MAX_TABLE_SERIES", 10000)
+	if err != nil {
+		return err
+	}
+
+	webhookMaxAttempts, err := getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5)
+	if err != nil {
+		return err
+	}
+	webhookBaseBackoff, err := getEnvDuration("WEBHOOK_BASE_BACKOFF", 2*time.Second)
+	if err != nil {
+		return err
+	}
+
+	// RETENTION_MAX_AGE never prunes the newest summary of a source
+	var retention domain.RetentionPolicy
+	if retention.MaxAge, err = getEnvDuration("RETENTION_MAX_AGE", 0); err != nil {
+		return err
+	}
+	if retention.KeepLast, err = getEnvInt("RETENTION_KEEP_LAST", 0); err != nil {
+		return err
+	}
+	if retention.DailyAfter, err = getEnvDuration("RETENTION_DAILY_AFTER", 0); err != nil {
+		return err
+	}
+	if retention.BatchSize, err = getEnvInt("RETENTION_BATCH_SIZE", 100); err != nil {
+		return err
+	}
+	if retention.Interval, err = getEnvDuration("RETENTION_INTERVAL", time.Hour); err != nil {
+		return err
+	}
+
+	var cache domain.CacheConfig
+	if cache.Enabled, err = getEnvBool("CACHE_ENABLED", true); err != nil {
+		return err
+	}
+	if cache.TTL, err = getEnvDuration("CACHE_TTL", 10*time.Minute); err != nil {
+		return err
+	}
+	if cache.MaxEntries, err = getEnvInt("CACHE_MAX_ENTRIES", 1000); err != nil {
+		return err
+	}
+	maxBytes, err := getEnvInt("CACHE_MAX_BYTES", 0)
+	if err != nil {
+		return err
+	}
+	cache.MaxBytes = int64(maxBytes)
+	if cache.Enabled && cache.MaxEntries <= 0 && cache.MaxBytes <= 0 {
+		return fmt.Errorf("cache needs CACHE_MAX_ENTRIES or CACHE_MAX_BYTES, or CACHE_ENABLED=false")
+	}
+
+	// strict rejects external payloads with any contract violation, lenient logs them
+	parseMode := getEnv("EXTERNAL_PARSE_MODE", "lenient")
+	if parseMode != "strict" && parseMode != "lenient" {
+		return fmt.Errorf("unknown EXTERNAL_PARSE_MODE %q, expected strict or lenient", parseMode)
+	}
+
+	var breaker domain.BreakerConfig
+	if breaker.FailureThreshold, err = getEnvInt("EXTERNAL_BREAKER_FAILURES", 5); err != nil {
+		return err
+	}
+	if breaker.CoolDown, err = getEnvDuration("EXTERNAL_BREAKER_COOLDOWN", 30*time.Second); err != nil {
+		return err
+	}
+	if breaker.HalfOpenCalls, err = getEnvInt("EXTERNAL_BREAKER_HALF_OPEN_CALLS", 1); err != nil {
+		return err
+	}
+
+	// the unversioned paths stay as aliases of /v1 and are always deprecated, the dates are announced once an
+	// operator decides them
+	var legacy domain.LegacyRoutes
+	if legacy.Enabled, err = getEnvBool("LEGACY_ROUTES", true); err != nil {
+		return err
+	}
+	if legacy.Deprecated, err = getEnvDate("LEGACY_ROUTES_DEPRECATED", ""); err != nil {
+		return err
+	}
+	if legacy.Sunset, err = getEnvDate("LEGACY_ROUTES_SUNSET", ""); err != nil {
+		return err
+	}
+	if !legacy.Sunset.IsZero() && legacy.Deprecated.IsZero() {
+		return fmt.Errorf("LEGACY_ROUTES_SUNSET needs LEGACY_ROUTES_DEPRECATED")
+	}
+	if !legacy.Sunset.IsZero() && legacy.Sunset.Before(legacy.Deprecated) {
+		return fmt.Errorf("LEGACY_ROUTES_SUNSET must not be before LEGACY_ROUTES_DEPRECATED")
+	}
+
+	// how many events GET /events keeps for clients resuming with Last-Event-ID
+	eventBufferSize, err := getEnvInt("EVENTS_BUFFER_SIZE", 1000)
+	if err != nil {
+		return err
+	}
+	if eventBufferSize < 1 {
+		return fmt.Errorf("EVENTS_BUFFER_SIZE must be at least 1")
+	}
+	// a job stream whose sync never ends, e.g. the server restarted, is closed after this long
+	jobStreamTimeout, err := getEnvDuration("JOB_STREAM_TIMEOUT", 30*time.Minute)
+	if err != nil {
+		return err
+	}
+	if jobStreamTimeout <= 0 {
+		return fmt.Errorf("JOB_STREAM_TIMEOUT must be positive")
+	}
+
+	// the audit log only takes the caller's name from X-Actor when a proxy listed here sent it
+	trustedProxies, err := getEnvPrefixes("TRUSTED_PROXIES")
+	if err != nil {
+		return err
+	}
+
 	// Assign to package-level conf
 	conf = config{
+		env:                   env,
 		port:                  port,
+		storage:               storage,
+		memorySnapshotFile:    os.Getenv("MEMORY_SNAPSHOT_FILE"),
 		localDbUrl:            localDb,
 		maxConnections:        5,
 		maxIdleConnections:    2,
 		maxConnectionLifeTime: 5 * time.Minute,
-		externalDbUrl:         externalDb,
+		externalProviders:     providers,
 		retries:               3,
 		isDebug:               false,
 		logDir:                "./logs",
 		logFile:               "server.log",
+		metricsLimits: metrics.Limits{
+			MaxTablesPerSource: maxTablesPerSource,
+			MaxTableSeries:     maxTableSeries,
+		},
+		webhookMaxAttempts: webhookMaxAttempts,
+		webhookBaseBackoff: webhookBaseBackoff,
+		retention:          retention,
+		cache:              cache,
+		externalParseMode:  parseMode,
+		externalBreaker:    breaker,
+		externalSecurity:   security,
+		serverTLS:          serverTLS,
+		legacyRoutes:       legacy,
+		eventBufferSize:    eventBufferSize,
+		jobStreamTimeout:   jobStreamTimeout,
+		trustedProxies:     trustedProxies,
 	}
 
 	return nil
@@ -62,6 +286,16 @@ func GetLocalDbUrl() string {
 	return conf.localDbUrl
 }
 
+// GetStorage returns StoragePostgres or StorageMemory
+func GetStorage() string {
+	return conf.storage
+}
+
+// GetMemorySnapshotFile returns where the in-memory store is loaded from and saved to, empty means not persisted
+func GetMemorySnapshotFile() string {
+	return conf.memorySnapshotFile
+}
+
 func GetLogDir() string {
 	return conf.logDir
 }
@@ -72,8 +306,9 @@ func Debug() bool {
 	return conf.isDebug
 }
 
-func GetExternalDbUrl() string {
-	return conf.externalDbUrl
+// GetExternalProviders returns the external summary APIs, there is at least one
+func GetExternalProviders() []domain.ExternalProvider {
+	return conf.externalProviders
 }
 
 func GetDBStats() domain.LocalDBStats {
@@ -92,9 +327,182 @@ func GetRetries() int {
 	return conf.retries
 }
 
+// GetExternalBreaker returns the circuit breaker settings of the external API, FailureThreshold 0 is off
+func GetExternalBreaker() domain.BreakerConfig {
+	return conf.externalBreaker
+}
+
+// GetExternalSecurity returns the CA bundle, client certificate and auth headers of the external API calls
+func GetExternalSecurity() domain.OutboundSecurity {
+	return conf.externalSecurity
+}
+
+// GetServerTLS returns how the service serves https, see domain.ServerTLS.Enabled
+func GetServerTLS() domain.ServerTLS {
+	return conf.serverTLS
+}
+
+// GetEventBufferSize returns how many of the latest events the event stream keeps for replay
+func GetEventBufferSize() int {
+	return conf.eventBufferSize
+}
+
+// GetJobStreamTimeout returns how long a job stream waits for the final event of its sync
+func GetJobStreamTimeout() time.Duration {
+	return conf.jobStreamTimeout
+}
+
+// GetTrustedProxies returns the networks whose X-Actor header names the caller
+func GetTrustedProxies() []netip.Prefix {
+	return conf.trustedProxies
+}
+
+// GetLegacyRoutes returns whether the unversioned paths are served and the dates their deprecation headers announce
+func GetLegacyRoutes() domain.LegacyRoutes {
+	return conf.legacyRoutes
+}
+
+// GetEnv returns EnvDevelopment or EnvProduction
+func GetEnv() string {
+	return conf.env
+}
+
+// GetExternalParseMode returns "strict" or "lenient"
+func GetExternalParseMode() string {
+	return conf.externalParseMode
+}
+
+// GetSummaryMetricsLimits returns the cardinality limits of /metrics/summaries
+func GetSummaryMetricsLimits() metrics.Limits {
+	return conf.metricsLimits
+}
+
+// GetWebhookRetry returns how often and how fast a failed webhook delivery is retried
+func GetWebhookRetry() (maxAttempts int, baseBackoff time.Duration) {
+	return conf.webhookMaxAttempts, conf.webhookBaseBackoff
+}
+
+func GetRetentionPolicy() domain.RetentionPolicy {
+	return conf.retention
+}
+
+// GetCacheConfig returns how the local repository cache is sized, Enabled false means no cache
+func GetCacheConfig() domain.CacheConfig {
+	return conf.cache
+}
+
+// getExternalProviders reads the JSON list of EXTERNAL_PROVIDERS, e.g.
+// [{"name":"eu","url":"https://eu.example.com/api/summary","priority":1,"region":"eu-west-1","hosts":["*.eu.example.com"]}]
+func getExternalProviders() ([]domain.ExternalProvider, error) {
+	raw := os.Getenv("EXTERNAL_PROVIDERS")
+	if raw == "" {
+		externalDb := os.Getenv("EXTERNAL_API_URL")
+		if externalDb == "" {
+			fmt.Println("No external api url set, using default")
+			//externalDb = "http://localhost:3000/api/summary" //"http://external-service.local/api/summary" // fallback default
+			return nil, fmt.Errorf("no external db url set, using default")
+		}
+		return []domain.ExternalProvider{{Name: "default", URL: externalDb}}, nil
+	}
+
+	var providers []domain.ExternalProvider
+	if err := json.Unmarshal([]byte(raw), &providers); err != nil {
+		return nil, fmt.Errorf("invalid EXTERNAL_PROVIDERS: %w", err)
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("EXTERNAL_PROVIDERS is empty")
+	}
+	names := map[string]bool{}
+	for i, p := range providers {
+		if p.Name == "" || p.URL == "" {
+			return nil, fmt.Errorf("EXTERNAL_PROVIDERS[%d] needs a name and a url", i)
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("duplicate provider %q in EXTERNAL_PROVIDERS", p.Name)
+		}
+		names[p.Name] = true
+		for _, pattern := range p.Hosts {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid host pattern %q of provider %s: %w", pattern, p.Name, err)
+			}
+		}
+	}
+	return providers, nil
+}
+
 func getEnv(key, defaultVal string) string {
 	if val := os.Getenv(key); val != "" {
 		return val
 	}
 	return defaultVal
 }
+
+func getEnvInt(key string, defaultVal int) (int, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultVal, nil
+	}
+	val, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, raw, err)
+	}
+	return val, nil
+}
+
+func getEnvDuration(key string, defaultVal time.Duration) (time.Duration, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultVal, nil
+	}
+	val, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, raw, err)
+	}
+	return val, nil
+}
+
+func getEnvBool(key string, defaultVal bool) (bool, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultVal, nil
+	}
+	val, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: %w", key, raw, err)
+	}
+	return val, nil
+}
+
+// getEnvDate parses a YYYY-MM-DD date in UTC, unset with an empty default is the zero time
+func getEnvDate(key, defaultVal string) (time.Time, error) {
+	raw := getEnv(key, defaultVal)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	val, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q, expected YYYY-MM-DD: %w", key, raw, err)
+	}
+	return val, nil
+}
+
+// getEnvPrefixes reads a comma separated list of addresses and CIDR ranges, an address is a range of one
+func getEnvPrefixes(key string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, raw := range strings.Split(os.Getenv(key), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(raw); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q, expected an address or CIDR range", key, raw)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,83 @@
+package domain
+
+import "time"
+
+type AlertRuleKind string
+
+const (
+	// RuleTableSizeAbove fires for every table bigger than Threshold MB
+	RuleTableSizeAbove AlertRuleKind = "table_size_above"
+	// RuleSchemaGrowth fires when a schema grew more than Threshold percent since the previous sync
+	RuleSchemaGrowth AlertRuleKind = "schema_growth"
+	// RuleRowCountDrop fires when a table lost more than Threshold percent of its rows since the previous sync
+	RuleRowCountDrop AlertRuleKind = "row_count_drop"
+)
+
+func (k AlertRuleKind) Valid() bool {
+	switch k {
+	case RuleTableSizeAbove, RuleSchemaGrowth, RuleRowCountDrop:
+		return true
+	}
+	return false
+}
+
+type AlertRoute string
+
+const (
+	AlertRouteLog     AlertRoute = "log"
+	AlertRouteWebhook AlertRoute = "webhook"
+)
+
+// AlertRule is a user defined threshold evaluated after each sync.
+// An empty Source, Schema or Table matches every source, schema or table.
+type AlertRule struct {
+	Id        string        `json:"id"`
+	Name      string        `json:"name"`
+	Kind      AlertRuleKind `json:"kind"`
+	Source    string        `json:"source,omitempty"`
+	Schema    string        `json:"schema,omitempty"`
+	Table     string        `json:"table,omitempty"`
+	Threshold float64       `json:"threshold"`
+	Routes    []AlertRoute  `json:"routes"`
+	Enabled   bool          `json:"enabled"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// AlertRuleRequest is the body of alert rule create and update calls
+type AlertRuleRequest struct {
+	Name      string        `json:"name"`
+	Kind      AlertRuleKind `json:"kind"`
+	Source    string        `json:"source"`
+	Schema    string        `json:"schema"`
+	Table     string        `json:"table"`
+	Threshold float64       `json:"threshold"`
+	Routes    []AlertRoute  `json:"routes"`
+	Enabled   *bool         `json:"enabled"`
+}
+
+// Alert is one firing of a rule against a stored summary
+type Alert struct {
+	Id             string        `json:"id"`
+	RuleId         string        `json:"rule_id"`
+	RuleName       string        `json:"rule_name"`
+	Kind           AlertRuleKind `json:"kind"`
+	SummaryId      string        `json:"summary_id"`
+	Source         string        `json:"source"`
+	Schema         string        `json:"schema,omitempty"`
+	Table          string        `json:"table,omitempty"`
+	Value          float64       `json:"value"`
+	Threshold      float64       `json:"threshold"`
+	Message        string        `json:"message"`
+	FiredAt        time.Time     `json:"fired_at"`
+	Acknowledged   bool          `json:"acknowledged"`
+	AcknowledgedAt *time.Time    `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string        `json:"acknowledged_by,omitempty"`
+}
+
+type AlertFilter struct {
+	Source       string
+	RuleId       string
+	Acknowledged *bool
+	Offset       int
+	Limit        int
+}
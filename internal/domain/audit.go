@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type AuditOutcome string
+
+const (
+	AuditSuccess AuditOutcome = "success"
+	AuditFailure AuditOutcome = "failure"
+)
+
+const (
+	AuditActionSync   = "summary.sync"
+	AuditActionDelete = "summary.delete"
+)
+
+// AuditEvent records who did what to which database and how it went. It never holds a password or an error
+// message, the message of an upstream error may echo connection details, so only its class is kept.
+type AuditEvent struct {
+	Id    string    `json:"id"`
+	At    time.Time `json:"at"`
+	Actor string    `json:"actor"`
+	// ClaimedActor is the name an unverified request asserted, kept apart from Actor which only holds verified ones
+	ClaimedActor string       `json:"claimed_actor,omitempty"`
+	RemoteAddr   string       `json:"remote_addr,omitempty"`
+	Action       string       `json:"action"`
+	Target       string       `json:"target"`            // the source, host:dbname
+	DBUser       string       `json:"db_user,omitempty"` // the user the sync connected as
+	SummaryId    string       `json:"summary_id,omitempty"`
+	JobId        string       `json:"job_id,omitempty"`
+	Outcome      AuditOutcome `json:"outcome"`
+	DurationMs   int64        `json:"duration_ms"`
+	ErrorClass   string       `json:"error_class,omitempty"`
+}
+
+// AuditFilter narrows an audit log query, empty fields match everything and a Limit of 0 is unlimited
+type AuditFilter struct {
+	Actor   string
+	Action  string
+	Target  string
+	Outcome AuditOutcome
+	Since   *time.Time
+	Until   *time.Time
+	Offset  int
+	Limit   int
+}
+
+// Matches reports whether event passes every field of the filter but the paging
+func (f AuditFilter) Matches(event AuditEvent) bool {
+	return (f.Actor == "" || event.Actor == f.Actor) &&
+		(f.Action == "" || event.Action == f.Action) &&
+		(f.Target == "" || event.Target == f.Target) &&
+		(f.Outcome == "" || event.Outcome == f.Outcome) &&
+		(f.Since == nil || !event.At.Before(*f.Since)) &&
+		(f.Until == nil || event.At.Before(*f.Until))
+}
+
+// Actor is who sent a request, taken from the request by the handlers
+type Actor struct {
+	Name       string
+	Claimed    string // a name the request asserted but nothing vouched for, Name is anonymous then
+	RemoteAddr string
+}
+
+type actorKey struct{}
+
+// WithActor records who the work done with ctx is done for, the audit log reads it back
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor set by WithActor, "system" for work nobody asked for (e.g. the janitor)
+func ActorFrom(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Name: "system"}
+}
+
+// ErrorClass buckets err for the audit log: canceled, timeout, unreachable, contract, bad_request, not_found,
+// unavailable, upstream or internal, empty for nil
+func ErrorClass(err error) string {
+	var contractErr *ContractError
+	var appErr *AppError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, ErrExternalServiceUnreachable):
+		return "unreachable"
+	case errors.As(err, &contractErr):
+		return "contract"
+	case errors.As(err, &appErr):
+		switch {
+		case appErr.Code == http.StatusNotFound:
+			return "not_found"
+		case appErr.Code == http.StatusServiceUnavailable:
+			return "unavailable"
+		case appErr.Code == http.StatusBadGateway:
+			return "upstream"
+		case appErr.Code >= 400 && appErr.Code < 500:
+			return "bad_request"
+		}
+	}
+	return "internal"
+}
@@ -15,3 +15,92 @@ type LocalDBStats struct {
 	MaxIdleConnections    int
 	MaxConnectionLifeTime time.Duration
 }
+
+// RetentionPolicy decides which summaries the janitor prunes, a zero value rule is off.
+// note: the newest summary of a source is never pruned
+type RetentionPolicy struct {
+	MaxAge     time.Duration // prune summaries older than this, but the newest of each source
+	KeepLast   int           // keep at most this many summaries per source
+	DailyAfter time.Duration // past this age keep only the newest summary of each day
+	BatchSize  int           // summaries of a source read, and pruned, per page
+	Interval   time.Duration // how often the janitor runs
+}
+
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxAge > 0 || p.KeepLast > 0 || p.DailyAfter > 0
+}
+
+// LegacyRoutes serves the unversioned paths as aliases of /v1 with Deprecation and Sunset headers
+type LegacyRoutes struct {
+	Enabled    bool
+	Deprecated time.Time // sent as the Deprecation header, which is true when zero
+	Sunset     time.Time // when the aliases go away, sent as the Sunset header, none when zero
+}
+
+// CacheConfig sizes the read-through cache of the local repository, a zero bound is unlimited
+type CacheConfig struct {
+	Enabled    bool
+	TTL        time.Duration // how long an entry is served, zero keeps entries until evicted
+	MaxEntries int           // least recently used entries are evicted past this count
+	MaxBytes   int64         // or past this estimated size
+}
+
+// CacheStats are the counters of the local repository cache
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// OutboundSecurity is how calls to the external summary API are secured, empty fields are not used
+type OutboundSecurity struct {
+	CAFile       string // PEM bundle trusted on top of the system roots
+	CertFile     string // client certificate for mTLS, with KeyFile
+	KeyFile      string
+	BearerToken  string // sent as Authorization: Bearer
+	HMACSecret   string // signs every request body, see X-Summary-Signature
+	RequireHTTPS bool   // refuse http:// endpoints
+}
+
+// BreakerConfig tunes the circuit breaker of an external endpoint, a FailureThreshold below 1 turns it off
+type BreakerConfig struct {
+	FailureThreshold int           // consecutive failures that open the breaker
+	CoolDown         time.Duration // how long it stays open before letting a trial call through
+	HalfOpenCalls    int           // trial calls allowed at once while half-open
+}
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerStatus is what an admin or a scrape sees of one breaker
+type BreakerStatus struct {
+	Endpoint            string       `json:"endpoint"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	Opens               uint64       `json:"opens"`
+	Rejected            uint64       `json:"rejected"`
+}
+
+// ServerTLS is how the service serves https, an empty CertFile serves plain http
+type ServerTLS struct {
+	CertFile       string
+	KeyFile        string
+	ClientCAFile   string        // verify client certificates against this bundle
+	ClientAuth     string        // "optional" or "require", used with ClientCAFile
+	MinVersion     string        // "1.2" or "1.3"
+	CipherSuites   []string      // names as in crypto/tls, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256; empty uses the Go defaults
+	ReloadInterval time.Duration // how often the cert files are checked for changes, 0 reloads on SIGHUP only
+	RedirectPort   string        // plain http port redirecting to https, empty is off
+}
+
+func (t ServerTLS) Enabled() bool {
+	return t.CertFile != ""
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ContractViolation is one way an external payload breaks the summary API contract
+type ContractViolation struct {
+	Path    string `json:"path"` // e.g. schemas[0].tables[2].row_count, empty for the payload as a whole
+	Message string `json:"message"`
+}
+
+func (v ContractViolation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// ContractError lists every violation found in an external payload.
+// It reads as a 502 AppError, the upstream answered but not with something we can store.
+type ContractError struct {
+	Version    string
+	Violations []ContractViolation
+}
+
+func (e *ContractError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.String())
+	}
+	return fmt.Sprintf("external summary (version %s) violates the contract: %s", e.Version, strings.Join(parts, "; "))
+}
+
+// As lets errors.As treat a ContractError as an AppError
+func (e *ContractError) As(target any) bool {
+	if appErr, ok := target.(**AppError); ok {
+		*appErr = &AppError{Code: http.StatusBadGateway, Message: e.Error()}
+		return true
+	}
+	return false
+}
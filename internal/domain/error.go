@@ -36,6 +36,18 @@ func NewBadRequestError(msg string) *AppError {
 	return &AppError{Code: http.StatusBadRequest, Message: msg}
 }
 
+func NewNotAcceptableError(msg string) *AppError {
+	return &AppError{Code: http.StatusNotAcceptable, Message: msg}
+}
+
+func NewServiceUnavailableError(msg string) *AppError {
+	return &AppError{Code: http.StatusServiceUnavailable, Message: msg}
+}
+
+func NewBadGatewayError(msg string) *AppError {
+	return &AppError{Code: http.StatusBadGateway, Message: msg}
+}
+
 func NewInternalError(msg string) *AppError {
 	return &AppError{Code: http.StatusInternalServerError, Message: msg}
 }
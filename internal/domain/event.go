@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventSyncSucceeded  EventType = "sync.succeeded"
+	EventSyncFailed     EventType = "sync.failed"
+	EventSummaryDeleted EventType = "summary.deleted"
+	EventAlertFired     EventType = "alert.fired"
+
+	// progress of a running sync, only on the event stream
+	EventSyncStarted EventType = "sync.started"
+	EventSyncFetched EventType = "sync.fetched"
+	EventSyncStoring EventType = "sync.storing"
+
+	// ends a job stream that will not see the final event of its sync, only on the event stream
+	EventJobExpired EventType = "job.expired"
+)
+
+// EventTypes lists every event a webhook can subscribe to
+var EventTypes = []EventType{EventSyncSucceeded, EventSyncFailed, EventSummaryDeleted, EventAlertFired}
+
+func (t EventType) Valid() bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Final reports whether no event of the same sync follows t
+func (t EventType) Final() bool {
+	return t == EventSyncSucceeded || t == EventSyncFailed
+}
+
+// Event is a lifecycle event of a sync or a stored summary
+type Event struct {
+	Id         string        `json:"id"`
+	Type       EventType     `json:"type"`
+	JobId      string        `json:"job_id,omitempty"` // the sync the event belongs to
+	SummaryId  string        `json:"summary_id,omitempty"`
+	Source     string        `json:"source"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	DurationMs int64         `json:"duration_ms"`
+	Error      string        `json:"error,omitempty"`
+	Alert      *Alert        `json:"alert,omitempty"`
+	Progress   *SyncProgress `json:"progress,omitempty"`
+}
+
+// SyncProgress counts the schemas and tables of a summary written so far against its totals
+type SyncProgress struct {
+	Schemas      int `json:"schemas"`
+	SchemasTotal int `json:"schemas_total"`
+	Tables       int `json:"tables"`
+	TablesTotal  int `json:"tables_total"`
+}
+
+type jobIdKey struct{}
+type progressKey struct{}
+
+// WithJobId tags the sync run by ctx with id, its events carry it as job_id
+func WithJobId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, jobIdKey{}, id)
+}
+
+// JobIdFrom returns the job id set by WithJobId, empty when there is none
+func JobIdFrom(ctx context.Context) string {
+	id, _ := ctx.Value(jobIdKey{}).(string)
+	return id
+}
+
+// WithProgress has ReportProgress calls on ctx go to fn
+func WithProgress(ctx context.Context, fn func(SyncProgress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress is called by a repository while it stores a summary, it does nothing without WithProgress
+func ReportProgress(ctx context.Context, progress SyncProgress) {
+	if fn, ok := ctx.Value(progressKey{}).(func(SyncProgress)); ok {
+		fn(progress)
+	}
+}
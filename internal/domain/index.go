@@ -0,0 +1,53 @@
+package domain
+
+// Index is an index of a table (pg_indexes and pg_stat_user_indexes)
+type Index struct {
+	Name       string  `json:"name"`
+	Definition string  `json:"definition"` // pg_indexes.indexdef, e.g. CREATE INDEX users_email_idx ON public.users USING btree (email)
+	SizeMB     float64 `json:"size_mb"`
+	Scans      *int64  `json:"scans,omitempty"` // idx_scan since the stats reset, nil when the external API does not send it
+	Unique     bool    `json:"unique"`
+	Primary    bool    `json:"primary"`
+}
+
+// TableIndex is a stored index with the table it belongs to
+type TableIndex struct {
+	Id      string `json:"id"`
+	TableId string `json:"table_id"`
+	Schema  string `json:"schema"`
+	Table   string `json:"table"`
+	Index
+}
+
+// IndexReport points at the indexes of a summary that cost more than they give
+type IndexReport struct {
+	SummaryId string `json:"summary_id"`
+	// Unused are the indexes without a scan since the stats reset, largest first. Unique and primary ones are
+	// listed too, dropping them drops their constraint.
+	Unused []TableIndex `json:"unused"`
+	// Duplicates are the groups of indexes of one table with the same definition but for their name
+	Duplicates []DuplicateIndexes `json:"duplicates"`
+	Schemas    []SchemaIndexUsage `json:"schemas"`
+}
+
+type DuplicateIndexes struct {
+	Schema     string `json:"schema"`
+	Table      string `json:"table"`
+	Definition string `json:"definition"` // with the index name left out
+	// Indexes starts with the one to keep: the primary key, else a unique index, else the largest
+	Indexes []TableIndex `json:"indexes"`
+	// WastedMB is the size of the group but the index to keep, what dropping the others frees
+	WastedMB float64 `json:"wasted_mb"`
+}
+
+// SchemaIndexUsage is the index to heap size ratio of a schema. HeapSizeMB is nil when a table of the schema
+// has no heap_size_mb, IndexToHeap is nil then or when the heap size is 0.
+type SchemaIndexUsage struct {
+	Schema       string   `json:"schema"`
+	IndexCount   int      `json:"index_count"`
+	IndexSizeMB  float64  `json:"index_size_mb"`
+	HeapSizeMB   *float64 `json:"heap_size_mb,omitempty"`
+	IndexToHeap  *float64 `json:"index_to_heap_ratio,omitempty"`
+	UnusedCount  int      `json:"unused_count"`
+	UnusedSizeMB float64  `json:"unused_size_mb"`
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// ExternalProvider is one deployment of the external summary API
+type ExternalProvider struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Priority int      `json:"priority"` // lower is tried first
+	Region   string   `json:"region"`
+	Hosts    []string `json:"hosts"` // glob patterns of the target hosts it serves, e.g. *.eu.example.com; empty serves any host
+}
+
+type ProviderStats struct {
+	Name         string     `json:"name"`
+	Region       string     `json:"region"`
+	Priority     int        `json:"priority"`
+	Requests     uint64     `json:"requests"`
+	Successes    uint64     `json:"successes"`
+	Failures     uint64     `json:"failures"`
+	Failovers    uint64     `json:"failovers"` // failures after which the next provider was tried
+	SuccessRate  float64    `json:"success_rate"`
+	AvgLatencyMs float64    `json:"avg_latency_ms"`
+	LastError    string     `json:"last_error,omitempty"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+}
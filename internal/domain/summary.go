@@ -41,13 +41,124 @@ type ExternalSummaryResp struct {
 	Schemas []Schema `json:"schemas"`
 }
 
+// Totals is the progress of storing the summary with nothing written yet
+func (r *ExternalSummaryResp) Totals() SyncProgress {
+	progress := SyncProgress{SchemasTotal: len(r.Schemas)}
+	for _, schema := range r.Schemas {
+		progress.TablesTotal += len(schema.Tables)
+	}
+	return progress
+}
+
 /* ########################################## Common Struct ########################################## */
 
 type Table struct {
 	Name      string  `json:"name"`
 	TotalRows int     `json:"row_count"`
 	Size      float64 `json:"size_mb"`
+	TableStats
+	Columns []Column `json:"columns,omitempty"`
+	Indexes []Index  `json:"indexes,omitempty"`
+}
+
+// Column is a column of a table (information_schema.columns), the pg_stats figures are nil for a column that was
+// never analyzed or when the external API does not send them
+type Column struct {
+	Name      string   `json:"name"`
+	DataType  string   `json:"data_type"`
+	Nullable  bool     `json:"nullable"`
+	Default   *string  `json:"default,omitempty"`
+	NullFrac  *float64 `json:"null_frac,omitempty"`
+	NDistinct *float64 `json:"n_distinct,omitempty"` // negative is minus the fraction of distinct rows, as in pg_stats
+	AvgWidth  *int64   `json:"avg_width,omitempty"`
+}
+
+// TableColumn is a stored column, Position is its 1-based place in the table as the external API listed it
+type TableColumn struct {
+	Id       string `json:"id"`
+	TableId  string `json:"table_id"`
+	Position int    `json:"position"`
+	Column
+}
+
+// TableStats are the optional statistics of a table (pg_statio and pg_stat_user_tables), each is nil when the
+// external API did not send it
+type TableStats struct {
+	HeapSizeMB     *float64   `json:"heap_size_mb,omitempty"`
+	IndexSizeMB    *float64   `json:"index_size_mb,omitempty"`
+	ToastSizeMB    *float64   `json:"toast_size_mb,omitempty"`
+	DeadTuples     *int64     `json:"dead_tuples,omitempty"`
+	LastVacuum     *time.Time `json:"last_vacuum,omitempty"`
+	LastAutovacuum *time.Time `json:"last_autovacuum,omitempty"`
+	LastAnalyze    *time.Time `json:"last_analyze,omitempty"`
+	SeqScans       *int64     `json:"seq_scans,omitempty"`
+	IdxScans       *int64     `json:"idx_scans,omitempty"`
+}
+
+// reported tells whether the external API sent any statistics for the table
+func (t TableStats) reported() bool {
+	return t.HeapSizeMB != nil || t.IndexSizeMB != nil || t.ToastSizeMB != nil || t.DeadTuples != nil ||
+		t.LastVacuum != nil || t.LastAutovacuum != nil || t.LastAnalyze != nil || t.SeqScans != nil || t.IdxScans != nil
+}
+
+// SchemaStats aggregates the TableStats of a schema: sums of the sizes, dead tuples and scans over the tables
+// that have them, and the least recent vacuum (manual or auto) and analyze. A field is nil when no table has it.
+// OldestVacuum only covers vacuumed tables, NeverVacuumed counts the tables with statistics but no vacuum at all.
+type SchemaStats struct {
+	HeapSizeMB    *float64   `json:"heap_size_mb,omitempty"`
+	IndexSizeMB   *float64   `json:"index_size_mb,omitempty"`
+	ToastSizeMB   *float64   `json:"toast_size_mb,omitempty"`
+	DeadTuples    *int64     `json:"dead_tuples,omitempty"`
+	SeqScans      *int64     `json:"seq_scans,omitempty"`
+	IdxScans      *int64     `json:"idx_scans,omitempty"`
+	OldestVacuum  *time.Time `json:"oldest_vacuum,omitempty"`
+	OldestAnalyze *time.Time `json:"oldest_analyze,omitempty"`
+	NeverVacuumed *int64     `json:"never_vacuumed,omitempty"`
 }
+
+// Add folds the stats of one more table into the aggregate
+func (s *SchemaStats) Add(t TableStats) {
+	s.HeapSizeMB = addStat(s.HeapSizeMB, t.HeapSizeMB)
+	s.IndexSizeMB = addStat(s.IndexSizeMB, t.IndexSizeMB)
+	s.ToastSizeMB = addStat(s.ToastSizeMB, t.ToastSizeMB)
+	s.DeadTuples = addStat(s.DeadTuples, t.DeadTuples)
+	s.SeqScans = addStat(s.SeqScans, t.SeqScans)
+	s.IdxScans = addStat(s.IdxScans, t.IdxScans)
+
+	vacuum := t.LastVacuum
+	if vacuum == nil || (t.LastAutovacuum != nil && t.LastAutovacuum.After(*vacuum)) {
+		vacuum = t.LastAutovacuum
+	}
+	s.OldestVacuum = oldest(s.OldestVacuum, vacuum)
+	if t.reported() {
+		var never int64
+		if vacuum == nil {
+			never = 1
+		}
+		s.NeverVacuumed = addStat(s.NeverVacuumed, &never)
+	}
+	s.OldestAnalyze = oldest(s.OldestAnalyze, t.LastAnalyze)
+}
+
+func addStat[T int64 | float64](sum, val *T) *T {
+	if val == nil {
+		return sum
+	}
+	total := *val
+	if sum != nil {
+		total += *sum
+	}
+	return &total
+}
+
+func oldest(cur, at *time.Time) *time.Time {
+	if at == nil || (cur != nil && !at.Before(*cur)) {
+		return cur
+	}
+	t := *at
+	return &t
+}
+
 type Schema struct {
 	Name   string  `json:"name"`
 	Tables []Table `json:"tables"`
@@ -59,4 +170,25 @@ type SchemaSummary struct {
 	TableCount  int     `json:"table_count"`
 	TotalRows   int64   `json:"total_rows"`
 	TotalSizeMB float64 `json:"total_size_mb"`
+	SchemaStats
+}
+
+// SummaryTable is a flattened, table-level view of a stored summary, used for exports
+type SummaryTable struct {
+	Id        string    `json:"id"`
+	SummaryId string    `json:"summary_id"`
+	SyncedAt  time.Time `json:"synced_at"`
+	Schema    string    `json:"schema"`
+	Name      string    `json:"name"`
+	TotalRows int64     `json:"row_count"`
+	SizeMB    float64   `json:"size_mb"`
+	TableStats
+}
+
+// SourceSnapshot is the latest stored summary of one source with its tables
+type SourceSnapshot struct {
+	Source    string         `json:"source"`
+	SummaryId string         `json:"summary_id"`
+	SyncedAt  time.Time      `json:"synced_at"`
+	Tables    []SummaryTable `json:"tables"`
 }
@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+type Webhook struct {
+	Id        string      `json:"id"`
+	URL       string      `json:"url"`
+	Secret    string      `json:"secret,omitempty"`
+	Events    []EventType `json:"events"`
+	Active    bool        `json:"active"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// WebhookRequest is the body of webhook create and update calls
+type WebhookRequest struct {
+	URL    string      `json:"url"`
+	Secret string      `json:"secret"`
+	Events []EventType `json:"events"`
+	Active *bool       `json:"active"`
+}
+
+// WebhookDelivery is one delivery attempt of an event to a webhook
+type WebhookDelivery struct {
+	Id          string    `json:"id"`
+	WebhookId   string    `json:"webhook_id"`
+	EventId     string    `json:"event_id"`
+	EventType   EventType `json:"event_type"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Success     bool      `json:"success"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int64     `json:"duration_ms"`
+	DeliveredAt time.Time `json:"delivered_at"`
+}
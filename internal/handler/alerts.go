@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"go.uber.org/zap"
+	"net/http"
+	"pg-summary-service/internal/domain"
+	logger1 "pg-summary-service/internal/logger"
+	"pg-summary-service/internal/utils"
+	"strconv"
+)
+
+type ackRequest struct {
+	By string `json:"by"`
+}
+
+func (s *Server) ListAlertRulesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if resp, err := s.alerts.ListRules(r.Context()); err != nil {
+		logger1.Log.Error("error at ListAlertRulesHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	} else {
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func (s *Server) CreateAlertRuleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req domain.AlertRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger1.Log.Error("Error decoding request", zap.Error(err))
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := s.alerts.CreateRule(r.Context(), req)
+	if err != nil {
+		logger1.Log.Error("error at CreateAlertRuleHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(rule)
+}
+
+func (s *Server) GetAlertRuleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if resp, err := s.alerts.GetRule(r.Context(), r.PathValue("id")); err != nil {
+		logger1.Log.Error("error at GetAlertRuleHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	} else {
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func (s *Server) UpdateAlertRuleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req domain.AlertRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger1.Log.Error("Error decoding request", zap.Error(err))
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if resp, err := s.alerts.UpdateRule(r.Context(), r.PathValue("id"), req); err != nil {
+		logger1.Log.Error("error at UpdateAlertRuleHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	} else {
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func (s *Server) DeleteAlertRuleHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.alerts.DeleteRule(r.Context(), r.PathValue("id")); err != nil {
+		logger1.Log.Error("error at DeleteAlertRuleHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListAlertsHandler lists fired alerts, filterable by ?source=, ?rule_id= and ?acknowledged=
+func (s *Server) ListAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	filter := domain.AlertFilter{
+		Source: query.Get("source"),
+		RuleId: query.Get("rule_id"),
+		Offset: utils.ParseQueryInt(r, "offset", 0),
+		Limit:  utils.ParseQueryInt(r, "limit", 0),
+	}
+	if raw := query.Get("acknowledged"); raw != "" {
+		acknowledged, err := strconv.ParseBool(raw)
+		if err != nil {
+			utils.SendError(w, domain.NewBadRequestError("acknowledged must be true or false"))
+			return
+		}
+		filter.Acknowledged = &acknowledged
+	}
+
+	if resp, err := s.alerts.ListAlerts(r.Context(), filter); err != nil {
+		logger1.Log.Error("error at ListAlertsHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	} else {
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// AcknowledgeAlertHandler serves POST /alerts/{id}/ack
+func (s *Server) AcknowledgeAlertHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req ackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger1.Log.Error("Error decoding request", zap.Error(err))
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if resp, err := s.alerts.AcknowledgeAlert(r.Context(), r.PathValue("id"), req.By); err != nil {
+		logger1.Log.Error("error at AcknowledgeAlertHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	} else {
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"go.uber.org/zap"
+	"net/http"
+	"pg-summary-service/internal/domain"
+	logger1 "pg-summary-service/internal/logger"
+	"pg-summary-service/internal/utils"
+	"time"
+)
+
+// ListAuditHandler serves the audit log newest first, filterable by ?actor=, ?action=, ?target=, ?outcome=,
+// ?since= and ?until= (RFC 3339). As NDJSON every match is exported unless ?limit= is set.
+func (s *Server) ListAuditHandler(w http.ResponseWriter, r *http.Request) {
+	if s.audit == nil {
+		utils.SendError(w, domain.NewServiceUnavailableError("the audit log is not enabled"))
+		return
+	}
+
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		utils.SendError(w, err)
+		return
+	}
+	format, err := negotiateFormat(r)
+	if err != nil {
+		utils.SendError(w, err)
+		return
+	}
+
+	switch format {
+	case formatNDJSON:
+		s.exportAudit(w, r, filter)
+	case formatJSON:
+		events, err := s.audit.ListEvents(r.Context(), filter)
+		if err != nil {
+			logger1.Log.Error("error at ListAuditHandler handler", zap.Error(err))
+			utils.SendError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(events)
+	default:
+		utils.SendError(w, domain.NewNotAcceptableError("the audit log is served as json or ndjson"))
+	}
+}
+
+// exportAudit writes the matching events as NDJSON as they are read, headers go out with the first one
+func (s *Server) exportAudit(w http.ResponseWriter, r *http.Request, filter domain.AuditFilter) {
+	var enc *json.Encoder
+	start := func() {
+		w.Header().Set("Content-Type", contentTypeByFormat[formatNDJSON])
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.ndjson"`)
+		enc = json.NewEncoder(w)
+	}
+	err := s.audit.StreamEvents(r.Context(), filter, func(event domain.AuditEvent) error {
+		if enc == nil {
+			start()
+		}
+		return enc.Encode(event)
+	})
+	if err != nil {
+		logger1.Log.Error("error while exporting audit events", zap.Error(err))
+		if enc == nil {
+			utils.SendError(w, err)
+		}
+		return
+	}
+	if enc == nil {
+		start()
+	}
+}
+
+func parseAuditFilter(r *http.Request) (domain.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := domain.AuditFilter{
+		Actor:   query.Get("actor"),
+		Action:  query.Get("action"),
+		Target:  query.Get("target"),
+		Outcome: domain.AuditOutcome(query.Get("outcome")),
+		Offset:  utils.ParseQueryInt(r, "offset", 0),
+		Limit:   utils.ParseQueryInt(r, "limit", 0),
+	}
+	if filter.Outcome != "" && filter.Outcome != domain.AuditSuccess && filter.Outcome != domain.AuditFailure {
+		return filter, domain.NewBadRequestError("outcome must be success or failure")
+	}
+	if filter.Offset < 0 || filter.Limit < 0 {
+		return filter, domain.NewBadRequestError("offset and limit cannot be negative")
+	}
+	for key, dst := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if raw := query.Get(key); raw != "" {
+			at, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return filter, domain.NewBadRequestError(key + " must be an RFC 3339 time")
+			}
+			at = at.UTC()
+			*dst = &at
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var gzipWriters = sync.Pool{
+	New: func() any {
+		gz, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return gz
+	},
+}
+
+// compress gzips the response when the client accepts it.
+// note: only gzip for now, zstd needs a third party encoder
+func compress(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip") {
+			next(w, r)
+			return
+		}
+
+		// the handlers compare against the identity ETag, the client holds the gzip one
+		if inm := r.Header.Get("If-None-Match"); strings.Contains(inm, gzipETagSuffix) {
+			r = r.Clone(r.Context())
+			r.Header.Set("If-None-Match", strings.ReplaceAll(inm, gzipETagSuffix+`"`, `"`))
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next(gw, r)
+	}
+}
+
+// gzipETagSuffix tells the ETag of a gzip response from the one of the identity response, RFC 9110 8.8.3.3
+// wants a different tag for every content coding of a resource
+const gzipETagSuffix = "-gzip"
+
+// gzipETag adds gzipETagSuffix inside the quotes of etag, W/"abc" becomes W/"abc-gzip"
+func gzipETag(etag string) string {
+	if !strings.HasSuffix(etag, `"`) || strings.HasSuffix(etag, gzipETagSuffix+`"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + gzipETagSuffix + `"`
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header allows coding, a q of 0 refuses it
+func acceptsEncoding(header, coding string) bool {
+	accepted := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != coding && name != "*" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		// an explicit entry for the coding wins over *
+		if name == coding {
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
+
+// gzipResponseWriter compresses the body as it is written, streamed responses stay streamed.
+// Bodiless responses (204, 304), event streams and responses that are already encoded are passed through.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+	passThrough bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+
+	h := g.Header()
+	// proxies buffer a compressed event stream, and one missed Flush would hold the events back
+	if h.Get("Content-Encoding") != "" || strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") {
+		g.passThrough = true
+		g.ResponseWriter.WriteHeader(code)
+		return
+	}
+	// a 304 carries the ETag the 200 would have, the one of the gzip representation
+	if etag := h.Get("ETag"); etag != "" && code >= http.StatusOK && code != http.StatusNoContent {
+		h.Set("ETag", gzipETag(etag))
+	}
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		g.passThrough = true
+	} else {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+	}
+	g.ResponseWriter.WriteHeader(code)
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !g.wroteHeader {
+		// the server would sniff the compressed bytes, so sniff the plain ones here
+		if g.Header().Get("Content-Type") == "" {
+			g.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.passThrough {
+		return g.ResponseWriter.Write(p)
+	}
+	return g.writer().Write(p)
+}
+
+// writer returns the gzip writer, taken from the pool on first use
+func (g *gzipResponseWriter) writer() *gzip.Writer {
+	if g.gz == nil {
+		g.gz = gzipWriters.Get().(*gzip.Writer)
+		g.gz.Reset(g.ResponseWriter)
+	}
+	return g.gz
+}
+
+// Flush pushes what was compressed so far to the client. A flush before the first write commits the headers,
+// so the encoding is decided first: a response sent as gzip must be a gzip stream even if nothing follows.
+func (g *gzipResponseWriter) Flush() {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if !g.passThrough {
+		_ = g.writer().Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+func (g *gzipResponseWriter) close() {
+	if g.gz == nil {
+		return
+	}
+	_ = g.gz.Close()
+	gzipWriters.Put(g.gz)
+	g.gz = nil
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// cacheControlRevalidate lets clients keep a copy but revalidate it on every use: lists grow with every sync
+	// and a stored summary can be deleted, so nothing is served as immutable
+	cacheControlRevalidate = "private, no-cache"
+	// representationVersion is part of every versionETag, bump it when the shape of a body changes (e.g. new
+	// columns or fields) so clients do not keep a copy of the old shape
+	representationVersion = "2"
+)
+
+// validators identify one representation of a resource for conditional requests
+type validators struct {
+	etag         string
+	lastModified time.Time
+}
+
+// contentETag is a strong ETag over the encoded body
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// versionETag is a strong ETag for a representation that is fully determined by its parts, e.g. summary id and format
+func versionETag(parts ...string) string {
+	return contentETag([]byte(strings.Join(append([]string{representationVersion}, parts...), "\x00")))
+}
+
+// checkNotModified sets the validator and caching headers and answers 304 when the client copy is current.
+// If-None-Match wins over If-Modified-Since as in RFC 9110 13.2.2.
+func checkNotModified(w http.ResponseWriter, r *http.Request, v validators, cacheControl string) bool {
+	h := w.Header()
+	h.Set("Cache-Control", cacheControl)
+	h.Add("Vary", "Accept")
+	if v.etag != "" {
+		h.Set("ETag", v.etag)
+	}
+	if !v.lastModified.IsZero() {
+		h.Set("Last-Modified", v.lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	notModified := false
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		notModified = v.etag != "" && etagMatches(inm, v.etag)
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && !v.lastModified.IsZero() {
+		// http dates have second precision, synced_at has microseconds
+		if since, err := http.ParseTime(ims); err == nil {
+			notModified = !v.lastModified.Truncate(time.Second).After(since)
+		}
+	}
+
+	if notModified {
+		w.WriteHeader(http.StatusNotModified)
+	}
+	return notModified
+}
+
+// etagMatches does the weak comparison If-None-Match asks for, header is a list of tags or *
+func etagMatches(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeConditionalJSON encodes body, tags it with a content ETag and writes it unless the client copy is current
+func writeConditionalJSON(w http.ResponseWriter, r *http.Request, body any, lastModified time.Time, cacheControl string) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return err
+	}
+
+	if checkNotModified(w, r, validators{etag: contentETag(buf.Bytes()), lastModified: lastModified}, cacheControl) {
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
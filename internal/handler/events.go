@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"math"
+	"net/http"
+	"pg-summary-service/internal/domain"
+	logger1 "pg-summary-service/internal/logger"
+	service2 "pg-summary-service/internal/service"
+	"pg-summary-service/internal/utils"
+	"strconv"
+	"time"
+)
+
+// sseHeartbeat is how often an idle stream gets a comment line, proxies close connections that stay silent
+const sseHeartbeat = 15 * time.Second
+
+// defaultJobStreamTimeout is how long a job stream waits for the final event of its sync
+const defaultJobStreamTimeout = 30 * time.Minute
+
+// jobStream ends the stream of a job with job.expired when the final event of its sync will not come
+type jobStream struct {
+	id      string
+	evicted <-chan struct{} // closed once the events of the job left the buffer
+	timeout time.Duration
+}
+
+// StreamEventsHandler serves the sync events as Server-Sent Events, only those of ?source= when set
+func (s *Server) StreamEventsHandler(w http.ResponseWriter, r *http.Request) {
+	source := r.URL.Query().Get("source")
+	s.streamEvents(w, r, nil, func(event domain.Event) bool {
+		return source == "" || event.Source == source
+	})
+}
+
+// StreamJobEventsHandler serves the events of one sync from its start, the stream ends after succeeded or failed.
+// A job without buffered events is not found. The stream ends with job.expired once the events of the job left
+// the buffer or when no final event came within the job stream timeout.
+func (s *Server) StreamJobEventsHandler(w http.ResponseWriter, r *http.Request) {
+	jobId := r.PathValue("id")
+	job := &jobStream{id: jobId, timeout: s.jobStreamTimeout}
+	if s.events != nil {
+		var ok bool
+		if job.evicted, ok = s.events.Job(jobId); !ok {
+			utils.SendError(w, domain.NewNotFoundError(fmt.Sprintf("no events of job %s are buffered", jobId)))
+			return
+		}
+	}
+	s.streamEvents(w, r, job, func(event domain.Event) bool {
+		return event.JobId == jobId
+	})
+}
+
+// streamEvents writes the buffered events after Last-Event-ID and then the new ones as they are published.
+// Without Last-Event-ID a job stream replays everything buffered for the job, the full stream only new events.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, job *jobStream, filter func(domain.Event) bool) {
+	if s.events == nil {
+		utils.SendError(w, domain.NewServiceUnavailableError("the event stream is not enabled"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.SendError(w, domain.NewInternalError("streaming is not supported"))
+		return
+	}
+
+	lastSeq := uint64(0)
+	if job == nil {
+		lastSeq = math.MaxUint64
+	}
+	if raw := lastEventId(r); raw != "" {
+		var err error
+		if lastSeq, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			utils.SendError(w, domain.NewBadRequestError("Last-Event-ID must be the id of an event"))
+			return
+		}
+	}
+
+	replay, events, cancel := s.events.Subscribe(lastSeq, filter)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx would hold the events back
+	w.WriteHeader(http.StatusOK)
+	for _, item := range replay {
+		if done, err := writeEvent(w, item, job); err != nil || done {
+			flusher.Flush()
+			return
+		}
+	}
+	flusher.Flush()
+
+	// both stay nil, and never fire, on the full stream
+	var evicted <-chan struct{}
+	var deadline <-chan time.Time
+	if job != nil {
+		evicted = job.evicted
+		timer := time.NewTimer(job.timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-evicted:
+			_ = writeExpired(w, job.id, "the events of the job were evicted from the buffer")
+			flusher.Flush()
+			return
+		case <-deadline:
+			_ = writeExpired(w, job.id, fmt.Sprintf("no final event within %s", job.timeout))
+			flusher.Flush()
+			return
+		case item, ok := <-events:
+			// closed when the client fell behind, it reconnects with Last-Event-ID and gets the rest replayed
+			if !ok {
+				return
+			}
+			done, err := writeEvent(w, item, job)
+			flusher.Flush()
+			if err != nil || done {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes one SSE event, done is set once a job stream has seen the last event of its sync
+func writeEvent(w http.ResponseWriter, item service2.StreamEvent, job *jobStream) (done bool, err error) {
+	data, err := json.Marshal(item.Event)
+	if err != nil {
+		logger1.Log.Error("error while encoding event", zap.Error(err))
+		return false, err
+	}
+	if _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", item.Seq, item.Event.Type, data); err != nil {
+		return false, err
+	}
+	return job != nil && item.Event.Type.Final(), nil
+}
+
+// writeExpired writes the job.expired event ending a job stream, without an id as it is not buffered
+func writeExpired(w http.ResponseWriter, jobId, reason string) error {
+	data, err := json.Marshal(domain.Event{
+		Id:         uuid.New().String(),
+		Type:       domain.EventJobExpired,
+		JobId:      jobId,
+		FinishedAt: time.Now().UTC(),
+		Error:      reason,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", domain.EventJobExpired, data)
+	return err
+}
+
+// lastEventId is the Last-Event-ID header a reconnecting EventSource sends, or ?last_event_id= for other clients
+func lastEventId(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return r.URL.Query().Get("last_event_id")
+}
@@ -0,0 +1,299 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"pg-summary-service/internal/domain"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	formatJSON     = "json"
+	formatCSV      = "csv"
+	formatNDJSON   = "ndjson"
+	formatMarkdown = "markdown"
+)
+
+// formatByMediaType maps Accept media types onto export formats
+var formatByMediaType = map[string]string{
+	"application/json":     formatJSON,
+	"text/csv":             formatCSV,
+	"application/x-ndjson": formatNDJSON,
+	"application/ndjson":   formatNDJSON,
+	"text/markdown":        formatMarkdown,
+	"*/*":                  formatJSON,
+	"application/*":        formatJSON,
+	"text/*":               formatCSV,
+}
+
+var contentTypeByFormat = map[string]string{
+	formatJSON:     "application/json",
+	formatCSV:      "text/csv; charset=utf-8",
+	formatNDJSON:   "application/x-ndjson",
+	formatMarkdown: "text/markdown; charset=utf-8",
+}
+
+// tableColumns head the csv and markdown exports, the statistics after size_mb are empty when not reported
+var tableColumns = []string{"summary_id", "synced_at", "schema", "table", "row_count", "size_mb",
+	"heap_size_mb", "index_size_mb", "toast_size_mb", "dead_tuples", "last_vacuum", "last_autovacuum", "last_analyze",
+	"seq_scans", "idx_scans"}
+
+// negotiateFormat picks the response format, ?format= wins over the Accept header
+func negotiateFormat(r *http.Request) (string, error) {
+	if raw := strings.ToLower(r.URL.Query().Get("format")); raw != "" {
+		if raw == "md" {
+			raw = formatMarkdown
+		}
+		if _, ok := contentTypeByFormat[raw]; !ok {
+			return "", domain.NewBadRequestError(fmt.Sprintf("unsupported format %q", raw))
+		}
+		return raw, nil
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return formatJSON, nil
+	}
+
+	// the highest q wins and ties go to the type listed first. q=0 refuses a format, also when a wildcard
+	// listed with it would match it.
+	type accepted struct {
+		format string
+		q      float64
+	}
+	var candidates []accepted
+	refused := map[string]bool{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		format, ok := formatByMediaType[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(raw, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, accepted{format, q})
+		} else if !strings.Contains(mediaType, "*") {
+			refused[format] = true
+		}
+	}
+
+	best := accepted{}
+	for _, c := range candidates {
+		if c.q > best.q && !refused[c.format] {
+			best = c
+		}
+	}
+	if best.format == "" {
+		return "", domain.NewNotAcceptableError("none of the accepted media types are supported")
+	}
+	return best.format, nil
+}
+
+// tableWriter streams summary tables in one export format
+type tableWriter interface {
+	Write(table domain.SummaryTable) error
+	Close() error
+}
+
+// newTableWriter sets the content type and returns a writer for format
+func newTableWriter(w http.ResponseWriter, format string) tableWriter {
+	w.Header().Set("Content-Type", contentTypeByFormat[format])
+	flusher, _ := w.(http.Flusher)
+
+	switch format {
+	case formatCSV:
+		return &csvTableWriter{w: csv.NewWriter(w), flusher: flusher}
+	case formatNDJSON:
+		return &ndjsonTableWriter{enc: json.NewEncoder(w), flusher: flusher}
+	case formatMarkdown:
+		return &markdownTableWriter{w: w, flusher: flusher}
+	default:
+		return &jsonTableWriter{w: w, flusher: flusher}
+	}
+}
+
+func tableRecord(table domain.SummaryTable) []string {
+	stats := table.TableStats
+	return []string{
+		table.SummaryId,
+		table.SyncedAt.UTC().Format(time.RFC3339),
+		table.Schema,
+		table.Name,
+		strconv.FormatInt(table.TotalRows, 10),
+		strconv.FormatFloat(table.SizeMB, 'f', -1, 64),
+		floatCell(stats.HeapSizeMB),
+		floatCell(stats.IndexSizeMB),
+		floatCell(stats.ToastSizeMB),
+		intCell(stats.DeadTuples),
+		timeCell(stats.LastVacuum),
+		timeCell(stats.LastAutovacuum),
+		timeCell(stats.LastAnalyze),
+		intCell(stats.SeqScans),
+		intCell(stats.IdxScans),
+	}
+}
+
+// floatCell, intCell and timeCell format an optional statistic, empty when it was not reported
+func floatCell(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func intCell(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+func timeCell(v *time.Time) string {
+	if v == nil {
+		return ""
+	}
+	return v.UTC().Format(time.RFC3339)
+}
+
+type csvTableWriter struct {
+	w          *csv.Writer
+	flusher    http.Flusher
+	wroteHead  bool
+	sinceFlush int
+}
+
+func (c *csvTableWriter) Write(table domain.SummaryTable) error {
+	if !c.wroteHead {
+		if err := c.w.Write(tableColumns); err != nil {
+			return err
+		}
+		c.wroteHead = true
+	}
+	if err := c.w.Write(tableRecord(table)); err != nil {
+		return err
+	}
+
+	// flush in chunks so big exports reach the client while still being read
+	c.sinceFlush++
+	if c.sinceFlush >= 100 {
+		c.sinceFlush = 0
+		c.w.Flush()
+		if c.flusher != nil {
+			c.flusher.Flush()
+		}
+	}
+	return c.w.Error()
+}
+
+func (c *csvTableWriter) Close() error {
+	if !c.wroteHead {
+		if err := c.w.Write(tableColumns); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonTableWriter struct {
+	enc     *json.Encoder
+	flusher http.Flusher
+}
+
+func (n *ndjsonTableWriter) Write(table domain.SummaryTable) error {
+	if err := n.enc.Encode(table); err != nil {
+		return err
+	}
+	if n.flusher != nil {
+		n.flusher.Flush()
+	}
+	return nil
+}
+
+func (n *ndjsonTableWriter) Close() error {
+	return nil
+}
+
+type markdownTableWriter struct {
+	w         http.ResponseWriter
+	flusher   http.Flusher
+	wroteHead bool
+}
+
+func (m *markdownTableWriter) writeHead() error {
+	m.wroteHead = true
+	header := "| " + strings.Join(tableColumns, " | ") + " |\n"
+	header += strings.Repeat("| --- ", len(tableColumns)) + "|\n"
+	_, err := m.w.Write([]byte(header))
+	return err
+}
+
+func (m *markdownTableWriter) Write(table domain.SummaryTable) error {
+	if !m.wroteHead {
+		if err := m.writeHead(); err != nil {
+			return err
+		}
+	}
+
+	cells := tableRecord(table)
+	for i, cell := range cells {
+		cells[i] = strings.ReplaceAll(cell, "|", `\|`)
+	}
+	_, err := m.w.Write([]byte("| " + strings.Join(cells, " | ") + " |\n"))
+	return err
+}
+
+func (m *markdownTableWriter) Close() error {
+	if !m.wroteHead {
+		return m.writeHead()
+	}
+	if m.flusher != nil {
+		m.flusher.Flush()
+	}
+	return nil
+}
+
+// jsonTableWriter writes a JSON array one element at a time
+type jsonTableWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	count   int
+}
+
+func (j *jsonTableWriter) Write(table domain.SummaryTable) error {
+	prefix := ","
+	if j.count == 0 {
+		prefix = "["
+	}
+	body, err := json.Marshal(table)
+	if err != nil {
+		return err
+	}
+	if _, err = j.w.Write(append([]byte(prefix), body...)); err != nil {
+		return err
+	}
+	j.count++
+	return nil
+}
+
+func (j *jsonTableWriter) Close() error {
+	closing := "]\n"
+	if j.count == 0 {
+		closing = "[]\n"
+	}
+	_, err := j.w.Write([]byte(closing))
+	return err
+}
@@ -2,33 +2,54 @@ package handler
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"net/http"
+	"pg-summary-service/internal/config"
 	"pg-summary-service/internal/domain"
 	logger1 "pg-summary-service/internal/logger"
+	"pg-summary-service/internal/metrics"
 	"pg-summary-service/internal/utils"
+	"time"
 )
 
 const (
-	defaultLimit  = 0
-	defaultOffset = 20
+	defaultLimit  = 20
+	defaultOffset = 0
+
+	jobIdHeader = "X-Job-Id"
 )
 
-func SyncSummaryHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) SyncSummaryHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Parse request body
 	var req domain.RemoteDBDetails
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		logger1.Log.Error("Error decoding request", zap.Error(err))
+		s.service.RejectSync(r.Context(), req, domain.NewBadRequestError("invalid request payload"))
 		http.Error(w, "invalid request payload", http.StatusBadRequest)
 		return
 	} else if err = utils.ValidateDBDetails(req); err != nil {
+		s.service.RejectSync(r.Context(), req, err)
+		utils.SendError(w, err)
+		return
+	}
+
+	// the job id tags the events of the sync, a client picks it to follow GET /jobs/{id}/events from the start
+	jobId := r.Header.Get(jobIdHeader)
+	if jobId == "" {
+		jobId = uuid.New().String()
+	} else if _, err := uuid.Parse(jobId); err != nil {
+		err = domain.NewBadRequestError(jobIdHeader + " must be a uuid")
+		s.service.RejectSync(r.Context(), req, err)
 		utils.SendError(w, err)
+		return
 	}
+	w.Header().Set(jobIdHeader, jobId)
 
-	_, err := service.SyncSummary(r.Context(), req)
+	_, err := s.service.SyncSummary(domain.WithJobId(r.Context(), jobId), req)
 	if err != nil {
 		logger1.Log.Error("error while fetching and saving data from external api", zap.Error(err))
 		utils.SendError(w, err)
@@ -38,64 +59,377 @@ func SyncSummaryHandler(w http.ResponseWriter, r *http.Request) {
 	// Placeholder response
 	_ = json.NewEncoder(w).Encode(map[string]string{
 		"status": "sync triggered",
+		"job_id": jobId,
 	})
 }
 
-func GetSummariesHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	fmt.Println("geckjnn")
-
+func (s *Server) GetSummariesHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse optional query params for pagination
 	//note: limit and offset to control fetching to avoid over-fetching too many rows at once
-	offset := utils.ParseQueryInt(r, "limit", defaultLimit)
-	limit := utils.ParseQueryInt(r, "offset", defaultOffset) //default limit 20
+	offset := utils.ParseQueryInt(r, "offset", defaultOffset)
+	limit := utils.ParseQueryInt(r, "limit", defaultLimit) //default limit 20
+	if offset < 0 || limit < 1 {
+		utils.SendError(w, domain.NewBadRequestError("offset cannot be negative and limit must be at least 1"))
+		return
+	}
 
-	if resp, err := service.GetSummaries(r.Context(), offset, limit); err != nil {
+	format, err := negotiateFormat(r)
+	if err != nil {
+		utils.SendError(w, err)
+		return
+	}
+	switch format {
+	case formatNDJSON:
+		s.streamSummaries(w, r, offset, limit)
+		return
+	case formatJSON:
+	default:
+		utils.SendError(w, domain.NewNotAcceptableError("summaries are served as json or ndjson"))
+		return
+	}
+
+	resp, err := s.service.GetSummaries(r.Context(), offset, limit)
+	if err != nil {
 		logger1.Log.Error("error at GetSummaries handler", zap.Error(err))
 		utils.SendError(w, err)
 		return
-	} else {
-		_ = json.NewEncoder(w).Encode(resp)
+	}
+
+	// Last-Modified is the newest synced_at, it misses deletes so clients should revalidate with the ETag
+	var lastModified time.Time
+	for _, item := range resp {
+		if item.SyncedAt.After(lastModified) {
+			lastModified = item.SyncedAt
+		}
+	}
+	if err = writeConditionalJSON(w, r, resp, lastModified, cacheControlRevalidate); err != nil {
+		logger1.Log.Error("error while writing summaries", zap.Error(err))
 	}
 }
 
-func GetSummaryByIDHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// streamSummaries writes the page as NDJSON row by row as it comes from the repository, large pages are never
+// held in memory; there is no ETag as the body is not known up front
+func (s *Server) streamSummaries(w http.ResponseWriter, r *http.Request, offset, limit int) {
+	var enc *json.Encoder
+	flusher, _ := w.(http.Flusher)
+	err := s.service.StreamSummaries(r.Context(), offset, limit, func(item domain.LocalSummaryListItem) error {
+		if enc == nil {
+			w.Header().Set("Content-Type", contentTypeByFormat[formatNDJSON])
+			w.Header().Set("Cache-Control", cacheControlRevalidate)
+			enc = json.NewEncoder(w)
+		}
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		logger1.Log.Error("error while streaming summaries", zap.Error(err))
+		if enc == nil {
+			utils.SendError(w, err)
+		}
+		return
+	}
+	if enc == nil {
+		w.Header().Set("Content-Type", contentTypeByFormat[formatNDJSON])
+		w.Header().Set("Cache-Control", cacheControlRevalidate)
+	}
+}
 
-	// Extract ID from URL using utils
-	id, err := utils.ExtractIDFromPath(r, "/summaries/")
+func (s *Server) GetSummaryByIDHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	format, err := negotiateFormat(r)
 	if err != nil {
-		http.Error(w, "missing or invalid summary ID", http.StatusBadRequest)
+		utils.SendError(w, err)
 		return
 	}
 
-	if resp, err := service.GetSummaryByID(r.Context(), id); err != nil {
+	// export formats are table level, only json keeps the per schema aggregation
+	if format != formatJSON {
+		s.writeSummaryTables(w, r, id, format)
+		return
+	}
+
+	resp, err := s.service.GetSummaryByID(r.Context(), id)
+	if err != nil {
 		logger1.Log.Error("error at GetSummaryByIDHandler handler", zap.Error(err))
 		utils.SendError(w, err)
 		return
-	} else {
-		_ = json.NewEncoder(w).Encode(resp)
+	}
+	if err = writeConditionalJSON(w, r, resp, resp.SyncedAt, cacheControlRevalidate); err != nil {
+		logger1.Log.Error("error while writing summary", zap.Error(err))
+	}
+}
+
+func (s *Server) DeleteSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.service.DeleteSummary(r.Context(), r.PathValue("id")); err != nil {
+		logger1.Log.Error("error at DeleteSummaryHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) GetSummaryTablesHandler(w http.ResponseWriter, r *http.Request) {
+	format, err := negotiateFormat(r)
+	if err != nil {
+		utils.SendError(w, err)
+		return
+	}
+	s.writeSummaryTables(w, r, r.PathValue("id"), format)
+}
+
+// GetTableColumnsHandler serves the columns of one table of a summary, as JSON only
+func (s *Server) GetTableColumnsHandler(w http.ResponseWriter, r *http.Request) {
+	if !acceptsJSON(w, r, "columns") {
+		return
+	}
+	columns, err := s.service.GetTableColumns(r.Context(), r.PathValue("id"), r.PathValue("tableId"))
+	if err != nil {
+		logger1.Log.Error("error at GetTableColumnsHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	}
+	// the columns of a stored summary never change, the ETag is over the body
+	if err = writeConditionalJSON(w, r, columns, time.Time{}, cacheControlRevalidate); err != nil {
+		logger1.Log.Error("error while writing table columns", zap.Error(err))
 	}
 }
 
-func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotFound)
-	html := `
-	<!DOCTYPE html>
-	<html>
-	<head>
-		<title>404 Not Found</title>
-		<style>
-			body { font-family: Arial, sans-serif; text-align: center; padding: 50px; }
-			h1 { font-size: 50px; }
-			p { font-size: 20px; }
-		</style>
-	</head>
-	<body>
-		<h1>404</h1>
-		<p>Oops! The page you requested does not exist.</p>
-	</body>
-	</html>
-	`
-	w.Write([]byte(html))
+// GetSummaryIndexesHandler serves the indexes of every table of a summary, as JSON only
+func (s *Server) GetSummaryIndexesHandler(w http.ResponseWriter, r *http.Request) {
+	if !acceptsJSON(w, r, "indexes") {
+		return
+	}
+	indexes, err := s.service.GetSummaryIndexes(r.Context(), r.PathValue("id"))
+	if err != nil {
+		logger1.Log.Error("error at GetSummaryIndexesHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	}
+	if err = writeConditionalJSON(w, r, indexes, time.Time{}, cacheControlRevalidate); err != nil {
+		logger1.Log.Error("error while writing summary indexes", zap.Error(err))
+	}
+}
+
+// IndexReportHandler serves the unused and duplicate indexes of a summary and the index to heap ratio per schema
+func (s *Server) IndexReportHandler(w http.ResponseWriter, r *http.Request) {
+	if !acceptsJSON(w, r, "the index report") {
+		return
+	}
+	report, err := s.service.IndexReport(r.Context(), r.PathValue("id"))
+	if err != nil {
+		logger1.Log.Error("error at IndexReportHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	}
+	if err = writeConditionalJSON(w, r, report, time.Time{}, cacheControlRevalidate); err != nil {
+		logger1.Log.Error("error while writing index report", zap.Error(err))
+	}
+}
+
+// acceptsJSON answers 400 or 406 unless the request takes JSON, what names the resource in the message
+func acceptsJSON(w http.ResponseWriter, r *http.Request, what string) bool {
+	if format, err := negotiateFormat(r); err != nil {
+		utils.SendError(w, err)
+		return false
+	} else if format != formatJSON {
+		utils.SendError(w, domain.NewNotAcceptableError("only json is served for "+what))
+		return false
+	}
+	return true
+}
+
+// errNotModified stops a stream once the client copy turned out to be current
+var errNotModified = errors.New("not modified")
+
+// writeSummaryTables streams the tables of a summary in format straight from the repository.
+// Headers go out with the first row, so a lookup error can still be sent as a proper status.
+func (s *Server) writeSummaryTables(w http.ResponseWriter, r *http.Request, id string, format string) {
+	// a stored summary never changes, so id and format determine the body
+	etag := versionETag(id, format)
+
+	var writer tableWriter
+	err := s.service.StreamSummaryTables(r.Context(), id, func(table domain.SummaryTable) error {
+		if writer == nil {
+			if checkNotModified(w, r, validators{etag: etag, lastModified: table.SyncedAt}, cacheControlRevalidate) {
+				return errNotModified
+			}
+			writer = newTableWriter(w, format)
+		}
+		return writer.Write(table)
+	})
+	if errors.Is(err, errNotModified) {
+		return
+	} else if err != nil {
+		logger1.Log.Error("error while writing summary tables", zap.Error(err), zap.String("summary id", id))
+		if writer == nil {
+			utils.SendError(w, err)
+		}
+		return
+	}
+
+	// a summary without tables
+	if writer == nil {
+		if checkNotModified(w, r, validators{etag: etag}, cacheControlRevalidate) {
+			return
+		}
+		writer = newTableWriter(w, format)
+	}
+	if err = writer.Close(); err != nil {
+		logger1.Log.Error("error while writing summary tables", zap.Error(err))
+	}
+}
+
+// ExportSummariesHandler streams the tables of every summary stored for ?source=
+func (s *Server) ExportSummariesHandler(w http.ResponseWriter, r *http.Request) {
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		utils.SendError(w, domain.NewBadRequestError("source query param is required"))
+		return
+	}
+
+	format, err := negotiateFormat(r)
+	if err != nil {
+		utils.SendError(w, err)
+		return
+	}
+
+	// the writer is created on the first row so a lookup error can still be sent as a proper status
+	var writer tableWriter
+	err = s.service.ExportSourceTables(r.Context(), source, func(table domain.SummaryTable) error {
+		if writer == nil {
+			writer = newTableWriter(w, format)
+		}
+		return writer.Write(table)
+	})
+	if err != nil {
+		logger1.Log.Error("error at ExportSummariesHandler handler", zap.Error(err), zap.String("source", source))
+		if writer == nil {
+			utils.SendError(w, err)
+		}
+		return
+	}
+	// a source whose summaries have no tables exports an empty body
+	if writer == nil {
+		writer = newTableWriter(w, format)
+	}
+	if err = writer.Close(); err != nil {
+		logger1.Log.Error("error while writing summaries export", zap.Error(err))
+	}
+}
+
+// SummaryMetricsHandler exposes the latest summary of every source as Prometheus gauges
+func (s *Server) SummaryMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := s.service.LatestSnapshots(r.Context())
+	if err != nil {
+		logger1.Log.Error("error at SummaryMetricsHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	if err = metrics.WriteSummaryGauges(w, snapshots, config.GetSummaryMetricsLimits()); err != nil {
+		logger1.Log.Error("error while writing summary metrics", zap.Error(err))
+	}
+}
+
+// ServiceMetricsHandler exports the health of the service itself: the cache, the circuit breakers and the
+// external providers. The stored summary data is on /metrics/summaries.
+func (s *Server) ServiceMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	if s.cache != nil {
+		if err := metrics.WriteCacheCounters(w, s.cache.Stats()); err != nil {
+			logger1.Log.Error("error while writing cache metrics", zap.Error(err))
+			return
+		}
+	}
+	if s.breakers != nil {
+		if err := metrics.WriteBreakerGauges(w, s.breakers.BreakerStatus()); err != nil {
+			logger1.Log.Error("error while writing breaker metrics", zap.Error(err))
+			return
+		}
+	}
+	if s.providers != nil {
+		if err := metrics.WriteProviderCounters(w, s.providers.ProviderStats()); err != nil {
+			logger1.Log.Error("error while writing provider metrics", zap.Error(err))
+		}
+	}
+}
+
+// GetProvidersHandler returns the external summary providers in priority order with their request stats
+func (s *Server) GetProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	stats := []domain.ProviderStats{}
+	if s.providers != nil {
+		stats = append(stats, s.providers.ProviderStats()...)
+	}
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+// GetBreakersHandler returns the circuit breaker state of every external endpoint
+func (s *Server) GetBreakersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	status := []domain.BreakerStatus{}
+	if s.breakers != nil {
+		status = append(status, s.breakers.BreakerStatus()...)
+	}
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// GetCacheStatsHandler returns the cache configuration and counters
+func (s *Server) GetCacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	conf := config.GetCacheConfig()
+	resp := map[string]any{
+		"enabled":     s.cache != nil,
+		"ttl":         conf.TTL.String(),
+		"max_entries": conf.MaxEntries,
+		"max_bytes":   conf.MaxBytes,
+	}
+	if s.cache != nil {
+		resp["stats"] = s.cache.Stats()
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// GetRetentionHandler returns the retention policy and the report of the latest janitor run
+func (s *Server) GetRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	policy := s.janitor.Policy()
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"policy": map[string]any{
+			"enabled":     policy.Enabled(),
+			"max_age":     policy.MaxAge.String(),
+			"keep_last":   policy.KeepLast,
+			"daily_after": policy.DailyAfter.String(),
+			"batch_size":  policy.BatchSize,
+			"interval":    policy.Interval.String(),
+		},
+		"last_report": s.janitor.LastReport(),
+	})
+}
+
+// RunRetentionHandler runs the janitor now and returns what it pruned
+func (s *Server) RunRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.janitor.Policy().Enabled() {
+		utils.SendError(w, domain.NewBadRequestError("no retention policy configured"))
+		return
+	}
+	if resp, err := s.janitor.Run(r.Context()); err != nil {
+		logger1.Log.Error("error at RunRetentionHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	} else {
+		_ = json.NewEncoder(w).Encode(resp)
+	}
 }
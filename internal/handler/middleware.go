@@ -1,12 +1,17 @@
 package handler
 
 import (
+	"encoding/json"
 	"fmt"
 	"go.uber.org/zap"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
+	"pg-summary-service/internal/domain"
 	logger2 "pg-summary-service/internal/logger"
 	"runtime/debug"
+	"strconv"
 )
 
 type requestLog struct {
@@ -16,17 +21,116 @@ type requestLog struct {
 	RemoteAddr string `json:"remoteAddr"`
 }
 
-func methodChecker(expected string, next http.HandlerFunc) http.HandlerFunc {
+// noRoute answers a request the mux has no handler for. The mux knows whether the path exists for other methods,
+// so its own 404/405 handler runs against a recorder and only the status and Allow header are kept.
+func noRoute(mux *http.ServeMux, w http.ResponseWriter, r *http.Request) {
+	h, _ := mux.Handler(r)
+	rec := &statusRecorder{header: http.Header{}, code: http.StatusNotFound}
+	h.ServeHTTP(rec, r)
+
+	if rec.code == http.StatusMethodNotAllowed {
+		logger2.Log.Error("Method not allowed", zap.String("method", r.Method), zap.String("path", r.URL.Path))
+		w.Header().Set("Allow", rec.header.Get("Allow"))
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed, allowed: %s", r.Method, rec.header.Get("Allow")))
+		return
+	}
+	writeJSONError(w, http.StatusNotFound, fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path))
+}
+
+// writeJSONError writes {"code": ..., "message": ...}
+func writeJSONError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{"code": code, "message": msg})
+}
+
+// statusRecorder keeps the header and status a handler writes and drops the body
+type statusRecorder struct {
+	header http.Header
+	code   int
+}
+
+func (s *statusRecorder) Header() http.Header {
+	return s.header
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.code = code
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// deprecated marks a response of a legacy unversioned path: Deprecation (RFC 9745), true until its date is
+// configured, a Link to the same path below prefix, and Sunset (RFC 8594) once its date is configured
+func deprecated(legacy domain.LegacyRoutes, prefix string, next http.HandlerFunc) http.HandlerFunc {
+	deprecation, sunset := "true", ""
+	if !legacy.Deprecated.IsZero() {
+		deprecation = "@" + strconv.FormatInt(legacy.Deprecated.Unix(), 10)
+	}
+	if !legacy.Sunset.IsZero() {
+		sunset = legacy.Sunset.UTC().Format(http.TimeFormat)
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != expected {
-			logger2.Log.Error("Method not allowed", zap.String("method", r.Method))
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-			return
+		w.Header().Set("Deprecation", deprecation)
+		if sunset != "" {
+			w.Header().Set("Sunset", sunset)
 		}
+		w.Header().Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", prefix, r.URL.EscapedPath()))
 		next(w, r)
 	}
 }
 
+// actorHeader names the caller, it is only believed from a trusted proxy that authenticated the user
+const actorHeader = "X-Actor"
+
+// actor puts who sent the request into its context for the audit log, with the client address. Only verified
+// names are taken: the common name of a verified client certificate, else X-Actor when the request comes from
+// a trusted proxy. Anything else is anonymous, a name the request claims (X-Actor or a basic auth user, whose
+// password nothing checks) is kept as the claimed actor.
+func actor(trustedProxies []netip.Prefix, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			addr = r.RemoteAddr
+		}
+
+		claimed := r.Header.Get(actorHeader)
+		if user, _, ok := r.BasicAuth(); ok && claimed == "" {
+			claimed = user
+		}
+
+		who := domain.Actor{Name: "anonymous", Claimed: claimed, RemoteAddr: addr}
+		switch {
+		case r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && r.TLS.VerifiedChains[0][0].Subject.CommonName != "":
+			who.Name = r.TLS.VerifiedChains[0][0].Subject.CommonName
+		case r.Header.Get(actorHeader) != "" && trusted(trustedProxies, addr):
+			who.Name = r.Header.Get(actorHeader)
+		}
+		if who.Name == who.Claimed {
+			who.Claimed = ""
+		}
+		next(w, r.WithContext(domain.WithActor(r.Context(), who)))
+	}
+}
+
+// trusted reports whether addr is in one of the proxy ranges
+func trusted(proxies []netip.Prefix, addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 func logger(next http.HandlerFunc) http.HandlerFunc {
 	src := "middleware-logger"
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -57,10 +161,12 @@ func panicRecovery(next http.HandlerFunc) http.HandlerFunc {
 		next(w, r)
 	}
 }
-func ApplyMiddlewares(method string, authType AuthType, next http.HandlerFunc) http.HandlerFunc {
+func ApplyMiddlewares(method string, authType AuthType, trustedProxies []netip.Prefix, next http.HandlerFunc) http.HandlerFunc {
 	// all the middlewares goes here including auth middleware
-	handlers := methodChecker(method, next)
+	// note: the method itself is part of the route pattern, the mux answers a wrong one with a 405
+	handlers := actor(trustedProxies, next)
 	handlers = logger(handlers)
+	handlers = compress(handlers)
 	handlers = panicRecovery(handlers)
 	return handlers
 }
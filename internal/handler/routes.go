@@ -1,9 +1,11 @@
 package handler
 
 import (
-	"fmt"
 	"net/http"
+	"net/netip"
+	"pg-summary-service/internal/domain"
 	service2 "pg-summary-service/internal/service"
+	"time"
 )
 
 type AuthType string
@@ -21,38 +23,285 @@ type Route struct {
 	AuthType AuthType
 }
 
-var service service2.SummaryService
-
-func RegisterRoutes(handler func(pattern string, handler func(http.ResponseWriter, *http.Request)), s service2.SummaryService) {
-	service = s
-	for i, route := range routes {
-		fmt.Println("ith ", i)
-		handler(route.Path, func(method string, handler http.HandlerFunc, authType AuthType) http.HandlerFunc {
-			fmt.Println("Registering route:", route.Path, "with method:", method, "and auth type:", authType)
-
-			// Here you can add middleware for authentication based on authType
-
-			return ApplyMiddlewares(route.Method, route.AuthType, handler)
-		}(route.Method, route.Handler, route.AuthType))
-	}
-	// Catch-all 404 handler for unknown paths
-	handler("/", NotFoundHandler)
-}
-
-var routes = []Route{
-	{
-		Path:    "/summary/sync",
-		Method:  http.MethodPost,
-		Handler: SyncSummaryHandler,
-	},
-	{
-		Path:    "/summaries",
-		Method:  http.MethodGet,
-		Handler: GetSummariesHandler,
-	},
-	{
-		Path:    "/summaries/",
-		Method:  http.MethodGet,
-		Handler: GetSummaryByIDHandler,
-	},
+// Services are the dependencies the handlers are served from
+type Services struct {
+	Summary   *service2.SummaryService
+	Webhooks  *service2.WebhookService
+	Alerts    *service2.AlertService
+	Janitor   *service2.Janitor
+	Cache     CacheStatsReporter // nil when the cache is off
+	Breakers  BreakerReporter
+	Providers ProviderReporter
+	Events    *service2.EventStream // nil turns the event stream off
+	Audit     *service2.AuditLog
+	Legacy    domain.LegacyRoutes // the unversioned paths, off unless Enabled
+	// TrustedProxies are the addresses whose X-Actor header names the caller, none by default
+	TrustedProxies []netip.Prefix
+	// JobStreamTimeout ends a job stream that saw no final event for this long, defaultJobStreamTimeout when zero
+	JobStreamTimeout time.Duration
+}
+
+// APIVersion is one shape of the API served below Prefix. Versions are served side by side, so a /v2 can be
+// added next to /v1 for a transition period, reusing the v1 handlers whose shape did not change.
+type APIVersion struct {
+	Prefix string
+	Routes []Route
+}
+
+// legacyVersion is the version the unversioned paths are aliases of
+const legacyVersion = "/v1"
+
+// CacheStatsReporter reports the counters of the local repository cache
+type CacheStatsReporter interface {
+	Stats() domain.CacheStats
+}
+
+// ProviderReporter reports the request counts and latency of every external summary provider
+type ProviderReporter interface {
+	ProviderStats() []domain.ProviderStats
+}
+
+// BreakerReporter reports the circuit breakers of the external summary API
+type BreakerReporter interface {
+	BreakerStatus() []domain.BreakerStatus
+}
+
+// Server serves the API from its dependencies, it holds no package state so several can run in one process
+type Server struct {
+	service   *service2.SummaryService
+	webhooks  *service2.WebhookService
+	alerts    *service2.AlertService
+	janitor   *service2.Janitor
+	cache     CacheStatsReporter
+	breakers  BreakerReporter
+	providers ProviderReporter
+	events    *service2.EventStream
+	audit     *service2.AuditLog
+	mux       *http.ServeMux
+
+	jobStreamTimeout time.Duration
+}
+
+// NewServer registers every route of every version as a method and path pattern, e.g. GET /v1/summaries/{id}
+func NewServer(s Services) *Server {
+	srv := &Server{
+		service:   s.Summary,
+		webhooks:  s.Webhooks,
+		alerts:    s.Alerts,
+		janitor:   s.Janitor,
+		cache:     s.Cache,
+		breakers:  s.Breakers,
+		providers: s.Providers,
+		events:    s.Events,
+		audit:     s.Audit,
+		mux:       http.NewServeMux(),
+
+		jobStreamTimeout: s.JobStreamTimeout,
+	}
+	if srv.jobStreamTimeout <= 0 {
+		srv.jobStreamTimeout = defaultJobStreamTimeout
+	}
+	for _, version := range srv.versions() {
+		for _, route := range version.Routes {
+			next := ApplyMiddlewares(route.Method, route.AuthType, s.TrustedProxies, route.Handler)
+			srv.mux.Handle(route.Method+" "+version.Prefix+route.Path, next)
+			if version.Prefix == legacyVersion && s.Legacy.Enabled {
+				srv.mux.Handle(route.Method+" "+route.Path, deprecated(s.Legacy, version.Prefix, next))
+			}
+		}
+	}
+	for _, route := range srv.operationalRoutes() {
+		srv.mux.Handle(route.Method+" "+route.Path, ApplyMiddlewares(route.Method, route.AuthType, s.TrustedProxies, route.Handler))
+	}
+	return srv
+}
+
+// versions are the served API versions, each with its own route table
+func (s *Server) versions() []APIVersion {
+	return []APIVersion{
+		{Prefix: "/v1", Routes: s.routes()},
+	}
+}
+
+// ServeHTTP routes r, a path without a route gets a JSON 404 and a route without the method a JSON 405 with Allow
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := s.mux.Handler(r); pattern != "" {
+		s.mux.ServeHTTP(w, r)
+		return
+	}
+	noRoute(s.mux, w, r)
+}
+
+// routes are the v1 routes, paths are relative to the version prefix
+func (s *Server) routes() []Route {
+	return []Route{
+		{
+			Path:    "/summary/sync",
+			Method:  http.MethodPost,
+			Handler: s.SyncSummaryHandler,
+		},
+		{
+			Path:    "/summaries",
+			Method:  http.MethodGet,
+			Handler: s.GetSummariesHandler,
+		},
+		{
+			// not under /summaries, a summary stored with the id export would be unreachable
+			Path:    "/exports/summaries",
+			Method:  http.MethodGet,
+			Handler: s.ExportSummariesHandler,
+		},
+		{
+			Path:    "/summaries/{id}",
+			Method:  http.MethodGet,
+			Handler: s.GetSummaryByIDHandler,
+		},
+		{
+			Path:    "/summaries/{id}",
+			Method:  http.MethodDelete,
+			Handler: s.DeleteSummaryHandler,
+		},
+		{
+			Path:    "/summaries/{id}/tables",
+			Method:  http.MethodGet,
+			Handler: s.GetSummaryTablesHandler,
+		},
+		{
+			Path:    "/summaries/{id}/tables/{tableId}/columns",
+			Method:  http.MethodGet,
+			Handler: s.GetTableColumnsHandler,
+		},
+		{
+			Path:    "/summaries/{id}/indexes",
+			Method:  http.MethodGet,
+			Handler: s.GetSummaryIndexesHandler,
+		},
+		{
+			Path:    "/summaries/{id}/indexes/report",
+			Method:  http.MethodGet,
+			Handler: s.IndexReportHandler,
+		},
+		{
+			Path:    "/events",
+			Method:  http.MethodGet,
+			Handler: s.StreamEventsHandler,
+		},
+		{
+			Path:    "/jobs/{id}/events",
+			Method:  http.MethodGet,
+			Handler: s.StreamJobEventsHandler,
+		},
+		{
+			Path:    "/webhooks",
+			Method:  http.MethodGet,
+			Handler: s.ListWebhooksHandler,
+		},
+		{
+			Path:    "/webhooks",
+			Method:  http.MethodPost,
+			Handler: s.CreateWebhookHandler,
+		},
+		{
+			Path:    "/webhooks/{id}",
+			Method:  http.MethodGet,
+			Handler: s.GetWebhookHandler,
+		},
+		{
+			Path:    "/webhooks/{id}",
+			Method:  http.MethodPut,
+			Handler: s.UpdateWebhookHandler,
+		},
+		{
+			Path:    "/webhooks/{id}",
+			Method:  http.MethodDelete,
+			Handler: s.DeleteWebhookHandler,
+		},
+		{
+			Path:    "/webhooks/{id}/deliveries",
+			Method:  http.MethodGet,
+			Handler: s.ListWebhookDeliveriesHandler,
+		},
+		{
+			Path:    "/alert-rules",
+			Method:  http.MethodGet,
+			Handler: s.ListAlertRulesHandler,
+		},
+		{
+			Path:    "/alert-rules",
+			Method:  http.MethodPost,
+			Handler: s.CreateAlertRuleHandler,
+		},
+		{
+			Path:    "/alert-rules/{id}",
+			Method:  http.MethodGet,
+			Handler: s.GetAlertRuleHandler,
+		},
+		{
+			Path:    "/alert-rules/{id}",
+			Method:  http.MethodPut,
+			Handler: s.UpdateAlertRuleHandler,
+		},
+		{
+			Path:    "/alert-rules/{id}",
+			Method:  http.MethodDelete,
+			Handler: s.DeleteAlertRuleHandler,
+		},
+		{
+			Path:    "/alerts",
+			Method:  http.MethodGet,
+			Handler: s.ListAlertsHandler,
+		},
+		{
+			Path:    "/alerts/{id}/ack",
+			Method:  http.MethodPost,
+			Handler: s.AcknowledgeAlertHandler,
+		},
+		{
+			Path:    "/audit",
+			Method:  http.MethodGet,
+			Handler: s.ListAuditHandler,
+		},
+	}
+}
+
+// operationalRoutes are for operators and scrapers, not API clients. They are served without a version prefix
+// and never carry deprecation headers.
+func (s *Server) operationalRoutes() []Route {
+	return []Route{
+		{
+			Path:    "/metrics",
+			Method:  http.MethodGet,
+			Handler: s.ServiceMetricsHandler,
+		},
+		{
+			Path:    "/metrics/summaries",
+			Method:  http.MethodGet,
+			Handler: s.SummaryMetricsHandler,
+		},
+		{
+			Path:    "/admin/retention",
+			Method:  http.MethodGet,
+			Handler: s.GetRetentionHandler,
+		},
+		{
+			Path:    "/admin/retention/run",
+			Method:  http.MethodPost,
+			Handler: s.RunRetentionHandler,
+		},
+		{
+			Path:    "/admin/cache",
+			Method:  http.MethodGet,
+			Handler: s.GetCacheStatsHandler,
+		},
+		{
+			Path:    "/admin/breakers",
+			Method:  http.MethodGet,
+			Handler: s.GetBreakersHandler,
+		},
+		{
+			Path:    "/admin/providers",
+			Method:  http.MethodGet,
+			Handler: s.GetProvidersHandler,
+		},
+	}
 }
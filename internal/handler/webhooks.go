@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"go.uber.org/zap"
+	"net/http"
+	"pg-summary-service/internal/domain"
+	logger1 "pg-summary-service/internal/logger"
+	"pg-summary-service/internal/utils"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500 // a larger limit is cut down to this
+)
+
+func (s *Server) ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if resp, err := s.webhooks.ListWebhooks(r.Context()); err != nil {
+		logger1.Log.Error("error at ListWebhooksHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	} else {
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func (s *Server) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req domain.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger1.Log.Error("Error decoding request", zap.Error(err))
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := s.webhooks.CreateWebhook(r.Context(), req)
+	if err != nil {
+		logger1.Log.Error("error at CreateWebhookHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(hook)
+}
+
+func (s *Server) GetWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	w.Header().Set("Content-Type", "application/json")
+
+	if resp, err := s.webhooks.GetWebhook(r.Context(), id); err != nil {
+		logger1.Log.Error("error at GetWebhookHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	} else {
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func (s *Server) UpdateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	w.Header().Set("Content-Type", "application/json")
+
+	var req domain.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger1.Log.Error("Error decoding request", zap.Error(err))
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if resp, err := s.webhooks.UpdateWebhook(r.Context(), id, req); err != nil {
+		logger1.Log.Error("error at UpdateWebhookHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	} else {
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func (s *Server) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := s.webhooks.DeleteWebhook(r.Context(), id); err != nil {
+		logger1.Log.Error("error at DeleteWebhookHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) ListWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	w.Header().Set("Content-Type", "application/json")
+
+	offset := utils.ParseQueryInt(r, "offset", 0)
+	limit := utils.ParseQueryInt(r, "limit", defaultDeliveriesLimit)
+	if offset < 0 || limit < 1 {
+		utils.SendError(w, domain.NewBadRequestError("offset cannot be negative and limit must be at least 1"))
+		return
+	}
+	limit = min(limit, maxDeliveriesLimit)
+
+	if resp, err := s.webhooks.ListDeliveries(r.Context(), id, offset, limit); err != nil {
+		logger1.Log.Error("error at ListWebhookDeliveriesHandler handler", zap.Error(err))
+		utils.SendError(w, err)
+		return
+	} else {
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"io"
+	"pg-summary-service/internal/domain"
+)
+
+// breakerStateValue encodes a breaker state as a gauge value
+var breakerStateValue = map[domain.BreakerState]float64{
+	domain.BreakerClosed:   0,
+	domain.BreakerHalfOpen: 1,
+	domain.BreakerOpen:     2,
+}
+
+// WriteBreakerGauges renders the circuit breaker of every external endpoint
+func WriteBreakerGauges(w io.Writer, breakers []domain.BreakerStatus) error {
+	mw := NewWriter(w)
+
+	mw.Family("pg_summary_external_breaker_state", "Circuit breaker state of an external endpoint: 0 closed, 1 half-open, 2 open.", "gauge")
+	for _, b := range breakers {
+		mw.Sample("pg_summary_external_breaker_state", []Label{{Name: "endpoint", Value: b.Endpoint}}, breakerStateValue[b.State])
+	}
+
+	mw.Family("pg_summary_external_breaker_opens_total", "Times the circuit breaker of an external endpoint opened.", "counter")
+	for _, b := range breakers {
+		mw.Sample("pg_summary_external_breaker_opens_total", []Label{{Name: "endpoint", Value: b.Endpoint}}, float64(b.Opens))
+	}
+
+	mw.Family("pg_summary_external_breaker_rejected_total", "Calls failed fast because the circuit breaker was open.", "counter")
+	for _, b := range breakers {
+		mw.Sample("pg_summary_external_breaker_rejected_total", []Label{{Name: "endpoint", Value: b.Endpoint}}, float64(b.Rejected))
+	}
+
+	return mw.Err()
+}
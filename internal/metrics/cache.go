@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"io"
+	"pg-summary-service/internal/domain"
+)
+
+// WriteCacheCounters renders the counters of the local repository cache
+func WriteCacheCounters(w io.Writer, stats domain.CacheStats) error {
+	mw := NewWriter(w)
+
+	mw.Family("pg_summary_cache_hits_total", "Lookups served from the summary cache.", "counter")
+	mw.Sample("pg_summary_cache_hits_total", nil, float64(stats.Hits))
+
+	mw.Family("pg_summary_cache_misses_total", "Lookups that went to the local repository.", "counter")
+	mw.Sample("pg_summary_cache_misses_total", nil, float64(stats.Misses))
+
+	mw.Family("pg_summary_cache_evictions_total", "Entries evicted to stay within the cache bounds.", "counter")
+	mw.Sample("pg_summary_cache_evictions_total", nil, float64(stats.Evictions))
+
+	mw.Family("pg_summary_cache_entries", "Entries currently cached.", "gauge")
+	mw.Sample("pg_summary_cache_entries", nil, float64(stats.Entries))
+
+	mw.Family("pg_summary_cache_bytes", "Estimated size of the cached entries in bytes.", "gauge")
+	mw.Sample("pg_summary_cache_bytes", nil, float64(stats.Bytes))
+
+	return mw.Err()
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Writer renders metrics in the Prometheus text exposition format.
+// note: samples of one family must be written right after its Family call
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Family writes the HELP and TYPE lines, kind is "gauge" or "counter"
+func (m *Writer) Family(name, help, kind string) {
+	m.printf("# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, kind)
+}
+
+func (m *Writer) Sample(name string, labels []Label, value float64) {
+	if len(labels) == 0 {
+		m.printf("%s %s\n", name, formatValue(value))
+		return
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for _, l := range labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, l.Name, escapeLabel(l.Value)))
+	}
+	m.printf("%s{%s} %s\n", name, strings.Join(pairs, ","), formatValue(value))
+}
+
+// Err returns the first write error, later writes are skipped once one failed
+func (m *Writer) Err() error {
+	return m.err
+}
+
+func (m *Writer) printf(format string, args ...any) {
+	if m.err != nil {
+		return
+	}
+	_, m.err = fmt.Fprintf(m.w, format, args...)
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func escapeHelp(v string) string {
+	return helpEscaper.Replace(v)
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"io"
+	"pg-summary-service/internal/domain"
+)
+
+// WriteProviderCounters renders the requests and latency of every external summary provider
+func WriteProviderCounters(w io.Writer, providers []domain.ProviderStats) error {
+	mw := NewWriter(w)
+	labels := func(p domain.ProviderStats) []Label {
+		return []Label{{Name: "provider", Value: p.Name}, {Name: "region", Value: p.Region}}
+	}
+
+	mw.Family("pg_summary_external_provider_requests_total", "Calls made to an external summary provider.", "counter")
+	for _, p := range providers {
+		mw.Sample("pg_summary_external_provider_requests_total", labels(p), float64(p.Requests))
+	}
+
+	mw.Family("pg_summary_external_provider_failures_total", "Calls to an external summary provider that failed.", "counter")
+	for _, p := range providers {
+		mw.Sample("pg_summary_external_provider_failures_total", labels(p), float64(p.Failures))
+	}
+
+	mw.Family("pg_summary_external_provider_failovers_total", "Failures after which the next provider was tried.", "counter")
+	for _, p := range providers {
+		mw.Sample("pg_summary_external_provider_failovers_total", labels(p), float64(p.Failovers))
+	}
+
+	mw.Family("pg_summary_external_provider_latency_avg_ms", "Average latency of the calls to an external summary provider.", "gauge")
+	for _, p := range providers {
+		mw.Sample("pg_summary_external_provider_latency_avg_ms", labels(p), p.AvgLatencyMs)
+	}
+
+	return mw.Err()
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"io"
+	"pg-summary-service/internal/domain"
+	"sort"
+)
+
+// Limits bounds the number of table level series, some sources have thousands of tables.
+// Zero means unlimited.
+type Limits struct {
+	MaxTablesPerSource int
+	MaxTableSeries     int
+}
+
+// WriteSummaryGauges renders the latest summary of every source as gauges.
+// When a limit is hit the largest tables are kept and the rest is counted in pg_summary_tables_dropped.
+func WriteSummaryGauges(w io.Writer, snapshots []domain.SourceSnapshot, limits Limits) error {
+	kept := make([][]domain.SummaryTable, len(snapshots))
+	dropped := make([]int, len(snapshots))
+	budget := limits.MaxTableSeries
+
+	for i, snap := range snapshots {
+		tables := make([]domain.SummaryTable, len(snap.Tables))
+		copy(tables, snap.Tables)
+		sort.SliceStable(tables, func(a, b int) bool {
+			return tables[a].SizeMB > tables[b].SizeMB
+		})
+
+		n := len(tables)
+		if limits.MaxTablesPerSource > 0 && n > limits.MaxTablesPerSource {
+			n = limits.MaxTablesPerSource
+		}
+		if limits.MaxTableSeries > 0 {
+			if n > budget {
+				n = budget
+			}
+			budget -= n
+		}
+		kept[i] = tables[:n]
+		dropped[i] = len(tables) - n
+	}
+
+	mw := NewWriter(w)
+
+	mw.Family("pg_summary_last_sync_timestamp", "Unix time of the latest stored summary of a source.", "gauge")
+	for _, snap := range snapshots {
+		mw.Sample("pg_summary_last_sync_timestamp", sourceLabels(snap), float64(snap.SyncedAt.Unix()))
+	}
+
+	mw.Family("pg_summary_table_rows", "Row count of a table in the latest summary of its source.", "gauge")
+	for i, snap := range snapshots {
+		for _, table := range kept[i] {
+			mw.Sample("pg_summary_table_rows", tableLabels(snap, table), float64(table.TotalRows))
+		}
+	}
+
+	mw.Family("pg_summary_table_size_mb", "Size in MB of a table in the latest summary of its source.", "gauge")
+	for i, snap := range snapshots {
+		for _, table := range kept[i] {
+			mw.Sample("pg_summary_table_size_mb", tableLabels(snap, table), table.SizeMB)
+		}
+	}
+
+	mw.Family("pg_summary_tables_dropped", "Tables left out of the table gauges because of cardinality limits.", "gauge")
+	for i, snap := range snapshots {
+		mw.Sample("pg_summary_tables_dropped", sourceLabels(snap), float64(dropped[i]))
+	}
+
+	return mw.Err()
+}
+
+func sourceLabels(snap domain.SourceSnapshot) []Label {
+	return []Label{{Name: "source", Value: snap.Source}}
+}
+
+func tableLabels(snap domain.SourceSnapshot, table domain.SummaryTable) []Label {
+	return []Label{
+		{Name: "source", Value: snap.Source},
+		{Name: "schema", Value: table.Schema},
+		{Name: "table", Value: table.Name},
+	}
+}
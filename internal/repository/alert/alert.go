@@ -0,0 +1,223 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/logger"
+	"strings"
+	"time"
+)
+
+type AlertRepository struct {
+	db *pgxpool.Pool
+}
+
+// CreateTables creates the tables the repository works on, if missing
+func CreateTables(ctx context.Context, db *pgxpool.Pool) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS alert_rules (id VARCHAR PRIMARY KEY, name VARCHAR NOT NULL, kind VARCHAR NOT NULL, source VARCHAR NOT NULL DEFAULT '', schema_name VARCHAR NOT NULL DEFAULT '', table_name VARCHAR NOT NULL DEFAULT '', threshold FLOAT NOT NULL, routes TEXT[] NOT NULL, enabled BOOLEAN NOT NULL, created_at TIMESTAMP)`,
+		`CREATE TABLE IF NOT EXISTS alerts (id VARCHAR PRIMARY KEY, rule_id VARCHAR NOT NULL, rule_name VARCHAR NOT NULL, kind VARCHAR NOT NULL, summary_id VARCHAR NOT NULL, source VARCHAR NOT NULL, schema_name VARCHAR NOT NULL DEFAULT '', table_name VARCHAR NOT NULL DEFAULT '', value FLOAT, threshold FLOAT, message VARCHAR, fired_at TIMESTAMP, acknowledged BOOLEAN NOT NULL DEFAULT FALSE, acknowledged_at TIMESTAMP, acknowledged_by VARCHAR NOT NULL DEFAULT '')`,
+		`CREATE INDEX IF NOT EXISTS alerts_fired_at_idx ON alerts (fired_at DESC)`,
+	}
+
+	for _, q := range queries {
+		if _, err := db.Exec(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewAlertRepository(db *pgxpool.Pool) *AlertRepository {
+	return &AlertRepository{db: db}
+}
+
+const ruleColumns = `id, name, kind, source, schema_name, table_name, threshold, routes, enabled, created_at`
+
+const alertColumns = `id, rule_id, rule_name, kind, summary_id, source, schema_name, table_name, value, threshold,
+	message, fired_at, acknowledged, acknowledged_at, acknowledged_by`
+
+func (aRepo *AlertRepository) AddRule(ctx context.Context, rule *domain.AlertRule) error {
+	query := `INSERT INTO alert_rules (` + ruleColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err := aRepo.db.Exec(ctx, query, rule.Id, rule.Name, string(rule.Kind), rule.Source, rule.Schema, rule.Table,
+		rule.Threshold, routesToStrings(rule.Routes), rule.Enabled, rule.CreatedAt)
+	if err != nil {
+		logger.Log.Error("error while saving alert rule", zap.Error(err), zap.Any("rule", rule))
+		return domain.HandlePGError(err)
+	}
+	return nil
+}
+
+func (aRepo *AlertRepository) GetRule(ctx context.Context, id string) (*domain.AlertRule, error) {
+	query := `SELECT ` + ruleColumns + ` FROM alert_rules WHERE id = $1`
+	rule, err := scanRule(aRepo.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.NewNotFoundError(fmt.Sprintf("alert rule with id %s not found", id))
+	} else if err != nil {
+		logger.Log.Error("error while fetching alert rule", zap.Error(err), zap.String("rule id", id))
+		return nil, domain.HandlePGError(err)
+	}
+	return rule, nil
+}
+
+func (aRepo *AlertRepository) ListRules(ctx context.Context) ([]domain.AlertRule, error) {
+	rows, err := aRepo.db.Query(ctx, `SELECT `+ruleColumns+` FROM alert_rules ORDER BY created_at`)
+	if err != nil {
+		logger.Log.Error("error while fetching alert rules", zap.Error(err))
+		return nil, domain.HandlePGError(err)
+	}
+	defer rows.Close()
+
+	rules := []domain.AlertRule{}
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			logger.Log.Error("error while s-caning alert rules", zap.Error(err))
+			return nil, domain.HandlePGError(err)
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, nil
+}
+
+func (aRepo *AlertRepository) UpdateRule(ctx context.Context, rule *domain.AlertRule) error {
+	query := `UPDATE alert_rules
+	          SET name = $2, kind = $3, source = $4, schema_name = $5, table_name = $6, threshold = $7, routes = $8, enabled = $9
+	          WHERE id = $1`
+	tag, err := aRepo.db.Exec(ctx, query, rule.Id, rule.Name, string(rule.Kind), rule.Source, rule.Schema, rule.Table,
+		rule.Threshold, routesToStrings(rule.Routes), rule.Enabled)
+	if err != nil {
+		logger.Log.Error("error while updating alert rule", zap.Error(err), zap.String("rule id", rule.Id))
+		return domain.HandlePGError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.NewNotFoundError(fmt.Sprintf("alert rule with id %s not found", rule.Id))
+	}
+	return nil
+}
+
+func (aRepo *AlertRepository) DeleteRule(ctx context.Context, id string) error {
+	// alerts already fired keep rule id and name, so the history stays readable
+	tag, err := aRepo.db.Exec(ctx, `DELETE FROM alert_rules WHERE id = $1`, id)
+	if err != nil {
+		logger.Log.Error("error while deleting alert rule", zap.Error(err), zap.String("rule id", id))
+		return domain.HandlePGError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.NewNotFoundError(fmt.Sprintf("alert rule with id %s not found", id))
+	}
+	return nil
+}
+
+func (aRepo *AlertRepository) AddAlert(ctx context.Context, a *domain.Alert) error {
+	query := `INSERT INTO alerts (` + alertColumns + `)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
+	_, err := aRepo.db.Exec(ctx, query, a.Id, a.RuleId, a.RuleName, string(a.Kind), a.SummaryId, a.Source, a.Schema, a.Table,
+		a.Value, a.Threshold, a.Message, a.FiredAt, a.Acknowledged, a.AcknowledgedAt, a.AcknowledgedBy)
+	if err != nil {
+		logger.Log.Error("error while saving alert", zap.Error(err), zap.Any("alert", a))
+		return domain.HandlePGError(err)
+	}
+	return nil
+}
+
+func (aRepo *AlertRepository) ListAlerts(ctx context.Context, filter domain.AlertFilter) ([]domain.Alert, error) {
+	var (
+		where []string
+		args  []any
+	)
+	if filter.Source != "" {
+		args = append(args, filter.Source)
+		where = append(where, fmt.Sprintf("source = $%d", len(args)))
+	}
+	if filter.RuleId != "" {
+		args = append(args, filter.RuleId)
+		where = append(where, fmt.Sprintf("rule_id = $%d", len(args)))
+	}
+	if filter.Acknowledged != nil {
+		args = append(args, *filter.Acknowledged)
+		where = append(where, fmt.Sprintf("acknowledged = $%d", len(args)))
+	}
+
+	query := `SELECT ` + alertColumns + ` FROM alerts`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(` ORDER BY fired_at DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := aRepo.db.Query(ctx, query, args...)
+	if err != nil {
+		logger.Log.Error("error while fetching alerts", zap.Error(err), zap.Any("filter", filter))
+		return nil, domain.HandlePGError(err)
+	}
+	defer rows.Close()
+
+	alerts := []domain.Alert{}
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			logger.Log.Error("error while s-caning alerts", zap.Error(err))
+			return nil, domain.HandlePGError(err)
+		}
+		alerts = append(alerts, *a)
+	}
+	return alerts, nil
+}
+
+func (aRepo *AlertRepository) AcknowledgeAlert(ctx context.Context, id string, by string, at time.Time) (*domain.Alert, error) {
+	query := `UPDATE alerts SET acknowledged = TRUE, acknowledged_at = $2, acknowledged_by = $3
+	          WHERE id = $1
+	          RETURNING ` + alertColumns
+	a, err := scanAlert(aRepo.db.QueryRow(ctx, query, id, at, by))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.NewNotFoundError(fmt.Sprintf("alert with id %s not found", id))
+	} else if err != nil {
+		logger.Log.Error("error while acknowledging alert", zap.Error(err), zap.String("alert id", id))
+		return nil, domain.HandlePGError(err)
+	}
+	return a, nil
+}
+
+func scanRule(row pgx.Row) (*domain.AlertRule, error) {
+	var (
+		rule   domain.AlertRule
+		kind   string
+		routes []string
+	)
+	if err := row.Scan(&rule.Id, &rule.Name, &kind, &rule.Source, &rule.Schema, &rule.Table, &rule.Threshold,
+		&routes, &rule.Enabled, &rule.CreatedAt); err != nil {
+		return nil, err
+	}
+	rule.Kind = domain.AlertRuleKind(kind)
+	for _, r := range routes {
+		rule.Routes = append(rule.Routes, domain.AlertRoute(r))
+	}
+	return &rule, nil
+}
+
+func scanAlert(row pgx.Row) (*domain.Alert, error) {
+	var (
+		a    domain.Alert
+		kind string
+	)
+	if err := row.Scan(&a.Id, &a.RuleId, &a.RuleName, &kind, &a.SummaryId, &a.Source, &a.Schema, &a.Table, &a.Value,
+		&a.Threshold, &a.Message, &a.FiredAt, &a.Acknowledged, &a.AcknowledgedAt, &a.AcknowledgedBy); err != nil {
+		return nil, err
+	}
+	a.Kind = domain.AlertRuleKind(kind)
+	return &a, nil
+}
+
+func routesToStrings(routes []domain.AlertRoute) []string {
+	out := make([]string, 0, len(routes))
+	for _, r := range routes {
+		out = append(out, string(r))
+	}
+	return out
+}
@@ -0,0 +1,18 @@
+package alert
+
+import (
+	"context"
+	"pg-summary-service/internal/domain"
+	"time"
+)
+
+type Store interface {
+	AddRule(ctx context.Context, rule *domain.AlertRule) error
+	GetRule(ctx context.Context, id string) (*domain.AlertRule, error)
+	ListRules(ctx context.Context) ([]domain.AlertRule, error)
+	UpdateRule(ctx context.Context, rule *domain.AlertRule) error
+	DeleteRule(ctx context.Context, id string) error
+	AddAlert(ctx context.Context, alert *domain.Alert) error
+	ListAlerts(ctx context.Context, filter domain.AlertFilter) ([]domain.Alert, error)
+	AcknowledgeAlert(ctx context.Context, id string, by string, at time.Time) (*domain.Alert, error)
+}
@@ -0,0 +1,124 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"pg-summary-service/internal/domain"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is a thread-safe in-memory Store, used with STORAGE=memory
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	rules  map[string]domain.AlertRule
+	alerts []domain.Alert
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{rules: map[string]domain.AlertRule{}}
+}
+
+func (mRepo *MemoryRepository) AddRule(ctx context.Context, rule *domain.AlertRule) error {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	if _, ok := mRepo.rules[rule.Id]; ok {
+		return domain.NewBadRequestError("duplicate entry, already exists")
+	}
+	mRepo.rules[rule.Id] = *rule
+	return nil
+}
+
+func (mRepo *MemoryRepository) GetRule(ctx context.Context, id string) (*domain.AlertRule, error) {
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+	rule, ok := mRepo.rules[id]
+	if !ok {
+		return nil, domain.NewNotFoundError(fmt.Sprintf("alert rule with id %s not found", id))
+	}
+	return &rule, nil
+}
+
+func (mRepo *MemoryRepository) ListRules(ctx context.Context) ([]domain.AlertRule, error) {
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+	rules := make([]domain.AlertRule, 0, len(mRepo.rules))
+	for _, rule := range mRepo.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(a, b int) bool {
+		return rules[a].CreatedAt.Before(rules[b].CreatedAt)
+	})
+	return rules, nil
+}
+
+func (mRepo *MemoryRepository) UpdateRule(ctx context.Context, rule *domain.AlertRule) error {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	if _, ok := mRepo.rules[rule.Id]; !ok {
+		return domain.NewNotFoundError(fmt.Sprintf("alert rule with id %s not found", rule.Id))
+	}
+	mRepo.rules[rule.Id] = *rule
+	return nil
+}
+
+func (mRepo *MemoryRepository) DeleteRule(ctx context.Context, id string) error {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	if _, ok := mRepo.rules[id]; !ok {
+		return domain.NewNotFoundError(fmt.Sprintf("alert rule with id %s not found", id))
+	}
+	delete(mRepo.rules, id)
+	return nil
+}
+
+func (mRepo *MemoryRepository) AddAlert(ctx context.Context, alert *domain.Alert) error {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	mRepo.alerts = append(mRepo.alerts, *alert)
+	return nil
+}
+
+func (mRepo *MemoryRepository) ListAlerts(ctx context.Context, filter domain.AlertFilter) ([]domain.Alert, error) {
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+
+	// newest first, alerts are appended in time order
+	matching := []domain.Alert{}
+	for i := len(mRepo.alerts) - 1; i >= 0; i-- {
+		a := mRepo.alerts[i]
+		if filter.Source != "" && a.Source != filter.Source {
+			continue
+		}
+		if filter.RuleId != "" && a.RuleId != filter.RuleId {
+			continue
+		}
+		if filter.Acknowledged != nil && a.Acknowledged != *filter.Acknowledged {
+			continue
+		}
+		matching = append(matching, a)
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	if filter.Offset >= len(matching) {
+		return []domain.Alert{}, nil
+	}
+	return matching[filter.Offset:min(filter.Offset+filter.Limit, len(matching))], nil
+}
+
+func (mRepo *MemoryRepository) AcknowledgeAlert(ctx context.Context, id string, by string, at time.Time) (*domain.Alert, error) {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	for i := range mRepo.alerts {
+		if mRepo.alerts[i].Id == id {
+			mRepo.alerts[i].Acknowledged = true
+			mRepo.alerts[i].AcknowledgedAt = &at
+			mRepo.alerts[i].AcknowledgedBy = by
+			a := mRepo.alerts[i]
+			return &a, nil
+		}
+	}
+	return nil, domain.NewNotFoundError(fmt.Sprintf("alert with id %s not found", id))
+}
@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/logger"
+	"strings"
+)
+
+type AuditRepository struct {
+	db *pgxpool.Pool
+}
+
+// CreateTables creates the audit_events table, if missing, with a trigger rejecting updates and deletes
+func CreateTables(ctx context.Context, db *pgxpool.Pool) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS audit_events (id VARCHAR PRIMARY KEY, at TIMESTAMP NOT NULL, actor VARCHAR NOT NULL, remote_addr VARCHAR NOT NULL DEFAULT '', action VARCHAR NOT NULL, target VARCHAR NOT NULL, db_user VARCHAR NOT NULL DEFAULT '', summary_id VARCHAR NOT NULL DEFAULT '', job_id VARCHAR NOT NULL DEFAULT '', outcome VARCHAR NOT NULL, duration_ms BIGINT NOT NULL, error_class VARCHAR NOT NULL DEFAULT '')`,
+		`ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS claimed_actor VARCHAR NOT NULL DEFAULT ''`,
+		`CREATE INDEX IF NOT EXISTS audit_events_at_idx ON audit_events (at DESC)`,
+		`CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+		 BEGIN
+		     RAISE EXCEPTION 'audit_events is append-only';
+		 END
+		 $$ LANGUAGE plpgsql`,
+		`DO $$
+		 BEGIN
+		     IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'audit_events_append_only') THEN
+		         CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_events
+		         FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+		     END IF;
+		 END
+		 $$`,
+	}
+
+	for _, q := range queries {
+		if _, err := db.Exec(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewAuditRepository(db *pgxpool.Pool) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+const eventColumns = `id, at, actor, claimed_actor, remote_addr, action, target, db_user, summary_id, job_id, outcome, duration_ms, error_class`
+
+func (aRepo *AuditRepository) AddEvent(ctx context.Context, e *domain.AuditEvent) error {
+	query := `INSERT INTO audit_events (` + eventColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	// at is a TIMESTAMP without zone, pgx drops the location so every time goes in as UTC
+	_, err := aRepo.db.Exec(ctx, query, e.Id, e.At.UTC(), e.Actor, e.ClaimedActor, e.RemoteAddr, e.Action, e.Target, e.DBUser, e.SummaryId,
+		e.JobId, string(e.Outcome), e.DurationMs, e.ErrorClass)
+	if err != nil {
+		logger.Log.Error("error while saving audit event", zap.Error(err), zap.String("action", e.Action), zap.String("target", e.Target))
+		return domain.HandlePGError(err)
+	}
+	return nil
+}
+
+func (aRepo *AuditRepository) StreamEvents(ctx context.Context, filter domain.AuditFilter, fn func(domain.AuditEvent) error) error {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, value any) {
+		args = append(args, value)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.Actor != "" {
+		add("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.Target != "" {
+		add("target = $%d", filter.Target)
+	}
+	if filter.Outcome != "" {
+		add("outcome = $%d", string(filter.Outcome))
+	}
+	if filter.Since != nil {
+		add("at >= $%d", filter.Since.UTC())
+	}
+	if filter.Until != nil {
+		add("at < $%d", filter.Until.UTC())
+	}
+
+	query := `SELECT ` + eventColumns + ` FROM audit_events`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY at DESC, id`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+	args = append(args, max(filter.Offset, 0))
+	query += fmt.Sprintf(` OFFSET $%d`, len(args))
+
+	rows, err := aRepo.db.Query(ctx, query, args...)
+	if err != nil {
+		logger.Log.Error("error while fetching audit events", zap.Error(err), zap.Any("filter", filter))
+		return domain.HandlePGError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			e       domain.AuditEvent
+			outcome string
+		)
+		if err = rows.Scan(&e.Id, &e.At, &e.Actor, &e.ClaimedActor, &e.RemoteAddr, &e.Action, &e.Target, &e.DBUser, &e.SummaryId,
+			&e.JobId, &outcome, &e.DurationMs, &e.ErrorClass); err != nil {
+			logger.Log.Error("error while s-caning audit events", zap.Error(err))
+			return domain.HandlePGError(err)
+		}
+		e.Outcome = domain.AuditOutcome(outcome)
+		if err = fn(e); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		logger.Log.Error("error while iterating audit events", zap.Error(err))
+		return domain.HandlePGError(err)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package audit
+
+import (
+	"context"
+	"pg-summary-service/internal/domain"
+)
+
+// Store is append-only, audit events are never updated or deleted
+type Store interface {
+	AddEvent(ctx context.Context, event *domain.AuditEvent) error
+	// StreamEvents hands the matching events to fn newest first
+	StreamEvents(ctx context.Context, filter domain.AuditFilter, fn func(domain.AuditEvent) error) error
+}
@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"context"
+	"pg-summary-service/internal/domain"
+	"sync"
+)
+
+// MemoryRepository is a thread-safe in-memory Store, used with STORAGE=memory
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	events []domain.AuditEvent
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{}
+}
+
+func (mRepo *MemoryRepository) AddEvent(ctx context.Context, event *domain.AuditEvent) error {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	mRepo.events = append(mRepo.events, *event)
+	return nil
+}
+
+func (mRepo *MemoryRepository) StreamEvents(ctx context.Context, filter domain.AuditFilter, fn func(domain.AuditEvent) error) error {
+	// copy the matches so fn runs without the lock, events are appended in time order
+	mRepo.mu.RLock()
+	var matching []domain.AuditEvent
+	for i := len(mRepo.events) - 1; i >= 0; i-- {
+		if filter.Matches(mRepo.events[i]) {
+			matching = append(matching, mRepo.events[i])
+		}
+	}
+	mRepo.mu.RUnlock()
+
+	if filter.Offset > 0 {
+		matching = matching[min(filter.Offset, len(matching)):]
+	}
+	if filter.Limit > 0 {
+		matching = matching[:min(filter.Limit, len(matching))]
+	}
+	for _, event := range matching {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package external
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"net/url"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/logger"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker stops calling an endpoint after consecutive failures, so a dead upstream fails fast
+// instead of tying up a handler for the whole retry loop.
+// closed -> open after FailureThreshold failures, open -> half-open after CoolDown,
+// half-open -> closed on a successful trial call or back to open on a failed one.
+type CircuitBreaker struct {
+	endpoint string
+	conf     domain.BreakerConfig
+
+	mu       sync.Mutex
+	state    domain.BreakerState
+	failures int
+	openedAt time.Time
+	inFlight int // trial calls while half-open
+	opens    uint64
+	rejected uint64
+}
+
+func NewCircuitBreaker(endpoint string, conf domain.BreakerConfig) *CircuitBreaker {
+	if conf.HalfOpenCalls < 1 {
+		conf.HalfOpenCalls = 1
+	}
+	return &CircuitBreaker{endpoint: endpointLabel(endpoint), conf: conf, state: domain.BreakerClosed}
+}
+
+func (cb *CircuitBreaker) enabled() bool {
+	return cb != nil && cb.conf.FailureThreshold > 0
+}
+
+// Allow reports whether a call may go out, a 503 AppError when the breaker is open
+func (cb *CircuitBreaker) Allow() error {
+	if !cb.enabled() {
+		return nil
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == domain.BreakerOpen && time.Since(cb.openedAt) >= cb.conf.CoolDown {
+		cb.state = domain.BreakerHalfOpen
+		cb.inFlight = 0
+		logger.Log.Info("circuit breaker half-open", zap.String("endpoint", cb.endpoint))
+	}
+
+	switch cb.state {
+	case domain.BreakerOpen:
+		cb.rejected++
+		retryIn := cb.conf.CoolDown - time.Since(cb.openedAt)
+		return domain.NewServiceUnavailableError(fmt.Sprintf(
+			"external summary API %s is unavailable, circuit open, retry in %s", cb.endpoint, retryIn.Round(time.Second)))
+	case domain.BreakerHalfOpen:
+		if cb.inFlight >= cb.conf.HalfOpenCalls {
+			cb.rejected++
+			return domain.NewServiceUnavailableError(fmt.Sprintf(
+				"external summary API %s is unavailable, circuit half-open and a trial call is running", cb.endpoint))
+		}
+		cb.inFlight++
+	}
+	return nil
+}
+
+// Record reports the outcome of an allowed call, failed is true when the upstream itself failed
+func (cb *CircuitBreaker) Record(failed bool) {
+	if !cb.enabled() {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == domain.BreakerHalfOpen && cb.inFlight > 0 {
+		cb.inFlight--
+	}
+
+	if !failed {
+		if cb.state != domain.BreakerClosed {
+			logger.Log.Info("circuit breaker closed", zap.String("endpoint", cb.endpoint))
+		}
+		cb.state = domain.BreakerClosed
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.state == domain.BreakerHalfOpen || (cb.state == domain.BreakerClosed && cb.failures >= cb.conf.FailureThreshold) {
+		cb.state = domain.BreakerOpen
+		cb.openedAt = time.Now()
+		cb.opens++
+		logger.Log.Warn("circuit breaker open", zap.String("endpoint", cb.endpoint), zap.Int("consecutive failures", cb.failures))
+	}
+}
+
+// Release hands back the slot of an allowed call whose outcome says nothing about the upstream,
+// a canceled call or one rejected before it went out. A half-open breaker without trial calls left
+// goes back to open, the next call is a trial again since the cool down has passed.
+func (cb *CircuitBreaker) Release() {
+	if !cb.enabled() {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != domain.BreakerHalfOpen || cb.inFlight == 0 {
+		return
+	}
+	cb.inFlight--
+	if cb.inFlight == 0 {
+		cb.state = domain.BreakerOpen
+	}
+}
+
+func (cb *CircuitBreaker) Status() domain.BreakerStatus {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	status := domain.BreakerStatus{
+		Endpoint:            cb.endpoint,
+		State:               cb.state,
+		ConsecutiveFailures: cb.failures,
+		Opens:               cb.opens,
+		Rejected:            cb.rejected,
+	}
+	if cb.state != domain.BreakerClosed {
+		openedAt := cb.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// endpointLabel drops credentials and the query from an endpoint url, it ends up in logs, errors and metrics
+func endpointLabel(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	return u.Scheme + "://" + u.Host + u.Path
+}
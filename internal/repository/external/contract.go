@@ -0,0 +1,325 @@
+package external
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"math"
+	"net/http"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/logger"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// VersionHeader carries the contract version of a payload, it wins over the version field of the body
+const VersionHeader = "X-Summary-Version"
+
+const defaultContractVersion = "1"
+
+type ParseMode string
+
+const (
+	// ParseStrict rejects a payload with any violation
+	ParseStrict ParseMode = "strict"
+	// ParseLenient logs violations and only rejects a payload without id or schemas
+	ParseLenient ParseMode = "lenient"
+)
+
+func (m ParseMode) Valid() bool {
+	return m == ParseStrict || m == ParseLenient
+}
+
+// Decoder turns the body of one contract version into a summary.
+// Violations it can see while decoding (e.g. unknown fields) are returned, err is for bodies it cannot decode at all.
+type Decoder func(body []byte) (*domain.ExternalSummaryResp, []domain.ContractViolation, error)
+
+// Parser picks a Decoder by contract version and validates what it decodes
+type Parser struct {
+	mode     ParseMode
+	decoders map[string]Decoder
+}
+
+// NewParser returns a parser that knows version 1, more versions are added with Register
+func NewParser(mode ParseMode) *Parser {
+	if !mode.Valid() {
+		mode = ParseLenient
+	}
+	return &Parser{mode: mode, decoders: map[string]Decoder{"1": DecodeV1}}
+}
+
+// Register adds or replaces the decoder of version, e.g. "2"
+func (p *Parser) Register(version string, d Decoder) {
+	p.decoders[normalizeVersion(version)] = d
+}
+
+func (p *Parser) Mode() ParseMode {
+	return p.mode
+}
+
+// Parse decodes and validates body, the version comes from the VersionHeader or the body and defaults to 1
+func (p *Parser) Parse(header http.Header, body []byte) (*domain.ExternalSummaryResp, error) {
+	version := normalizeVersion(header.Get(VersionHeader))
+	if version == "" {
+		version = bodyVersion(body)
+	}
+
+	decode, ok := p.decoders[version]
+	if !ok {
+		return nil, &domain.ContractError{Version: version, Violations: []domain.ContractViolation{
+			{Path: "version", Message: fmt.Sprintf("unsupported contract version %q", version)},
+		}}
+	}
+
+	summary, violations, err := decode(body)
+	if err != nil {
+		return nil, &domain.ContractError{Version: version, Violations: append(violations, decodeViolation(err))}
+	}
+	violations = append(violations, ValidateSummary(summary)...)
+	if len(violations) == 0 {
+		return summary, nil
+	}
+
+	if p.mode == ParseStrict {
+		return nil, &domain.ContractError{Version: version, Violations: violations}
+	}
+
+	logger.Log.Warn("external summary violates the contract, accepted in lenient mode",
+		zap.String("version", version), zap.Any("violations", violations))
+	// check for missing or empty schemas
+	if summary.Id == "" || len(summary.Schemas) == 0 {
+		return nil, domain.NewNotFoundError("external summary list not found or empty")
+	}
+	return summary, nil
+}
+
+// ValidateSummary checks what every contract version has to hold once decoded
+func ValidateSummary(s *domain.ExternalSummaryResp) []domain.ContractViolation {
+	var violations []domain.ContractViolation
+	add := func(path, format string, args ...any) {
+		violations = append(violations, domain.ContractViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Id == "" {
+		add("summary_id", "is required")
+	}
+	if len(s.Schemas) == 0 {
+		add("schemas", "at least one schema is required")
+	}
+
+	schemaAt := map[string]int{}
+	for i, schema := range s.Schemas {
+		schemaPath := fmt.Sprintf("schemas[%d]", i)
+		if schema.Name == "" {
+			add(schemaPath+".name", "is required")
+		} else if first, ok := schemaAt[schema.Name]; ok {
+			add(schemaPath+".name", "duplicate schema %q, first at schemas[%d]", schema.Name, first)
+		} else {
+			schemaAt[schema.Name] = i
+		}
+
+		tableAt := map[string]int{}
+		for j, table := range schema.Tables {
+			tablePath := fmt.Sprintf("%s.tables[%d]", schemaPath, j)
+			if table.Name == "" {
+				add(tablePath+".name", "is required")
+			} else if first, ok := tableAt[table.Name]; ok {
+				add(tablePath+".name", "duplicate table %q, first at %s.tables[%d]", table.Name, schemaPath, first)
+			} else {
+				tableAt[table.Name] = j
+			}
+			if table.TotalRows < 0 {
+				add(tablePath+".row_count", "cannot be negative, got %d", table.TotalRows)
+			}
+			if table.Size < 0 || math.IsNaN(table.Size) || math.IsInf(table.Size, 0) {
+				add(tablePath+".size_mb", "must be a non negative number, got %v", table.Size)
+			}
+			sizes := []*float64{table.HeapSizeMB, table.IndexSizeMB, table.ToastSizeMB}
+			for k, field := range []string{"heap_size_mb", "index_size_mb", "toast_size_mb"} {
+				if size := sizes[k]; size != nil && (*size < 0 || math.IsNaN(*size) || math.IsInf(*size, 0)) {
+					add(tablePath+"."+field, "must be a non negative number, got %v", *size)
+				}
+			}
+			counts := []*int64{table.DeadTuples, table.SeqScans, table.IdxScans}
+			for k, field := range []string{"dead_tuples", "seq_scans", "idx_scans"} {
+				if count := counts[k]; count != nil && *count < 0 {
+					add(tablePath+"."+field, "cannot be negative, got %d", *count)
+				}
+			}
+
+			columnAt := map[string]int{}
+			for k, column := range table.Columns {
+				columnPath := fmt.Sprintf("%s.columns[%d]", tablePath, k)
+				if column.Name == "" {
+					add(columnPath+".name", "is required")
+				} else if first, ok := columnAt[column.Name]; ok {
+					add(columnPath+".name", "duplicate column %q, first at %s.columns[%d]", column.Name, tablePath, first)
+				} else {
+					columnAt[column.Name] = k
+				}
+				if column.DataType == "" {
+					add(columnPath+".data_type", "is required")
+				}
+				if f := column.NullFrac; f != nil && !(*f >= 0 && *f <= 1) {
+					add(columnPath+".null_frac", "must be between 0 and 1, got %v", *f)
+				}
+				if n := column.NDistinct; n != nil && (*n < -1 || math.IsNaN(*n) || math.IsInf(*n, 0)) {
+					add(columnPath+".n_distinct", "must be at least -1, got %v", *n)
+				}
+				if w := column.AvgWidth; w != nil && *w < 0 {
+					add(columnPath+".avg_width", "cannot be negative, got %d", *w)
+				}
+			}
+
+			indexAt := map[string]int{}
+			for k, index := range table.Indexes {
+				indexPath := fmt.Sprintf("%s.indexes[%d]", tablePath, k)
+				if index.Name == "" {
+					add(indexPath+".name", "is required")
+				} else if first, ok := indexAt[index.Name]; ok {
+					add(indexPath+".name", "duplicate index %q, first at %s.indexes[%d]", index.Name, tablePath, first)
+				} else {
+					indexAt[index.Name] = k
+				}
+				if index.Definition == "" {
+					add(indexPath+".definition", "is required")
+				}
+				if index.SizeMB < 0 || math.IsNaN(index.SizeMB) || math.IsInf(index.SizeMB, 0) {
+					add(indexPath+".size_mb", "must be a non negative number, got %v", index.SizeMB)
+				}
+				if index.Scans != nil && *index.Scans < 0 {
+					add(indexPath+".scans", "cannot be negative, got %d", *index.Scans)
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// DecodeV1 decodes {"summary_id", "schemas": [{"name", "tables": [{"name", "row_count", "size_mb"}]}]}, a table
+// may also carry the optional domain.TableStats fields, its "columns" and its "indexes"
+func DecodeV1(body []byte) (*domain.ExternalSummaryResp, []domain.ContractViolation, error) {
+	var summary domain.ExternalSummaryResp
+	if err := json.Unmarshal(body, &summary); err != nil {
+		return nil, nil, err
+	}
+
+	var raw any
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, nil, err
+	}
+	return &summary, unknownFields(raw, "", v1Shape), nil
+}
+
+// shape lists the known fields of a JSON object, nested objects and arrays of objects have their own shape
+type shape map[string]shape
+
+var v1Shape = shape{
+	"version":    nil,
+	"summary_id": nil,
+	"schemas": shape{
+		"name": nil,
+		"tables": shape{
+			"name":      nil,
+			"row_count": nil,
+			"size_mb":   nil,
+			// optional statistics
+			"heap_size_mb":    nil,
+			"index_size_mb":   nil,
+			"toast_size_mb":   nil,
+			"dead_tuples":     nil,
+			"last_vacuum":     nil,
+			"last_autovacuum": nil,
+			"last_analyze":    nil,
+			"seq_scans":       nil,
+			"idx_scans":       nil,
+			"columns": shape{
+				"name":       nil,
+				"data_type":  nil,
+				"nullable":   nil,
+				"default":    nil,
+				"null_frac":  nil,
+				"n_distinct": nil,
+				"avg_width":  nil,
+			},
+			"indexes": shape{
+				"name":       nil,
+				"definition": nil,
+				"size_mb":    nil,
+				"scans":      nil,
+				"unique":     nil,
+				"primary":    nil,
+			},
+		},
+	},
+}
+
+// unknownFields reports every field of raw that known does not list
+func unknownFields(raw any, path string, known shape) []domain.ContractViolation {
+	var violations []domain.ContractViolation
+	switch v := raw.(type) {
+	case []any:
+		for i, item := range v {
+			violations = append(violations, unknownFields(item, fmt.Sprintf("%s[%d]", path, i), known)...)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fieldPath := k
+			if path != "" {
+				fieldPath = path + "." + k
+			}
+			nested, ok := known[k]
+			if !ok {
+				violations = append(violations, domain.ContractViolation{Path: fieldPath, Message: "unknown field"})
+				continue
+			}
+			if nested != nil {
+				violations = append(violations, unknownFields(v[k], fieldPath, nested)...)
+			}
+		}
+	}
+	return violations
+}
+
+// bodyVersion reads the version field of body, a string ("1", "v1") or a number, defaulting to 1
+func bodyVersion(body []byte) string {
+	var probe struct {
+		Version json.RawMessage `json:"version"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil || len(probe.Version) == 0 {
+		return defaultContractVersion
+	}
+	if v := normalizeVersion(string(bytes.Trim(probe.Version, `"`))); v != "" && v != "null" {
+		return v
+	}
+	return defaultContractVersion
+}
+
+func normalizeVersion(v string) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	return strings.TrimPrefix(v, "v")
+}
+
+// arrayIndex matches the ".0" array steps the json package puts in field paths
+var arrayIndex = regexp.MustCompile(`\.(\d+)`)
+
+// decodeViolation points a decode error at the offending field when the json package tells which one
+func decodeViolation(err error) domain.ContractViolation {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return domain.ContractViolation{
+			Path:    arrayIndex.ReplaceAllString(typeErr.Field, "[$1]"),
+			Message: fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value),
+		}
+	}
+	return domain.ContractViolation{Message: "invalid JSON: " + err.Error()}
+}
@@ -1,42 +1,126 @@
 package external
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"pg-summary-service/internal/domain"
 	"pg-summary-service/internal/utils"
 )
 
+// maxSummaryBody bounds how much of an external response is read
+const maxSummaryBody = 64 << 20
+
 type ExternalRepository struct {
-	URL     string
-	Retries int
+	URL      string
+	Retries  int
+	Parser   *Parser
+	Breaker  *CircuitBreaker
+	Security *Security
+}
+
+// Option configures an ExternalRepository
+type Option func(*ExternalRepository)
+
+// WithParser sets how payloads are decoded and validated, the default parses leniently
+func WithParser(parser *Parser) Option {
+	return func(eRepo *ExternalRepository) {
+		eRepo.Parser = parser
+	}
+}
+
+// WithBreaker puts a circuit breaker in front of the endpoint, there is none by default
+func WithBreaker(conf domain.BreakerConfig) Option {
+	return func(eRepo *ExternalRepository) {
+		eRepo.Breaker = NewCircuitBreaker(eRepo.URL, conf)
+	}
 }
 
-func NewExternalRepository(url string, retries int) *ExternalRepository {
+// WithSecurity calls the endpoint with the TLS settings and auth headers of sec, the default is a plain client
+func WithSecurity(sec *Security) Option {
+	return func(eRepo *ExternalRepository) {
+		eRepo.Security = sec
+	}
+}
+
+func NewExternalRepository(url string, retries int, opts ...Option) *ExternalRepository {
 	// constructor
-	return &ExternalRepository{
+	eRepo := &ExternalRepository{
 		URL:     url,
 		Retries: retries,
 	}
+	for _, opt := range opts {
+		opt(eRepo)
+	}
+	if eRepo.Parser == nil {
+		eRepo.Parser = NewParser(ParseLenient)
+	}
+	if eRepo.Breaker == nil {
+		eRepo.Breaker = NewCircuitBreaker(url, domain.BreakerConfig{})
+	}
+	return eRepo
+}
+
+// BreakerStatus returns the state of the endpoint's circuit breaker
+func (eRepo *ExternalRepository) BreakerStatus() []domain.BreakerStatus {
+	return []domain.BreakerStatus{eRepo.Breaker.Status()}
 }
 
-func (eRepo *ExternalRepository) FetchSummaries(data domain.RemoteDBDetails) (*domain.ExternalSummaryResp, error) {
-	resp, err := utils.PostWithRetry(eRepo.URL, eRepo.Retries, data)
+func (eRepo *ExternalRepository) FetchSummaries(ctx context.Context, data domain.RemoteDBDetails) (*domain.ExternalSummaryResp, error) {
+	if eRepo.Security != nil {
+		if err := eRepo.Security.CheckURL(eRepo.URL); err != nil {
+			return nil, err
+		}
+	}
+	if err := eRepo.Breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	body, header, err := eRepo.fetch(ctx, data)
+	switch {
+	case err == nil:
+		eRepo.Breaker.Record(false)
+	case ctx.Err() == nil && upstreamFailed(err):
+		eRepo.Breaker.Record(true)
+	default:
+		// a canceled call or a 4xx says nothing about the upstream's health
+		eRepo.Breaker.Release()
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error while fetching external summary list: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	var externalSummaryResp domain.ExternalSummaryResp
-	if err := json.NewDecoder(resp.Body).Decode(&externalSummaryResp); err != nil {
-		return nil, domain.NewInternalError(
-			fmt.Sprintf("failed to parse external summary list (status %d): %v", resp.StatusCode, err),
-		)
+	// the parser picks the decoder of the payload version and validates it
+	return eRepo.Parser.Parse(header, body)
+}
+
+func (eRepo *ExternalRepository) fetch(ctx context.Context, data domain.RemoteDBDetails) ([]byte, http.Header, error) {
+	var poster utils.Poster
+	if eRepo.Security != nil {
+		poster = utils.Poster{Client: eRepo.Security.client, Sign: eRepo.Security.Sign}
 	}
-	// check for missing or empty schemas
-	if externalSummaryResp.Id == "" || len(externalSummaryResp.Schemas) == 0 {
-		return nil, domain.NewNotFoundError("external summary list not found or empty")
+
+	resp, err := poster.PostWithRetry(ctx, eRepo.URL, eRepo.Retries, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while fetching external summary list: %w", err)
 	}
+	defer resp.Body.Close()
 
-	return &externalSummaryResp, nil
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSummaryBody))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read external summary list (status %d): %w: %v",
+			resp.StatusCode, domain.ErrExternalServiceUnreachable, err)
+	}
+	return body, resp.Header, nil
+}
+
+// upstreamFailed reports whether err comes from an unreachable upstream or one answering with a 5xx or 429
+func upstreamFailed(err error) bool {
+	if errors.Is(err, domain.ErrExternalServiceUnreachable) {
+		return true
+	}
+	var appErr *domain.AppError
+	return errors.As(err, &appErr) && appErr.Code >= http.StatusInternalServerError
 }
@@ -1,7 +1,10 @@
 package external
 
-import "pg-summary-service/internal/domain"
+import (
+	"context"
+	"pg-summary-service/internal/domain"
+)
 
 type External interface {
-	FetchSummaries(details domain.RemoteDBDetails) (*domain.ExternalSummaryResp, error)
+	FetchSummaries(ctx context.Context, details domain.RemoteDBDetails) (*domain.ExternalSummaryResp, error)
 }
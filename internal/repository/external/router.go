@@ -0,0 +1,164 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"path"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/logger"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider is one external summary API the router can send a target to
+type Provider struct {
+	domain.ExternalProvider
+	Repo External
+}
+
+// ProviderRouter is an External over several providers. A target goes to the providers whose host patterns
+// match it (the ones without patterns when none do), by priority, failing over to the next one while a
+// provider is unreachable.
+type ProviderRouter struct {
+	providers []*routedProvider // sorted by priority
+}
+
+type routedProvider struct {
+	Provider
+
+	mu        sync.Mutex
+	stats     domain.ProviderStats
+	latencyMs float64 // total, for the average
+}
+
+// NewRouter builds a repository per provider, opts apply to each, so every provider gets its own breaker
+func NewRouter(providers []domain.ExternalProvider, retries int, opts ...Option) *ProviderRouter {
+	repos := make([]Provider, 0, len(providers))
+	for _, p := range providers {
+		repos = append(repos, Provider{ExternalProvider: p, Repo: NewExternalRepository(p.URL, retries, opts...)})
+	}
+	return NewProviderRouter(repos)
+}
+
+func NewProviderRouter(providers []Provider) *ProviderRouter {
+	router := &ProviderRouter{}
+	for _, p := range providers {
+		router.providers = append(router.providers, &routedProvider{
+			Provider: p,
+			stats:    domain.ProviderStats{Name: p.Name, Region: p.Region, Priority: p.Priority},
+		})
+	}
+	sort.SliceStable(router.providers, func(a, b int) bool {
+		return router.providers[a].Priority < router.providers[b].Priority
+	})
+	return router
+}
+
+func (r *ProviderRouter) FetchSummaries(ctx context.Context, details domain.RemoteDBDetails) (*domain.ExternalSummaryResp, error) {
+	candidates := r.route(details.Host)
+	if len(candidates) == 0 {
+		return nil, domain.NewBadRequestError(fmt.Sprintf("no external provider serves host %s", details.Host))
+	}
+
+	var errs []error
+	for i, p := range candidates {
+		startedAt := time.Now()
+		resp, err := p.Repo.FetchSummaries(ctx, details)
+		failover := err != nil && unavailable(err) && ctx.Err() == nil && i < len(candidates)-1
+		p.record(startedAt, err, failover)
+		if err == nil {
+			return resp, nil
+		}
+		if !failover {
+			if len(errs) == 0 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w (after failing over: %w)", err, errors.Join(errs...))
+		}
+
+		logger.Log.Warn("external provider unavailable, failing over", zap.String("provider", p.Name),
+			zap.String("next", candidates[i+1].Name), zap.Error(err))
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+	}
+	return nil, errors.Join(errs...) // unreachable, the last candidate never fails over
+}
+
+// route returns the providers for host in priority order
+func (r *ProviderRouter) route(host string) []*routedProvider {
+	var matching, catchAll []*routedProvider
+	for _, p := range r.providers {
+		if len(p.Hosts) == 0 {
+			catchAll = append(catchAll, p)
+			continue
+		}
+		for _, pattern := range p.Hosts {
+			if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(host)); ok {
+				matching = append(matching, p)
+				break
+			}
+		}
+	}
+	if len(matching) > 0 {
+		return matching
+	}
+	return catchAll
+}
+
+// unavailable tells a provider that is down or refusing calls from one that answered with an error
+func unavailable(err error) bool {
+	if errors.Is(err, domain.ErrExternalServiceUnreachable) {
+		return true
+	}
+	var appErr *domain.AppError
+	return errors.As(err, &appErr) && appErr.Code == http.StatusServiceUnavailable
+}
+
+func (p *routedProvider) record(startedAt time.Time, err error, failover bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.Requests++
+	p.latencyMs += float64(time.Since(startedAt).Microseconds()) / 1000
+	p.stats.LastUsedAt = &startedAt
+	if err != nil {
+		p.stats.Failures++
+		p.stats.LastError = err.Error()
+	} else {
+		p.stats.Successes++
+	}
+	if failover {
+		p.stats.Failovers++
+	}
+}
+
+// ProviderStats returns the request counts and latency of every provider in priority order
+func (r *ProviderRouter) ProviderStats() []domain.ProviderStats {
+	stats := make([]domain.ProviderStats, 0, len(r.providers))
+	for _, p := range r.providers {
+		p.mu.Lock()
+		s := p.stats
+		if s.Requests > 0 {
+			s.SuccessRate = float64(s.Successes) / float64(s.Requests)
+			s.AvgLatencyMs = p.latencyMs / float64(s.Requests)
+		}
+		p.mu.Unlock()
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// BreakerStatus returns the circuit breakers of the providers that have one
+func (r *ProviderRouter) BreakerStatus() []domain.BreakerStatus {
+	var status []domain.BreakerStatus
+	for _, p := range r.providers {
+		if b, ok := p.Repo.(interface{ BreakerStatus() []domain.BreakerStatus }); ok {
+			status = append(status, b.BreakerStatus()...)
+		}
+	}
+	return status
+}
@@ -0,0 +1,90 @@
+package external
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TimestampHeader and SignatureHeader carry the HMAC of a request, the API recomputes
+	// hex(HMAC-SHA256(secret, "<timestamp>.<body>")) to verify it
+	TimestampHeader = "X-Summary-Timestamp"
+	SignatureHeader = "X-Summary-Signature"
+
+	requestTimeout = 5 * time.Second
+)
+
+// Security is the client and request headers the external API is called with
+type Security struct {
+	conf   domain.OutboundSecurity
+	client *http.Client
+}
+
+// NewSecurity loads the CA bundle and client certificate of conf
+func NewSecurity(conf domain.OutboundSecurity) (*Security, error) {
+	tlsConf := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if conf.CAFile != "" {
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA bundle %s", conf.CAFile)
+		}
+		tlsConf.RootCAs = roots
+	}
+
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		if conf.CertFile == "" || conf.KeyFile == "" {
+			return nil, fmt.Errorf("mTLS needs both a client certificate and its key")
+		}
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConf
+	client := &http.Client{Timeout: requestTimeout, Transport: transport, CheckRedirect: utils.RefuseRedirects}
+	return &Security{conf: conf, client: client}, nil
+}
+
+// Sign sets the bearer token and the HMAC signature of body on req
+func (s *Security) Sign(req *http.Request, body []byte) {
+	if s.conf.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.conf.BearerToken)
+	}
+	if s.conf.HMACSecret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, "sha256="+utils.SignPayload(s.conf.HMACSecret, timestamp, body))
+	}
+}
+
+// CheckURL refuses a plain http endpoint when https is required, credentials would be sent in the clear
+func (s *Security) CheckURL(raw string) error {
+	if !s.conf.RequireHTTPS {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || !strings.EqualFold(u.Scheme, "https") {
+		return domain.NewInternalError(fmt.Sprintf("external summary API %s is not https, refused", endpointLabel(raw)))
+	}
+	return nil
+}
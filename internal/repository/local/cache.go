@@ -0,0 +1,376 @@
+package local
+
+import (
+	"container/list"
+	"context"
+	"pg-summary-service/internal/domain"
+	"sync"
+	"time"
+)
+
+const latestSummariesKey = "latest"
+
+// CachedRepository is a read-through cache in front of a Local with an LRU bound and a TTL.
+// Summaries never change once written, so lookups by id are cached until the summary is written or deleted again.
+// note: paged lists and source streams are always read from the wrapped Local
+type CachedRepository struct {
+	next Local
+	conf domain.CacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is the most recently used
+	bytes   int64
+	stats   domain.CacheStats
+
+	// a load started before its key was invalidated is not stored, the value may predate the write.
+	// invalidated holds the clock of the last invalidation of every key, only while loads are in flight.
+	clock       uint64
+	invalidated map[string]uint64
+	loads       int
+}
+
+type cacheEntry struct {
+	key       string
+	value     any
+	size      int64
+	expiresAt time.Time
+}
+
+func NewCachedRepository(next Local, conf domain.CacheConfig) *CachedRepository {
+	return &CachedRepository{
+		next:        next,
+		conf:        conf,
+		entries:     map[string]*list.Element{},
+		lru:         list.New(),
+		invalidated: map[string]uint64{},
+	}
+}
+
+// Stats returns the hit, miss and eviction counters and the current size
+func (cRepo *CachedRepository) Stats() domain.CacheStats {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	stats := cRepo.stats
+	stats.Entries = cRepo.lru.Len()
+	stats.Bytes = cRepo.bytes
+	return stats
+}
+
+func (cRepo *CachedRepository) AddSummary(ctx context.Context, src string, data *domain.ExternalSummaryResp) (any, error) {
+	resp, err := cRepo.next.AddSummary(ctx, src, data)
+	if data != nil {
+		cRepo.invalidate(summaryKey(data.Id), tablesKey(data.Id), indexesKey(data.Id), latestSummariesKey)
+	}
+	return resp, err
+}
+
+func (cRepo *CachedRepository) DeleteSummary(ctx context.Context, id string) (*domain.LocalSummaryListItem, error) {
+	item, err := cRepo.next.DeleteSummary(ctx, id)
+	cRepo.invalidate(summaryKey(id), tablesKey(id), indexesKey(id), latestSummariesKey)
+	return item, err
+}
+
+func (cRepo *CachedRepository) GetSummaryById(ctx context.Context, id string) (*domain.LocalSummaryByIdResp, error) {
+	key := summaryKey(id)
+	if v, ok := cRepo.get(key); ok {
+		return copySummaryResp(v.(*domain.LocalSummaryByIdResp)), nil
+	}
+
+	gen := cRepo.beginLoad()
+	defer cRepo.endLoad()
+	resp, err := cRepo.next.GetSummaryById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cRepo.put(gen, key, copySummaryResp(resp), summaryRespSize(resp))
+	return resp, nil
+}
+
+func (cRepo *CachedRepository) GetSummaryTables(ctx context.Context, id string) ([]domain.SummaryTable, error) {
+	key := tablesKey(id)
+	if v, ok := cRepo.get(key); ok {
+		return copyTables(v.([]domain.SummaryTable)), nil
+	}
+
+	gen := cRepo.beginLoad()
+	defer cRepo.endLoad()
+	tables, err := cRepo.next.GetSummaryTables(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cRepo.put(gen, key, copyTables(tables), summaryTablesSize(tables))
+	return tables, nil
+}
+
+func (cRepo *CachedRepository) GetSummaryIndexes(ctx context.Context, id string) ([]domain.TableIndex, error) {
+	key := indexesKey(id)
+	if v, ok := cRepo.get(key); ok {
+		return copyIndexes(v.([]domain.TableIndex)), nil
+	}
+
+	gen := cRepo.beginLoad()
+	defer cRepo.endLoad()
+	indexes, err := cRepo.next.GetSummaryIndexes(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cRepo.put(gen, key, copyIndexes(indexes), summaryIndexesSize(indexes))
+	return indexes, nil
+}
+
+// GetLatestSummaries is cached as one entry, any write or delete drops it
+func (cRepo *CachedRepository) GetLatestSummaries(ctx context.Context) ([]domain.LocalSummaryListItem, error) {
+	if v, ok := cRepo.get(latestSummariesKey); ok {
+		return copyListItems(v.([]domain.LocalSummaryListItem)), nil
+	}
+
+	gen := cRepo.beginLoad()
+	defer cRepo.endLoad()
+	items, err := cRepo.next.GetLatestSummaries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cRepo.put(gen, latestSummariesKey, copyListItems(items), listItemsSize(items))
+	return items, nil
+}
+
+// GetLatestSnapshots is not cached, it is one read of the store and its tables would duplicate the table entries
+func (cRepo *CachedRepository) GetLatestSnapshots(ctx context.Context) ([]domain.SourceSnapshot, error) {
+	return cRepo.next.GetLatestSnapshots(ctx)
+}
+
+func (cRepo *CachedRepository) GetSummary(ctx context.Context, offset int, limit int) ([]domain.LocalSummaryListItem, error) {
+	return cRepo.next.GetSummary(ctx, offset, limit)
+}
+
+func (cRepo *CachedRepository) GetSourceSummaries(ctx context.Context, src string, offset int, limit int) ([]domain.LocalSummaryListItem, error) {
+	return cRepo.next.GetSourceSummaries(ctx, src, offset, limit)
+}
+
+func (cRepo *CachedRepository) StreamSummaries(ctx context.Context, offset int, limit int, fn func(domain.LocalSummaryListItem) error) error {
+	return cRepo.next.StreamSummaries(ctx, offset, limit, fn)
+}
+
+// StreamSummaryTables serves cached tables, a stream read to the end fills the cache unless it outgrows MaxBytes
+func (cRepo *CachedRepository) StreamSummaryTables(ctx context.Context, id string, fn func(domain.SummaryTable) error) error {
+	key := tablesKey(id)
+	if v, ok := cRepo.get(key); ok {
+		for _, table := range copyTables(v.([]domain.SummaryTable)) {
+			if err := fn(table); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	gen := cRepo.beginLoad()
+	defer cRepo.endLoad()
+	tables, size := []domain.SummaryTable{}, summaryTablesSize(nil)
+	err := cRepo.next.StreamSummaryTables(ctx, id, func(table domain.SummaryTable) error {
+		if tables != nil {
+			size += summaryTablesSize([]domain.SummaryTable{table}) - summaryTablesSize(nil)
+			if cRepo.conf.MaxBytes > 0 && size > cRepo.conf.MaxBytes {
+				tables = nil // too large to keep, stream the rest without holding it
+			} else {
+				c := table
+				c.TableStats = copyStats(table.TableStats)
+				tables = append(tables, c)
+			}
+		}
+		return fn(table)
+	})
+	if err == nil && tables != nil {
+		cRepo.put(gen, key, tables, size)
+	}
+	return err
+}
+
+// GetTableColumns is not cached, a delete could not tell which table ids of the summary to drop
+func (cRepo *CachedRepository) GetTableColumns(ctx context.Context, summaryId string, tableId string) ([]domain.TableColumn, error) {
+	return cRepo.next.GetTableColumns(ctx, summaryId, tableId)
+}
+
+func (cRepo *CachedRepository) StreamSourceTables(ctx context.Context, src string, fn func(domain.SummaryTable) error) error {
+	return cRepo.next.StreamSourceTables(ctx, src, fn)
+}
+
+func (cRepo *CachedRepository) get(key string) (any, bool) {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+
+	el, ok := cRepo.entries[key]
+	if !ok {
+		cRepo.stats.Misses++
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !entry.expiresAt.IsZero() && !time.Now().Before(entry.expiresAt) {
+		cRepo.removeLocked(el)
+		cRepo.stats.Misses++
+		return nil, false
+	}
+	cRepo.lru.MoveToFront(el)
+	cRepo.stats.Hits++
+	return entry.value, true
+}
+
+// beginLoad returns the clock a load starts at, endLoad must follow once the load is stored or dropped
+func (cRepo *CachedRepository) beginLoad() uint64 {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	cRepo.loads++
+	return cRepo.clock
+}
+
+func (cRepo *CachedRepository) endLoad() {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	cRepo.loads--
+	if cRepo.loads == 0 {
+		clear(cRepo.invalidated)
+	}
+}
+
+// put stores value unless key was invalidated since the load started at gen
+func (cRepo *CachedRepository) put(gen uint64, key string, value any, size int64) {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+
+	if cRepo.invalidated[key] > gen || (cRepo.conf.MaxBytes > 0 && size > cRepo.conf.MaxBytes) {
+		return
+	}
+	if el, ok := cRepo.entries[key]; ok {
+		cRepo.removeLocked(el)
+	}
+
+	entry := &cacheEntry{key: key, value: value, size: size}
+	if cRepo.conf.TTL > 0 {
+		entry.expiresAt = time.Now().Add(cRepo.conf.TTL)
+	}
+	cRepo.entries[key] = cRepo.lru.PushFront(entry)
+	cRepo.bytes += size
+
+	for cRepo.overLimitLocked() {
+		cRepo.removeLocked(cRepo.lru.Back())
+		cRepo.stats.Evictions++
+	}
+}
+
+func (cRepo *CachedRepository) invalidate(keys ...string) {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+
+	cRepo.clock++
+	for _, key := range keys {
+		if cRepo.loads > 0 {
+			cRepo.invalidated[key] = cRepo.clock
+		}
+		if el, ok := cRepo.entries[key]; ok {
+			cRepo.removeLocked(el)
+		}
+	}
+}
+
+func (cRepo *CachedRepository) overLimitLocked() bool {
+	if cRepo.lru.Len() == 0 {
+		return false
+	}
+	return (cRepo.conf.MaxEntries > 0 && cRepo.lru.Len() > cRepo.conf.MaxEntries) ||
+		(cRepo.conf.MaxBytes > 0 && cRepo.bytes > cRepo.conf.MaxBytes)
+}
+
+func (cRepo *CachedRepository) removeLocked(el *list.Element) {
+	entry := cRepo.lru.Remove(el).(*cacheEntry)
+	delete(cRepo.entries, entry.key)
+	cRepo.bytes -= entry.size
+}
+
+func summaryKey(id string) string {
+	return "summary:" + id
+}
+
+func tablesKey(id string) string {
+	return "tables:" + id
+}
+
+// cached values are copied in and out so callers cannot change what other callers get
+
+func copySummaryResp(resp *domain.LocalSummaryByIdResp) *domain.LocalSummaryByIdResp {
+	c := *resp
+	c.Schemas = append([]domain.SchemaSummary(nil), resp.Schemas...)
+	for i := range c.Schemas {
+		stats := &c.Schemas[i].SchemaStats
+		stats.HeapSizeMB = copyOf(stats.HeapSizeMB)
+		stats.IndexSizeMB = copyOf(stats.IndexSizeMB)
+		stats.ToastSizeMB = copyOf(stats.ToastSizeMB)
+		stats.DeadTuples = copyOf(stats.DeadTuples)
+		stats.SeqScans = copyOf(stats.SeqScans)
+		stats.IdxScans = copyOf(stats.IdxScans)
+		stats.OldestVacuum = copyOf(stats.OldestVacuum)
+		stats.OldestAnalyze = copyOf(stats.OldestAnalyze)
+		stats.NeverVacuumed = copyOf(stats.NeverVacuumed)
+	}
+	return &c
+}
+
+// the stats and scans are pointers, the slices are copied element by element
+
+func copyTables(tables []domain.SummaryTable) []domain.SummaryTable {
+	c := make([]domain.SummaryTable, len(tables))
+	for i, t := range tables {
+		t.TableStats = copyStats(t.TableStats)
+		c[i] = t
+	}
+	return c
+}
+
+func copyIndexes(indexes []domain.TableIndex) []domain.TableIndex {
+	c := make([]domain.TableIndex, len(indexes))
+	for i, index := range indexes {
+		c[i] = copyIndex(index)
+	}
+	return c
+}
+
+func copyListItems(items []domain.LocalSummaryListItem) []domain.LocalSummaryListItem {
+	return append([]domain.LocalSummaryListItem(nil), items...)
+}
+
+// sizes are estimates, struct overhead plus string bytes
+
+func summaryRespSize(resp *domain.LocalSummaryByIdResp) int64 {
+	size := int64(64 + len(resp.ID) + len(resp.Source))
+	for _, s := range resp.Schemas {
+		size += int64(128 + len(s.Id) + len(s.Name)) // with the SchemaStats pointers
+	}
+	return size
+}
+
+func summaryTablesSize(tables []domain.SummaryTable) int64 {
+	size := int64(24)
+	for _, t := range tables {
+		size += int64(184 + len(t.Id) + len(t.SummaryId) + len(t.Schema) + len(t.Name)) // with the TableStats pointers
+	}
+	return size
+}
+
+func summaryIndexesSize(indexes []domain.TableIndex) int64 {
+	size := int64(24)
+	for _, i := range indexes {
+		size += int64(144 + len(i.Id) + len(i.TableId) + len(i.Schema) + len(i.Table) + len(i.Name) + len(i.Definition))
+	}
+	return size
+}
+
+func listItemsSize(items []domain.LocalSummaryListItem) int64 {
+	size := int64(24)
+	for _, item := range items {
+		size += int64(56 + len(item.ID) + len(item.DBName))
+	}
+	return size
+}
+
+func indexesKey(id string) string {
+	return "indexes:" + id
+}
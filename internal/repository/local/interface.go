@@ -9,4 +9,24 @@ type Local interface {
 	GetSummaryById(ctx context.Context, id string) (*domain.LocalSummaryByIdResp, error)
 	AddSummary(ctx context.Context, src string, data *domain.ExternalSummaryResp) (any, error)
 	GetSummary(ctx context.Context, offset int, limit int) ([]domain.LocalSummaryListItem, error)
+	// StreamSummaries calls fn for every summary of the page as it is read, newest first
+	StreamSummaries(ctx context.Context, offset int, limit int, fn func(domain.LocalSummaryListItem) error) error
+	// GetSourceSummaries returns the summaries of one source, newest first
+	GetSourceSummaries(ctx context.Context, src string, offset int, limit int) ([]domain.LocalSummaryListItem, error)
+	// GetLatestSummaries returns the most recent summary of every source
+	GetLatestSummaries(ctx context.Context) ([]domain.LocalSummaryListItem, error)
+	// GetLatestSnapshots returns the most recent summary of every source with its tables, ordered by source
+	GetLatestSnapshots(ctx context.Context) ([]domain.SourceSnapshot, error)
+	// DeleteSummary removes the summary with its schemas and tables and returns what was deleted
+	DeleteSummary(ctx context.Context, id string) (*domain.LocalSummaryListItem, error)
+	GetSummaryTables(ctx context.Context, id string) ([]domain.SummaryTable, error)
+	// StreamSummaryTables calls fn for every table of the summary as it is read, fn is never called for a summary without tables
+	StreamSummaryTables(ctx context.Context, id string, fn func(domain.SummaryTable) error) error
+	// GetTableColumns returns the columns of a table of the summary in their position order, not found when the
+	// table is not part of the summary
+	GetTableColumns(ctx context.Context, summaryId string, tableId string) ([]domain.TableColumn, error)
+	// GetSummaryIndexes returns the indexes of every table of the summary ordered by schema, table and index name
+	GetSummaryIndexes(ctx context.Context, id string) ([]domain.TableIndex, error)
+	// StreamSourceTables calls fn for every table of every summary stored for src, newest summary first
+	StreamSourceTables(ctx context.Context, src string, fn func(domain.SummaryTable) error) error
 }
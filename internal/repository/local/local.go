@@ -2,8 +2,10 @@ package local
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 	"pg-summary-service/internal/domain"
@@ -11,10 +13,46 @@ import (
 	"time"
 )
 
+// progressEvery is how many tables AddSummary writes between progress reports within a schema
+const progressEvery = 100
+
 type LocalRepository struct {
 	db *pgxpool.Pool
 }
 
+// CreateTables creates the tables the repository works on, if missing
+func CreateTables(ctx context.Context, db *pgxpool.Pool) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS summaries (id VARCHAR PRIMARY KEY, source_info VARCHAR, synced_at TIMESTAMP)`,
+		`CREATE TABLE IF NOT EXISTS schemas (id VARCHAR PRIMARY KEY, summary_id VARCHAR REFERENCES summaries(id), name VARCHAR)`,
+		`CREATE TABLE IF NOT EXISTS tables (id VARCHAR PRIMARY KEY, schema_id VARCHAR REFERENCES schemas(id), name VARCHAR, row_count BIGINT, size_mb FLOAT)`,
+		// the optional table statistics, added to tables created before they existed
+		`ALTER TABLE tables
+			ADD COLUMN IF NOT EXISTS heap_size_mb FLOAT,
+			ADD COLUMN IF NOT EXISTS index_size_mb FLOAT,
+			ADD COLUMN IF NOT EXISTS toast_size_mb FLOAT,
+			ADD COLUMN IF NOT EXISTS dead_tuples BIGINT,
+			ADD COLUMN IF NOT EXISTS last_vacuum TIMESTAMPTZ,
+			ADD COLUMN IF NOT EXISTS last_autovacuum TIMESTAMPTZ,
+			ADD COLUMN IF NOT EXISTS last_analyze TIMESTAMPTZ,
+			ADD COLUMN IF NOT EXISTS seq_scans BIGINT,
+			ADD COLUMN IF NOT EXISTS idx_scans BIGINT`,
+		`CREATE TABLE IF NOT EXISTS columns (id VARCHAR PRIMARY KEY, table_id VARCHAR REFERENCES tables(id), position INT,
+			name VARCHAR, data_type VARCHAR, nullable BOOLEAN, column_default VARCHAR, null_frac FLOAT, n_distinct FLOAT, avg_width BIGINT)`,
+		`CREATE INDEX IF NOT EXISTS columns_table_id_idx ON columns (table_id)`,
+		`CREATE TABLE IF NOT EXISTS indexes (id VARCHAR PRIMARY KEY, table_id VARCHAR REFERENCES tables(id), name VARCHAR,
+			definition VARCHAR, size_mb FLOAT, scans BIGINT, is_unique BOOLEAN, is_primary BOOLEAN)`,
+		`CREATE INDEX IF NOT EXISTS indexes_table_id_idx ON indexes (table_id)`,
+	}
+
+	for _, q := range queries {
+		if _, err := db.Exec(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func NewLocalRepository(db *pgxpool.Pool) *LocalRepository {
 	return &LocalRepository{db: db}
 }
@@ -27,33 +65,71 @@ func (lRepo *LocalRepository) AddSummary(ctx context.Context, src string, data *
 		return nil, domain.NewBadRequestError("data cannot be an empty")
 	}
 
+	// the whole summary is written in one transaction, a failed sync leaves nothing half stored
+	tx, err := lRepo.db.Begin(ctx)
+	if err != nil {
+		logger.Log.Error("error while starting sync transaction", zap.Error(err))
+		return nil, domain.HandlePGError(err)
+	}
+	defer tx.Rollback(ctx) // no-op once committed
+
+	// rows are queued and sent in batches, one round trip per schema or per progressEvery tables
+	batch := &pgx.Batch{}
+	flush := func() error {
+		if batch.Len() == 0 {
+			return nil
+		}
+		err := tx.SendBatch(ctx, batch).Close()
+		batch = &pgx.Batch{}
+		if err != nil {
+			logger.Log.Error("error while saving summary data to local db", zap.Error(err), zap.String("summary id", data.Id))
+			return domain.HandlePGError(err)
+		}
+		return nil
+	}
+
 	// Insert summary
 	id := data.Id //uuid.New().String()
 	syncedAt := time.Now()
-	query := `INSERT INTO summaries (id, source_info, synced_at) VALUES ($1, $2, $3)`
-	if _, err := lRepo.db.Exec(ctx, query, id, src, syncedAt); err != nil {
-		logger.Log.Error("error while saving data to local db", zap.Error(err), zap.Any("data", data))
-		return nil, domain.HandlePGError(err)
-	}
+	batch.Queue(`INSERT INTO summaries (id, source_info, synced_at) VALUES ($1, $2, $3)`, id, src, syncedAt)
 
-	// Insert schemas and tables
+	// Insert schemas and tables, reporting progress after every schema and every progressEvery tables
+	progress := data.Totals()
+	tableQuery := `INSERT INTO tables (id, schema_id, name, row_count, size_mb, heap_size_mb, index_size_mb, toast_size_mb,
+		dead_tuples, last_vacuum, last_autovacuum, last_analyze, seq_scans, idx_scans)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
 	for _, schema := range data.Schemas {
 		schemaID := uuid.New().String()
-		query = `INSERT INTO schemas (id, summary_id, name) VALUES ($1, $2, $3)`
-		_, err := lRepo.db.Exec(ctx, query, schemaID, id, schema.Name)
-		if err != nil {
-			logger.Log.Error("error while saving schema data to local db", zap.Error(err), zap.Any("schema", schema))
-			return nil, domain.HandlePGError(err)
-		}
-		tableQuery := `INSERT INTO tables (id, schema_id, name, row_count, size_mb) VALUES ($1, $2, $3, $4, $5)`
+		batch.Queue(`INSERT INTO schemas (id, summary_id, name) VALUES ($1, $2, $3)`, schemaID, id, schema.Name)
 		for _, table := range schema.Tables {
-			_, err = lRepo.db.Exec(ctx, tableQuery,
-				uuid.New().String(), schemaID, table.Name, table.TotalRows, table.Size)
-			if err != nil {
-				logger.Log.Error("error while saving table data to local db", zap.Error(err), zap.Any("table", table))
-				return nil, domain.HandlePGError(err)
+			tableID := uuid.New().String()
+			stats := table.TableStats
+			batch.Queue(tableQuery,
+				tableID, schemaID, table.Name, table.TotalRows, table.Size,
+				stats.HeapSizeMB, stats.IndexSizeMB, stats.ToastSizeMB, stats.DeadTuples,
+				stats.LastVacuum, stats.LastAutovacuum, stats.LastAnalyze, stats.SeqScans, stats.IdxScans)
+			queueColumns(batch, tableID, table.Columns)
+			queueIndexes(batch, tableID, table.Indexes)
+			if progress.Tables++; progress.Tables%progressEvery == 0 {
+				if err = flush(); err != nil {
+					return nil, err
+				}
+				domain.ReportProgress(ctx, progress)
 			}
 		}
+		if err = flush(); err != nil {
+			return nil, err
+		}
+		progress.Schemas++
+		domain.ReportProgress(ctx, progress)
+	}
+	if err = flush(); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		logger.Log.Error("error while committing sync transaction", zap.Error(err), zap.String("summary id", id))
+		return nil, domain.HandlePGError(err)
 	}
 	return nil, nil
 	//return &domain.Summary{
@@ -75,7 +151,14 @@ func (lRepo *LocalRepository) GetSummaryById(ctx context.Context, id string) (*d
 		sc.id, sc.name,
 		COUNT(t.id), 
 		COALESCE(SUM(t.row_count), 0),
-		COALESCE(SUM(t.size_mb), 0)
+		COALESCE(SUM(t.size_mb), 0),
+		SUM(t.heap_size_mb), SUM(t.index_size_mb), SUM(t.toast_size_mb),
+		SUM(t.dead_tuples)::BIGINT, SUM(t.seq_scans)::BIGINT, SUM(t.idx_scans)::BIGINT,
+		MIN(GREATEST(t.last_vacuum, t.last_autovacuum)), MIN(t.last_analyze),
+		CASE WHEN COUNT(t.id) FILTER (WHERE num_nonnulls(t.heap_size_mb, t.index_size_mb, t.toast_size_mb, t.dead_tuples,
+			t.last_vacuum, t.last_autovacuum, t.last_analyze, t.seq_scans, t.idx_scans) > 0) > 0
+		THEN COUNT(t.id) FILTER (WHERE t.last_vacuum IS NULL AND t.last_autovacuum IS NULL AND num_nonnulls(t.heap_size_mb,
+			t.index_size_mb, t.toast_size_mb, t.dead_tuples, t.last_analyze, t.seq_scans, t.idx_scans) > 0) END
 	FROM summaries s
 	LEFT JOIN schemas sc ON sc.summary_id = s.id
 	LEFT JOIN tables t ON t.schema_id = sc.id
@@ -103,9 +186,12 @@ func (lRepo *LocalRepository) GetSummaryById(ctx context.Context, id string) (*d
 			tableCount  int
 			totalRows   int64
 			totalSizeMb float64
+			stats       domain.SchemaStats
 		)
 
-		if err = rows.Scan(&summaryID, &source, &syncedAt, &schemaID, &schemaName, &tableCount, &totalRows, &totalSizeMb); err != nil {
+		if err = rows.Scan(&summaryID, &source, &syncedAt, &schemaID, &schemaName, &tableCount, &totalRows, &totalSizeMb,
+			&stats.HeapSizeMB, &stats.IndexSizeMB, &stats.ToastSizeMB, &stats.DeadTuples, &stats.SeqScans, &stats.IdxScans,
+			&stats.OldestVacuum, &stats.OldestAnalyze, &stats.NeverVacuumed); err != nil {
 			logger.Log.Error("error while s-caning summary data", zap.Error(err))
 			return nil, err
 		}
@@ -126,7 +212,9 @@ func (lRepo *LocalRepository) GetSummaryById(ctx context.Context, id string) (*d
 				TableCount:  tableCount,
 				TotalRows:   totalRows,
 				TotalSizeMB: totalSizeMb,
+				SchemaStats: stats,
 			}
+			utcTimes(schema.OldestVacuum, schema.OldestAnalyze)
 			summary.Schemas = append(summary.Schemas, schema)
 		}
 	}
@@ -138,7 +226,182 @@ func (lRepo *LocalRepository) GetSummaryById(ctx context.Context, id string) (*d
 	return &summary, nil
 }
 
+// queueColumns queues the inserts of the columns of one table in the order they were listed
+func queueColumns(batch *pgx.Batch, tableID string, columns []domain.Column) {
+	query := `INSERT INTO columns (id, table_id, position, name, data_type, nullable, column_default, null_frac, n_distinct, avg_width)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	for i, column := range columns {
+		batch.Queue(query, uuid.New().String(), tableID, i+1, column.Name, column.DataType, column.Nullable,
+			column.Default, column.NullFrac, column.NDistinct, column.AvgWidth)
+	}
+}
+
+// queueIndexes queues the inserts of the indexes of one table
+func queueIndexes(batch *pgx.Batch, tableID string, indexes []domain.Index) {
+	query := `INSERT INTO indexes (id, table_id, name, definition, size_mb, scans, is_unique, is_primary)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	for _, index := range indexes {
+		batch.Queue(query, uuid.New().String(), tableID, index.Name, index.Definition, index.SizeMB,
+			index.Scans, index.Unique, index.Primary)
+	}
+}
+
+func (lRepo *LocalRepository) GetSummaryIndexes(ctx context.Context, id string) ([]domain.TableIndex, error) {
+	if id == "" {
+		return nil, domain.NewBadRequestError("id cannot be an empty string")
+	}
+
+	query := `
+	SELECT s.id, sc.name, t.id, t.name, i.id, i.name, i.definition, i.size_mb, i.scans, i.is_unique, i.is_primary
+	FROM summaries s
+	LEFT JOIN schemas sc ON sc.summary_id = s.id
+	LEFT JOIN tables t ON t.schema_id = sc.id
+	LEFT JOIN indexes i ON i.table_id = t.id
+	WHERE s.id = $1
+	ORDER BY sc.name, t.name, i.name;
+	`
+
+	rows, err := lRepo.db.Query(ctx, query, id)
+	if err != nil {
+		logger.Log.Error("error while fetching summary indexes", zap.Error(err), zap.String("summary id", id))
+		return nil, domain.HandlePGError(err)
+	}
+	defer rows.Close()
+
+	// the left joins give rows without an index for schemas and tables that have none, found tells the summary exists
+	found := false
+	indexes := []domain.TableIndex{}
+	for rows.Next() {
+		var (
+			summaryID  string
+			schemaName *string
+			tableID    *string
+			tableName  *string
+			indexID    *string
+			name       *string
+			definition *string
+			sizeMb     *float64
+			unique     *bool
+			primary    *bool
+			index      domain.TableIndex
+		)
+		if err = rows.Scan(&summaryID, &schemaName, &tableID, &tableName, &indexID, &name, &definition, &sizeMb,
+			&index.Scans, &unique, &primary); err != nil {
+			logger.Log.Error("error while s-caning summary indexes", zap.Error(err))
+			return nil, domain.HandlePGError(err)
+		}
+		found = true
+		if indexID == nil {
+			continue
+		}
+
+		index.Id, index.TableId, index.Schema, index.Table = *indexID, *tableID, *schemaName, *tableName
+		if name != nil {
+			index.Name = *name
+		}
+		if definition != nil {
+			index.Definition = *definition
+		}
+		if sizeMb != nil {
+			index.SizeMB = *sizeMb
+		}
+		index.Unique = unique != nil && *unique
+		index.Primary = primary != nil && *primary
+		indexes = append(indexes, index)
+	}
+	if err = rows.Err(); err != nil {
+		logger.Log.Error("error while iterating summary indexes", zap.Error(err))
+		return nil, domain.HandlePGError(err)
+	}
+
+	if !found {
+		return nil, domain.NewNotFoundError(fmt.Sprintf("summary with id %s not found", id))
+	}
+	return indexes, nil
+}
+
+func (lRepo *LocalRepository) GetTableColumns(ctx context.Context, summaryId string, tableId string) ([]domain.TableColumn, error) {
+	if summaryId == "" || tableId == "" {
+		return nil, domain.NewBadRequestError("summary id and table id cannot be empty strings")
+	}
+
+	query := `
+	SELECT c.id, c.position, c.name, c.data_type, c.nullable, c.column_default, c.null_frac, c.n_distinct, c.avg_width
+	FROM tables t
+	JOIN schemas sc ON sc.id = t.schema_id
+	LEFT JOIN columns c ON c.table_id = t.id
+	WHERE sc.summary_id = $1 AND t.id = $2
+	ORDER BY c.position;
+	`
+
+	rows, err := lRepo.db.Query(ctx, query, summaryId, tableId)
+	if err != nil {
+		logger.Log.Error("error while fetching table columns", zap.Error(err), zap.String("summary id", summaryId), zap.String("table id", tableId))
+		return nil, domain.HandlePGError(err)
+	}
+	defer rows.Close()
+
+	// the left join gives one row without a column for a table that has none, so found tells the table exists
+	found := false
+	columns := []domain.TableColumn{}
+	for rows.Next() {
+		var (
+			columnID *string
+			position *int
+			name     *string
+			dataType *string
+			nullable *bool
+			column   domain.TableColumn
+		)
+		if err = rows.Scan(&columnID, &position, &name, &dataType, &nullable,
+			&column.Default, &column.NullFrac, &column.NDistinct, &column.AvgWidth); err != nil {
+			logger.Log.Error("error while s-caning table columns", zap.Error(err))
+			return nil, domain.HandlePGError(err)
+		}
+		found = true
+		if columnID == nil {
+			continue
+		}
+
+		column.Id, column.TableId = *columnID, tableId
+		if position != nil {
+			column.Position = *position
+		}
+		if name != nil {
+			column.Name = *name
+		}
+		if dataType != nil {
+			column.DataType = *dataType
+		}
+		if nullable != nil {
+			column.Nullable = *nullable
+		}
+		columns = append(columns, column)
+	}
+	if err = rows.Err(); err != nil {
+		logger.Log.Error("error while iterating table columns", zap.Error(err))
+		return nil, domain.HandlePGError(err)
+	}
+
+	if !found {
+		return nil, domain.NewNotFoundError(fmt.Sprintf("table with id %s not found in summary %s", tableId, summaryId))
+	}
+	return columns, nil
+}
+
 func (lRepo *LocalRepository) GetSummary(ctx context.Context, offset int, limit int) ([]domain.LocalSummaryListItem, error) {
+	var items []domain.LocalSummaryListItem
+	err := lRepo.StreamSummaries(ctx, offset, limit, func(item domain.LocalSummaryListItem) error {
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (lRepo *LocalRepository) StreamSummaries(ctx context.Context, offset int, limit int, fn func(domain.LocalSummaryListItem) error) error {
 
 	query := `SELECT id, source_info, synced_at 
 	          FROM summaries 
@@ -147,18 +410,331 @@ func (lRepo *LocalRepository) GetSummary(ctx context.Context, offset int, limit
 	rows, err := lRepo.db.Query(ctx, query, limit, offset)
 	if err != nil {
 		logger.Log.Error("error while fetching summaries", zap.Error(err))
-		return nil, domain.HandlePGError(err)
+		return domain.HandlePGError(err)
 	}
 	defer rows.Close()
 
-	var items []domain.LocalSummaryListItem
 	for rows.Next() {
 		var item domain.LocalSummaryListItem
 		if err = rows.Scan(&item.ID, &item.DBName, &item.SyncedAt); err != nil { // Note: source_info as DBName for list
 			logger.Log.Error("error while s-caning summaries", zap.Error(err))
+			return domain.HandlePGError(err)
+		}
+		if err = fn(item); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		logger.Log.Error("error while iterating summaries", zap.Error(err))
+		return domain.HandlePGError(err)
+	}
+	return nil
+}
+
+func (lRepo *LocalRepository) GetSourceSummaries(ctx context.Context, src string, offset int, limit int) ([]domain.LocalSummaryListItem, error) {
+	if src == "" {
+		return nil, domain.NewBadRequestError("src cannot be an empty string")
+	}
+
+	query := `SELECT id, source_info, synced_at
+	          FROM summaries
+	          WHERE source_info = $1
+	          ORDER BY synced_at DESC
+	          LIMIT $2 OFFSET $3`
+	rows, err := lRepo.db.Query(ctx, query, src, limit, offset)
+	if err != nil {
+		logger.Log.Error("error while fetching source summaries", zap.Error(err), zap.String("source", src))
+		return nil, domain.HandlePGError(err)
+	}
+	defer rows.Close()
+
+	var items []domain.LocalSummaryListItem
+	for rows.Next() {
+		var item domain.LocalSummaryListItem
+		if err = rows.Scan(&item.ID, &item.DBName, &item.SyncedAt); err != nil {
+			logger.Log.Error("error while s-caning source summaries", zap.Error(err))
 			return nil, domain.HandlePGError(err)
 		}
 		items = append(items, item)
 	}
 	return items, nil
 }
+
+func (lRepo *LocalRepository) GetLatestSummaries(ctx context.Context) ([]domain.LocalSummaryListItem, error) {
+
+	query := `SELECT DISTINCT ON (source_info) id, source_info, synced_at
+	          FROM summaries
+	          ORDER BY source_info, synced_at DESC`
+	rows, err := lRepo.db.Query(ctx, query)
+	if err != nil {
+		logger.Log.Error("error while fetching latest summaries", zap.Error(err))
+		return nil, domain.HandlePGError(err)
+	}
+	defer rows.Close()
+
+	var items []domain.LocalSummaryListItem
+	for rows.Next() {
+		var item domain.LocalSummaryListItem
+		if err = rows.Scan(&item.ID, &item.DBName, &item.SyncedAt); err != nil {
+			logger.Log.Error("error while s-caning latest summaries", zap.Error(err))
+			return nil, domain.HandlePGError(err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// GetLatestSnapshots reads the latest summary of every source joined with its tables in one query
+func (lRepo *LocalRepository) GetLatestSnapshots(ctx context.Context) ([]domain.SourceSnapshot, error) {
+	query := `
+	WITH latest AS (
+		SELECT DISTINCT ON (source_info) id, source_info, synced_at
+		FROM summaries
+		ORDER BY source_info, synced_at DESC
+	)
+	SELECT l.id, l.synced_at, sc.name, t.id, t.name, t.row_count, t.size_mb,
+		t.heap_size_mb, t.index_size_mb, t.toast_size_mb, t.dead_tuples,
+		t.last_vacuum, t.last_autovacuum, t.last_analyze, t.seq_scans, t.idx_scans,
+		l.source_info
+	FROM latest l
+	LEFT JOIN schemas sc ON sc.summary_id = l.id
+	LEFT JOIN tables t ON t.schema_id = sc.id
+	ORDER BY l.source_info, sc.name, t.name;
+	`
+
+	rows, err := lRepo.db.Query(ctx, query)
+	if err != nil {
+		logger.Log.Error("error while fetching latest snapshots", zap.Error(err))
+		return nil, domain.HandlePGError(err)
+	}
+	defer rows.Close()
+
+	snapshots := []domain.SourceSnapshot{}
+	for rows.Next() {
+		var source string
+		table, ok, err := scanSummaryTable(rows, &source)
+		if err != nil {
+			logger.Log.Error("error while s-caning latest snapshots", zap.Error(err))
+			return nil, domain.HandlePGError(err)
+		}
+		// rows come grouped by source, a summary without tables has one row without a table
+		if n := len(snapshots); n == 0 || snapshots[n-1].Source != source {
+			snapshots = append(snapshots, domain.SourceSnapshot{Source: source, SummaryId: table.SummaryId,
+				SyncedAt: table.SyncedAt, Tables: []domain.SummaryTable{}})
+		}
+		if ok {
+			snapshots[len(snapshots)-1].Tables = append(snapshots[len(snapshots)-1].Tables, table)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		logger.Log.Error("error while iterating latest snapshots", zap.Error(err))
+		return nil, domain.HandlePGError(err)
+	}
+	return snapshots, nil
+}
+
+func (lRepo *LocalRepository) DeleteSummary(ctx context.Context, id string) (*domain.LocalSummaryListItem, error) {
+	if id == "" {
+		return nil, domain.NewBadRequestError("id cannot be an empty string")
+	}
+
+	// the foreign keys have no ON DELETE CASCADE, so children go first, all in one transaction
+	tx, err := lRepo.db.Begin(ctx)
+	if err != nil {
+		logger.Log.Error("error while starting delete transaction", zap.Error(err))
+		return nil, domain.HandlePGError(err)
+	}
+	defer tx.Rollback(ctx) // no-op once committed
+
+	queries := []string{
+		`DELETE FROM columns WHERE table_id IN (
+			SELECT t.id FROM tables t JOIN schemas sc ON sc.id = t.schema_id WHERE sc.summary_id = $1)`,
+		`DELETE FROM indexes WHERE table_id IN (
+			SELECT t.id FROM tables t JOIN schemas sc ON sc.id = t.schema_id WHERE sc.summary_id = $1)`,
+		`DELETE FROM tables WHERE schema_id IN (SELECT id FROM schemas WHERE summary_id = $1)`,
+		`DELETE FROM schemas WHERE summary_id = $1`,
+	}
+	for _, q := range queries {
+		if _, err = tx.Exec(ctx, q, id); err != nil {
+			logger.Log.Error("error while deleting summary children", zap.Error(err), zap.String("summary id", id))
+			return nil, domain.HandlePGError(err)
+		}
+	}
+
+	var item domain.LocalSummaryListItem
+	err = tx.QueryRow(ctx, `DELETE FROM summaries WHERE id = $1 RETURNING id, source_info, synced_at`, id).
+		Scan(&item.ID, &item.DBName, &item.SyncedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.NewNotFoundError(fmt.Sprintf("summary with id %s not found", id))
+	} else if err != nil {
+		logger.Log.Error("error while deleting summary", zap.Error(err), zap.String("summary id", id))
+		return nil, domain.HandlePGError(err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		logger.Log.Error("error while committing summary delete", zap.Error(err), zap.String("summary id", id))
+		return nil, domain.HandlePGError(err)
+	}
+	return &item, nil
+}
+
+func (lRepo *LocalRepository) GetSummaryTables(ctx context.Context, id string) ([]domain.SummaryTable, error) {
+	tables := []domain.SummaryTable{}
+	err := lRepo.StreamSummaryTables(ctx, id, func(table domain.SummaryTable) error {
+		tables = append(tables, table)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+func (lRepo *LocalRepository) StreamSummaryTables(ctx context.Context, id string, fn func(domain.SummaryTable) error) error {
+	if id == "" {
+		return domain.NewBadRequestError("id cannot be an empty string")
+	}
+
+	query := `
+	SELECT s.id, s.synced_at, sc.name, t.id, t.name, t.row_count, t.size_mb,
+		t.heap_size_mb, t.index_size_mb, t.toast_size_mb, t.dead_tuples,
+		t.last_vacuum, t.last_autovacuum, t.last_analyze, t.seq_scans, t.idx_scans
+	FROM summaries s
+	LEFT JOIN schemas sc ON sc.summary_id = s.id
+	LEFT JOIN tables t ON t.schema_id = sc.id
+	WHERE s.id = $1
+	ORDER BY sc.name, t.name;
+	`
+
+	rows, err := lRepo.db.Query(ctx, query, id)
+	if err != nil {
+		logger.Log.Error("error while fetching summary tables", zap.Error(err), zap.Any("summary id", id))
+		return domain.HandlePGError(err)
+	}
+	defer rows.Close()
+
+	// the left joins give one row without a table for a summary that has none, so found tells it exists
+	found := false
+	for rows.Next() {
+		table, ok, err := scanSummaryTable(rows)
+		if err != nil {
+			logger.Log.Error("error while s-caning summary tables", zap.Error(err))
+			return domain.HandlePGError(err)
+		}
+		found = true
+		if !ok {
+			continue
+		}
+		if err = fn(table); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		logger.Log.Error("error while iterating summary tables", zap.Error(err))
+		return domain.HandlePGError(err)
+	}
+
+	if !found {
+		return domain.NewNotFoundError(fmt.Sprintf("summary with id %s not found", id))
+	}
+	return nil
+}
+
+func (lRepo *LocalRepository) StreamSourceTables(ctx context.Context, src string, fn func(domain.SummaryTable) error) error {
+	if src == "" {
+		return domain.NewBadRequestError("src cannot be an empty string")
+	}
+
+	query := `
+	SELECT s.id, s.synced_at, sc.name, t.id, t.name, t.row_count, t.size_mb,
+		t.heap_size_mb, t.index_size_mb, t.toast_size_mb, t.dead_tuples,
+		t.last_vacuum, t.last_autovacuum, t.last_analyze, t.seq_scans, t.idx_scans
+	FROM summaries s
+	LEFT JOIN schemas sc ON sc.summary_id = s.id
+	LEFT JOIN tables t ON t.schema_id = sc.id
+	WHERE s.source_info = $1
+	ORDER BY s.synced_at DESC, sc.name, t.name;
+	`
+
+	// rows are handed to fn as they arrive from the cursor, nothing is buffered here
+	rows, err := lRepo.db.Query(ctx, query, src)
+	if err != nil {
+		logger.Log.Error("error while streaming source tables", zap.Error(err), zap.String("source", src))
+		return domain.HandlePGError(err)
+	}
+	defer rows.Close()
+
+	// the left joins give a row without a table for a summary that has none, so found tells the source exists
+	found := false
+	for rows.Next() {
+		table, ok, err := scanSummaryTable(rows)
+		if err != nil {
+			logger.Log.Error("error while s-caning source tables", zap.Error(err))
+			return domain.HandlePGError(err)
+		}
+		found = true
+		if !ok {
+			continue
+		}
+		if err = fn(table); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		logger.Log.Error("error while iterating source tables", zap.Error(err))
+		return domain.HandlePGError(err)
+	}
+
+	if !found {
+		return domain.NewNotFoundError(fmt.Sprintf("no summaries found for source %s", src))
+	}
+	return nil
+}
+
+// scanSummaryTable scans one row of the summary/schema/table join, extra takes the columns selected after the
+// table ones; ok is false when the row carries no table
+func scanSummaryTable(rows pgx.Rows, extra ...any) (domain.SummaryTable, bool, error) {
+	var (
+		table      domain.SummaryTable
+		schemaName *string
+		tableID    *string
+		tableName  *string
+		rowCount   *int64
+		sizeMb     *float64
+	)
+	stats := &table.TableStats
+	dest := []any{&table.SummaryId, &table.SyncedAt, &schemaName, &tableID, &tableName, &rowCount, &sizeMb,
+		&stats.HeapSizeMB, &stats.IndexSizeMB, &stats.ToastSizeMB, &stats.DeadTuples,
+		&stats.LastVacuum, &stats.LastAutovacuum, &stats.LastAnalyze, &stats.SeqScans, &stats.IdxScans}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return table, false, err
+	}
+	utcTimes(stats.LastVacuum, stats.LastAutovacuum, stats.LastAnalyze)
+	if tableID == nil {
+		return table, false, nil
+	}
+
+	table.Id = *tableID
+	if schemaName != nil {
+		table.Schema = *schemaName
+	}
+	if tableName != nil {
+		table.Name = *tableName
+	}
+	if rowCount != nil {
+		table.TotalRows = *rowCount
+	}
+	if sizeMb != nil {
+		table.SizeMB = *sizeMb
+	}
+	return table, true, nil
+}
+
+// utcTimes moves the timestamptz values pgx scans in the local zone to UTC, as the memory store keeps them
+func utcTimes(times ...*time.Time) {
+	for _, t := range times {
+		if t != nil {
+			*t = t.UTC()
+		}
+	}
+}
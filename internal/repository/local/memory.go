@@ -0,0 +1,510 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pg-summary-service/internal/domain"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryRepository is a thread-safe in-memory Local with the same semantics as LocalRepository.
+// note: meant for development and tests, nothing survives a restart unless a snapshot is saved
+type MemoryRepository struct {
+	mu        sync.RWMutex
+	summaries map[string]*memorySummary
+}
+
+type memorySummary struct {
+	ID       string         `json:"id"`
+	Source   string         `json:"source"`
+	SyncedAt time.Time      `json:"synced_at"`
+	Schemas  []memorySchema `json:"schemas"`
+}
+
+type memorySchema struct {
+	ID     string        `json:"id"`
+	Name   string        `json:"name"`
+	Tables []memoryTable `json:"tables"`
+}
+
+type memoryTable struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	TotalRows int64   `json:"row_count"`
+	SizeMB    float64 `json:"size_mb"`
+	domain.TableStats
+	Columns []domain.TableColumn `json:"columns,omitempty"`
+	Indexes []domain.TableIndex  `json:"indexes,omitempty"`
+}
+
+// memorySnapshot is the on-disk format of SaveSnapshot
+type memorySnapshot struct {
+	Version   int             `json:"version"`
+	SavedAt   time.Time       `json:"saved_at"`
+	Summaries []memorySummary `json:"summaries"`
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{summaries: map[string]*memorySummary{}}
+}
+
+// LoadMemorySnapshot builds a repository from a snapshot file, a missing file gives an empty repository
+func LoadMemorySnapshot(path string) (*MemoryRepository, error) {
+	mRepo := NewMemoryRepository()
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return mRepo, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read memory snapshot: %w", err)
+	}
+
+	var snapshot memorySnapshot
+	if err = json.Unmarshal(raw, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse memory snapshot %s: %w", path, err)
+	}
+	for i := range snapshot.Summaries {
+		s := snapshot.Summaries[i]
+		mRepo.summaries[s.ID] = &s
+	}
+	return mRepo, nil
+}
+
+// SaveSnapshot writes every summary to path as JSON, the file is replaced atomically
+func (mRepo *MemoryRepository) SaveSnapshot(path string) error {
+	mRepo.mu.RLock()
+	snapshot := memorySnapshot{Version: 1, SavedAt: time.Now().UTC(), Summaries: make([]memorySummary, 0, len(mRepo.summaries))}
+	for _, s := range mRepo.sortedLocked() {
+		snapshot.Summaries = append(snapshot.Summaries, *s)
+	}
+	mRepo.mu.RUnlock()
+
+	raw, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode memory snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write memory snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+
+	if _, err = tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write memory snapshot: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write memory snapshot: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write memory snapshot: %w", err)
+	}
+	return nil
+}
+
+func (mRepo *MemoryRepository) AddSummary(ctx context.Context, src string, data *domain.ExternalSummaryResp) (any, error) {
+
+	if src == "" {
+		return nil, domain.NewBadRequestError("src cannot be an empty string")
+	} else if data == nil {
+		return nil, domain.NewBadRequestError("data cannot be an empty")
+	}
+
+	// postgres keeps microseconds and no location, match it so both stores return the same values
+	summary := &memorySummary{
+		ID:       data.Id,
+		Source:   src,
+		SyncedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	for _, schema := range data.Schemas {
+		ms := memorySchema{ID: uuid.New().String(), Name: schema.Name}
+		for _, table := range schema.Tables {
+			mt := memoryTable{
+				ID:         uuid.New().String(),
+				Name:       table.Name,
+				TotalRows:  int64(table.TotalRows),
+				SizeMB:     table.Size,
+				TableStats: copyStats(table.TableStats),
+			}
+			for i, column := range table.Columns {
+				mt.Columns = append(mt.Columns, copyColumn(domain.TableColumn{
+					Id: uuid.New().String(), TableId: mt.ID, Position: i + 1, Column: column,
+				}))
+			}
+			for _, index := range table.Indexes {
+				mt.Indexes = append(mt.Indexes, copyIndex(domain.TableIndex{
+					Id: uuid.New().String(), TableId: mt.ID, Schema: schema.Name, Table: table.Name, Index: index,
+				}))
+			}
+			ms.Tables = append(ms.Tables, mt)
+		}
+		summary.Schemas = append(summary.Schemas, ms)
+	}
+
+	mRepo.mu.Lock()
+	if _, ok := mRepo.summaries[summary.ID]; ok {
+		mRepo.mu.Unlock()
+		return nil, domain.NewBadRequestError("duplicate entry, already exists")
+	}
+	mRepo.summaries[summary.ID] = summary
+	mRepo.mu.Unlock()
+
+	// the summary is stored at once, so there is a single report with everything written
+	progress := data.Totals()
+	progress.Schemas, progress.Tables = progress.SchemasTotal, progress.TablesTotal
+	domain.ReportProgress(ctx, progress)
+	return nil, nil
+}
+
+func (mRepo *MemoryRepository) GetSummaryById(ctx context.Context, id string) (*domain.LocalSummaryByIdResp, error) {
+	if id == "" {
+		return nil, domain.NewBadRequestError("id cannot be an empty string")
+	}
+
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+
+	s, ok := mRepo.summaries[id]
+	if !ok {
+		return nil, domain.NewNotFoundError(fmt.Sprintf("summary with id %s not found", id))
+	}
+
+	resp := &domain.LocalSummaryByIdResp{ID: s.ID, Source: s.Source, SyncedAt: s.SyncedAt}
+	for _, schema := range s.Schemas {
+		agg := domain.SchemaSummary{Id: schema.ID, Name: schema.Name, TableCount: len(schema.Tables)}
+		for _, t := range schema.Tables {
+			agg.TotalRows += t.TotalRows
+			agg.TotalSizeMB += t.SizeMB
+			agg.Add(t.TableStats)
+		}
+		resp.Schemas = append(resp.Schemas, agg)
+	}
+	return resp, nil
+}
+
+func (mRepo *MemoryRepository) GetSummary(ctx context.Context, offset int, limit int) ([]domain.LocalSummaryListItem, error) {
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+	return page(mRepo.sortedLocked(), offset, limit), nil
+}
+
+func (mRepo *MemoryRepository) StreamSummaries(ctx context.Context, offset int, limit int, fn func(domain.LocalSummaryListItem) error) error {
+	items, _ := mRepo.GetSummary(ctx, offset, limit)
+	for _, item := range items {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mRepo *MemoryRepository) GetSourceSummaries(ctx context.Context, src string, offset int, limit int) ([]domain.LocalSummaryListItem, error) {
+	if src == "" {
+		return nil, domain.NewBadRequestError("src cannot be an empty string")
+	}
+
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+
+	var matching []*memorySummary
+	for _, s := range mRepo.sortedLocked() {
+		if s.Source == src {
+			matching = append(matching, s)
+		}
+	}
+	return page(matching, offset, limit), nil
+}
+
+func (mRepo *MemoryRepository) GetLatestSummaries(ctx context.Context) ([]domain.LocalSummaryListItem, error) {
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+
+	latest := map[string]*memorySummary{}
+	for _, s := range mRepo.summaries {
+		if cur, ok := latest[s.Source]; !ok || s.SyncedAt.After(cur.SyncedAt) {
+			latest[s.Source] = s
+		}
+	}
+
+	var items []domain.LocalSummaryListItem
+	for _, s := range latest {
+		items = append(items, listItem(s))
+	}
+	sort.Slice(items, func(a, b int) bool {
+		return items[a].DBName < items[b].DBName
+	})
+	return items, nil
+}
+
+func (mRepo *MemoryRepository) GetLatestSnapshots(ctx context.Context) ([]domain.SourceSnapshot, error) {
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+
+	latest := map[string]*memorySummary{}
+	for _, s := range mRepo.summaries {
+		if cur, ok := latest[s.Source]; !ok || s.SyncedAt.After(cur.SyncedAt) {
+			latest[s.Source] = s
+		}
+	}
+
+	snapshots := make([]domain.SourceSnapshot, 0, len(latest))
+	for _, s := range latest {
+		snapshots = append(snapshots, domain.SourceSnapshot{Source: s.Source, SummaryId: s.ID, SyncedAt: s.SyncedAt, Tables: summaryTables(s)})
+	}
+	sort.Slice(snapshots, func(a, b int) bool {
+		return snapshots[a].Source < snapshots[b].Source
+	})
+	return snapshots, nil
+}
+
+func (mRepo *MemoryRepository) DeleteSummary(ctx context.Context, id string) (*domain.LocalSummaryListItem, error) {
+	if id == "" {
+		return nil, domain.NewBadRequestError("id cannot be an empty string")
+	}
+
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+
+	s, ok := mRepo.summaries[id]
+	if !ok {
+		return nil, domain.NewNotFoundError(fmt.Sprintf("summary with id %s not found", id))
+	}
+	delete(mRepo.summaries, id)
+	item := listItem(s)
+	return &item, nil
+}
+
+func (mRepo *MemoryRepository) GetSummaryTables(ctx context.Context, id string) ([]domain.SummaryTable, error) {
+	if id == "" {
+		return nil, domain.NewBadRequestError("id cannot be an empty string")
+	}
+
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+
+	s, ok := mRepo.summaries[id]
+	if !ok {
+		return nil, domain.NewNotFoundError(fmt.Sprintf("summary with id %s not found", id))
+	}
+	return summaryTables(s), nil
+}
+
+func (mRepo *MemoryRepository) StreamSummaryTables(ctx context.Context, id string, fn func(domain.SummaryTable) error) error {
+	// GetSummaryTables copies the rows, fn runs without the lock
+	tables, err := mRepo.GetSummaryTables(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if err = fn(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mRepo *MemoryRepository) GetSummaryIndexes(ctx context.Context, id string) ([]domain.TableIndex, error) {
+	if id == "" {
+		return nil, domain.NewBadRequestError("id cannot be an empty string")
+	}
+
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+
+	s, ok := mRepo.summaries[id]
+	if !ok {
+		return nil, domain.NewNotFoundError(fmt.Sprintf("summary with id %s not found", id))
+	}
+	indexes := []domain.TableIndex{}
+	for _, schema := range s.Schemas {
+		for _, t := range schema.Tables {
+			for _, index := range t.Indexes {
+				indexes = append(indexes, copyIndex(index))
+			}
+		}
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		x, y := indexes[a], indexes[b]
+		if x.Schema != y.Schema {
+			return x.Schema < y.Schema
+		}
+		if x.Table != y.Table {
+			return x.Table < y.Table
+		}
+		return x.Name < y.Name
+	})
+	return indexes, nil
+}
+
+func (mRepo *MemoryRepository) GetTableColumns(ctx context.Context, summaryId string, tableId string) ([]domain.TableColumn, error) {
+	if summaryId == "" || tableId == "" {
+		return nil, domain.NewBadRequestError("summary id and table id cannot be empty strings")
+	}
+
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+
+	if s, ok := mRepo.summaries[summaryId]; ok {
+		for _, schema := range s.Schemas {
+			for _, t := range schema.Tables {
+				if t.ID != tableId {
+					continue
+				}
+				columns := make([]domain.TableColumn, 0, len(t.Columns))
+				for _, column := range t.Columns {
+					columns = append(columns, copyColumn(column))
+				}
+				return columns, nil
+			}
+		}
+	}
+	return nil, domain.NewNotFoundError(fmt.Sprintf("table with id %s not found in summary %s", tableId, summaryId))
+}
+
+func (mRepo *MemoryRepository) StreamSourceTables(ctx context.Context, src string, fn func(domain.SummaryTable) error) error {
+	if src == "" {
+		return domain.NewBadRequestError("src cannot be an empty string")
+	}
+
+	// rows are copied under the lock so a slow fn does not block writers
+	mRepo.mu.RLock()
+	var rows []domain.SummaryTable
+	found := false
+	for _, s := range mRepo.sortedLocked() {
+		if s.Source == src {
+			found = true
+			rows = append(rows, summaryTables(s)...)
+		}
+	}
+	mRepo.mu.RUnlock()
+
+	if !found {
+		return domain.NewNotFoundError(fmt.Sprintf("no summaries found for source %s", src))
+	}
+	for _, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedLocked returns the summaries newest first, callers hold mu
+func (mRepo *MemoryRepository) sortedLocked() []*memorySummary {
+	sorted := make([]*memorySummary, 0, len(mRepo.summaries))
+	for _, s := range mRepo.summaries {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		if !sorted[a].SyncedAt.Equal(sorted[b].SyncedAt) {
+			return sorted[a].SyncedAt.After(sorted[b].SyncedAt)
+		}
+		return sorted[a].ID < sorted[b].ID
+	})
+	return sorted
+}
+
+// page applies offset and limit like LIMIT/OFFSET does, an empty page is nil as with the postgres scan loop
+func page(sorted []*memorySummary, offset, limit int) []domain.LocalSummaryListItem {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(sorted) || limit <= 0 {
+		return nil
+	}
+	end := min(offset+limit, len(sorted))
+
+	var items []domain.LocalSummaryListItem
+	for _, s := range sorted[offset:end] {
+		items = append(items, listItem(s))
+	}
+	return items
+}
+
+func listItem(s *memorySummary) domain.LocalSummaryListItem {
+	return domain.LocalSummaryListItem{ID: s.ID, DBName: s.Source, SyncedAt: s.SyncedAt}
+}
+
+// summaryTables flattens a summary ordered by schema then table name
+func summaryTables(s *memorySummary) []domain.SummaryTable {
+	tables := []domain.SummaryTable{}
+	for _, schema := range s.Schemas {
+		for _, t := range schema.Tables {
+			tables = append(tables, domain.SummaryTable{
+				Id:         t.ID,
+				SummaryId:  s.ID,
+				SyncedAt:   s.SyncedAt,
+				Schema:     schema.Name,
+				Name:       t.Name,
+				TotalRows:  t.TotalRows,
+				SizeMB:     t.SizeMB,
+				TableStats: copyStats(t.TableStats),
+			})
+		}
+	}
+	sort.SliceStable(tables, func(a, b int) bool {
+		if tables[a].Schema != tables[b].Schema {
+			return tables[a].Schema < tables[b].Schema
+		}
+		return tables[a].Name < tables[b].Name
+	})
+	return tables
+}
+
+// copyStats copies the stats so the stored ones are not shared with callers, the times kept in UTC to the
+// microsecond like postgres keeps them
+func copyStats(stats domain.TableStats) domain.TableStats {
+	copyTime := func(v *time.Time) *time.Time {
+		if v == nil {
+			return nil
+		}
+		c := v.UTC().Truncate(time.Microsecond)
+		return &c
+	}
+	return domain.TableStats{
+		HeapSizeMB:     copyOf(stats.HeapSizeMB),
+		IndexSizeMB:    copyOf(stats.IndexSizeMB),
+		ToastSizeMB:    copyOf(stats.ToastSizeMB),
+		DeadTuples:     copyOf(stats.DeadTuples),
+		LastVacuum:     copyTime(stats.LastVacuum),
+		LastAutovacuum: copyTime(stats.LastAutovacuum),
+		LastAnalyze:    copyTime(stats.LastAnalyze),
+		SeqScans:       copyOf(stats.SeqScans),
+		IdxScans:       copyOf(stats.IdxScans),
+	}
+}
+
+// copyColumn copies the column so the stored one is not shared with callers
+func copyColumn(column domain.TableColumn) domain.TableColumn {
+	column.Default = copyOf(column.Default)
+	column.NullFrac = copyOf(column.NullFrac)
+	column.NDistinct = copyOf(column.NDistinct)
+	column.AvgWidth = copyOf(column.AvgWidth)
+	return column
+}
+
+// copyIndex copies the index so the stored one is not shared with callers
+func copyIndex(index domain.TableIndex) domain.TableIndex {
+	index.Scans = copyOf(index.Scans)
+	return index
+}
+
+func copyOf[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
@@ -0,0 +1,16 @@
+package webhook
+
+import (
+	"context"
+	"pg-summary-service/internal/domain"
+)
+
+type Store interface {
+	AddWebhook(ctx context.Context, hook *domain.Webhook) error
+	GetWebhook(ctx context.Context, id string) (*domain.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]domain.Webhook, error)
+	UpdateWebhook(ctx context.Context, hook *domain.Webhook) error
+	DeleteWebhook(ctx context.Context, id string) error
+	AddDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error
+	ListDeliveries(ctx context.Context, webhookId string, offset int, limit int) ([]domain.WebhookDelivery, error)
+}
@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"pg-summary-service/internal/domain"
+	"sort"
+	"sync"
+)
+
+// MemoryRepository is a thread-safe in-memory Store, used with STORAGE=memory
+type MemoryRepository struct {
+	mu         sync.RWMutex
+	hooks      map[string]domain.Webhook
+	deliveries []domain.WebhookDelivery
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{hooks: map[string]domain.Webhook{}}
+}
+
+func (mRepo *MemoryRepository) AddWebhook(ctx context.Context, hook *domain.Webhook) error {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	if _, ok := mRepo.hooks[hook.Id]; ok {
+		return domain.NewBadRequestError("duplicate entry, already exists")
+	}
+	mRepo.hooks[hook.Id] = *hook
+	return nil
+}
+
+func (mRepo *MemoryRepository) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+	hook, ok := mRepo.hooks[id]
+	if !ok {
+		return nil, domain.NewNotFoundError(fmt.Sprintf("webhook with id %s not found", id))
+	}
+	return &hook, nil
+}
+
+func (mRepo *MemoryRepository) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+	hooks := make([]domain.Webhook, 0, len(mRepo.hooks))
+	for _, hook := range mRepo.hooks {
+		hooks = append(hooks, hook)
+	}
+	sort.Slice(hooks, func(a, b int) bool {
+		return hooks[a].CreatedAt.Before(hooks[b].CreatedAt)
+	})
+	return hooks, nil
+}
+
+func (mRepo *MemoryRepository) UpdateWebhook(ctx context.Context, hook *domain.Webhook) error {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	if _, ok := mRepo.hooks[hook.Id]; !ok {
+		return domain.NewNotFoundError(fmt.Sprintf("webhook with id %s not found", hook.Id))
+	}
+	mRepo.hooks[hook.Id] = *hook
+	return nil
+}
+
+func (mRepo *MemoryRepository) DeleteWebhook(ctx context.Context, id string) error {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	if _, ok := mRepo.hooks[id]; !ok {
+		return domain.NewNotFoundError(fmt.Sprintf("webhook with id %s not found", id))
+	}
+	delete(mRepo.hooks, id)
+
+	kept := mRepo.deliveries[:0]
+	for _, d := range mRepo.deliveries {
+		if d.WebhookId != id {
+			kept = append(kept, d)
+		}
+	}
+	mRepo.deliveries = kept
+	return nil
+}
+
+func (mRepo *MemoryRepository) AddDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	mRepo.deliveries = append(mRepo.deliveries, *delivery)
+	return nil
+}
+
+func (mRepo *MemoryRepository) ListDeliveries(ctx context.Context, webhookId string, offset int, limit int) ([]domain.WebhookDelivery, error) {
+	mRepo.mu.RLock()
+	defer mRepo.mu.RUnlock()
+
+	// newest first, deliveries are appended in time order
+	matching := []domain.WebhookDelivery{}
+	for i := len(mRepo.deliveries) - 1; i >= 0; i-- {
+		if mRepo.deliveries[i].WebhookId == webhookId {
+			matching = append(matching, mRepo.deliveries[i])
+		}
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(matching) {
+		return []domain.WebhookDelivery{}, nil
+	}
+	return matching[offset:min(offset+limit, len(matching))], nil
+}
@@ -0,0 +1,169 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/logger"
+)
+
+type WebhookRepository struct {
+	db *pgxpool.Pool
+}
+
+// CreateTables creates the tables the repository works on, if missing
+func CreateTables(ctx context.Context, db *pgxpool.Pool) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS webhooks (id VARCHAR PRIMARY KEY, url VARCHAR NOT NULL, secret VARCHAR NOT NULL, events TEXT[] NOT NULL, active BOOLEAN NOT NULL, created_at TIMESTAMP)`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (id VARCHAR PRIMARY KEY, webhook_id VARCHAR REFERENCES webhooks(id) ON DELETE CASCADE, event_id VARCHAR, event_type VARCHAR, attempt INT, status_code INT, success BOOLEAN, error VARCHAR, duration_ms BIGINT, delivered_at TIMESTAMP)`,
+		`CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, delivered_at DESC)`,
+	}
+
+	for _, q := range queries {
+		if _, err := db.Exec(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewWebhookRepository(db *pgxpool.Pool) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (wRepo *WebhookRepository) AddWebhook(ctx context.Context, hook *domain.Webhook) error {
+	query := `INSERT INTO webhooks (id, url, secret, events, active, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := wRepo.db.Exec(ctx, query, hook.Id, hook.URL, hook.Secret, eventsToStrings(hook.Events), hook.Active, hook.CreatedAt); err != nil {
+		logger.Log.Error("error while saving webhook", zap.Error(err), zap.String("url", hook.URL))
+		return domain.HandlePGError(err)
+	}
+	return nil
+}
+
+func (wRepo *WebhookRepository) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	query := `SELECT id, url, secret, events, active, created_at FROM webhooks WHERE id = $1`
+	hook, err := scanWebhook(wRepo.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.NewNotFoundError(fmt.Sprintf("webhook with id %s not found", id))
+	} else if err != nil {
+		logger.Log.Error("error while fetching webhook", zap.Error(err), zap.String("webhook id", id))
+		return nil, domain.HandlePGError(err)
+	}
+	return hook, nil
+}
+
+func (wRepo *WebhookRepository) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	query := `SELECT id, url, secret, events, active, created_at FROM webhooks ORDER BY created_at`
+	rows, err := wRepo.db.Query(ctx, query)
+	if err != nil {
+		logger.Log.Error("error while fetching webhooks", zap.Error(err))
+		return nil, domain.HandlePGError(err)
+	}
+	defer rows.Close()
+
+	hooks := []domain.Webhook{}
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			logger.Log.Error("error while s-caning webhooks", zap.Error(err))
+			return nil, domain.HandlePGError(err)
+		}
+		hooks = append(hooks, *hook)
+	}
+	return hooks, nil
+}
+
+func (wRepo *WebhookRepository) UpdateWebhook(ctx context.Context, hook *domain.Webhook) error {
+	query := `UPDATE webhooks SET url = $2, secret = $3, events = $4, active = $5 WHERE id = $1`
+	tag, err := wRepo.db.Exec(ctx, query, hook.Id, hook.URL, hook.Secret, eventsToStrings(hook.Events), hook.Active)
+	if err != nil {
+		logger.Log.Error("error while updating webhook", zap.Error(err), zap.String("webhook id", hook.Id))
+		return domain.HandlePGError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.NewNotFoundError(fmt.Sprintf("webhook with id %s not found", hook.Id))
+	}
+	return nil
+}
+
+func (wRepo *WebhookRepository) DeleteWebhook(ctx context.Context, id string) error {
+	// deliveries go with the webhook (ON DELETE CASCADE)
+	tag, err := wRepo.db.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		logger.Log.Error("error while deleting webhook", zap.Error(err), zap.String("webhook id", id))
+		return domain.HandlePGError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.NewNotFoundError(fmt.Sprintf("webhook with id %s not found", id))
+	}
+	return nil
+}
+
+func (wRepo *WebhookRepository) AddDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	query := `INSERT INTO webhook_deliveries
+		(id, webhook_id, event_id, event_type, attempt, status_code, success, error, duration_ms, delivered_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err := wRepo.db.Exec(ctx, query, d.Id, d.WebhookId, d.EventId, string(d.EventType), d.Attempt,
+		d.StatusCode, d.Success, d.Error, d.DurationMs, d.DeliveredAt)
+	if err != nil {
+		logger.Log.Error("error while saving webhook delivery", zap.Error(err), zap.String("webhook id", d.WebhookId))
+		return domain.HandlePGError(err)
+	}
+	return nil
+}
+
+func (wRepo *WebhookRepository) ListDeliveries(ctx context.Context, webhookId string, offset int, limit int) ([]domain.WebhookDelivery, error) {
+	query := `SELECT id, webhook_id, event_id, event_type, attempt, status_code, success, error, duration_ms, delivered_at
+	          FROM webhook_deliveries
+	          WHERE webhook_id = $1
+	          ORDER BY delivered_at DESC
+	          LIMIT $2 OFFSET $3`
+	rows, err := wRepo.db.Query(ctx, query, webhookId, limit, offset)
+	if err != nil {
+		logger.Log.Error("error while fetching webhook deliveries", zap.Error(err), zap.String("webhook id", webhookId))
+		return nil, domain.HandlePGError(err)
+	}
+	defer rows.Close()
+
+	deliveries := []domain.WebhookDelivery{}
+	for rows.Next() {
+		var (
+			d         domain.WebhookDelivery
+			eventType string
+		)
+		if err = rows.Scan(&d.Id, &d.WebhookId, &d.EventId, &eventType, &d.Attempt, &d.StatusCode,
+			&d.Success, &d.Error, &d.DurationMs, &d.DeliveredAt); err != nil {
+			logger.Log.Error("error while s-caning webhook deliveries", zap.Error(err))
+			return nil, domain.HandlePGError(err)
+		}
+		d.EventType = domain.EventType(eventType)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+func scanWebhook(row pgx.Row) (*domain.Webhook, error) {
+	var (
+		hook   domain.Webhook
+		events []string
+	)
+	if err := row.Scan(&hook.Id, &hook.URL, &hook.Secret, &events, &hook.Active, &hook.CreatedAt); err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		hook.Events = append(hook.Events, domain.EventType(e))
+	}
+	return &hook, nil
+}
+
+func eventsToStrings(events []domain.EventType) []string {
+	out := make([]string, 0, len(events))
+	for _, e := range events {
+		out = append(out, string(e))
+	}
+	return out
+}
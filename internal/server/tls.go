@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/logger"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// CertReloader serves the certificate of a cert/key pair and swaps it when the files change,
+// a pair that fails to load keeps the previous one in use
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	version string // mod times and sizes of the files the cert was loaded from
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the pair again
+func (r *CertReloader) Reload() error {
+	version, err := r.fileVersion()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.version = version
+	return nil
+}
+
+// GetCertificate is the tls.Config hook, every handshake gets the current certificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch reloads on SIGHUP and, when interval > 0, when the files change, until ctx is done
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.reload("SIGHUP")
+		case <-tick:
+			if version, err := r.fileVersion(); err == nil && version != r.currentVersion() {
+				r.reload("file change")
+			}
+		}
+	}
+}
+
+func (r *CertReloader) reload(reason string) {
+	if err := r.Reload(); err != nil {
+		logger.Log.Error("server certificate reload failed, keeping the current one", zap.String("reason", reason), zap.Error(err))
+		return
+	}
+	logger.Log.Info("server certificate reloaded", zap.String("reason", reason))
+}
+
+func (r *CertReloader) currentVersion() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.version
+}
+
+func (r *CertReloader) fileVersion() (string, error) {
+	var version strings.Builder
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read server certificate: %w", err)
+		}
+		fmt.Fprintf(&version, "%d/%d;", info.ModTime().UnixNano(), info.Size())
+	}
+	return version.String(), nil
+}
+
+// NewTLSConfig returns the server tls.Config of conf, certificates come from reloader
+func NewTLSConfig(conf domain.ServerTLS, reloader *CertReloader) (*tls.Config, error) {
+	tlsConf := &tls.Config{GetCertificate: reloader.GetCertificate, MinVersion: tls.VersionTLS12}
+
+	switch conf.MinVersion {
+	case "", "1.2":
+	case "1.3":
+		tlsConf.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported TLS min version %q, expected 1.2 or 1.3", conf.MinVersion)
+	}
+
+	if len(conf.CipherSuites) > 0 {
+		ids := map[string]uint16{}
+		for _, suite := range tls.CipherSuites() {
+			ids[suite.Name] = suite.ID
+		}
+		for _, name := range conf.CipherSuites {
+			id, ok := ids[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+			}
+			tlsConf.CipherSuites = append(tlsConf.CipherSuites, id)
+		}
+	}
+
+	if conf.ClientCAFile != "" {
+		pem, err := os.ReadFile(conf.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		tlsConf.ClientCAs = x509.NewCertPool()
+		if !tlsConf.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in client CA bundle %s", conf.ClientCAFile)
+		}
+		switch conf.ClientAuth {
+		case "", "require":
+			tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+		case "optional":
+			tlsConf.ClientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("unknown client auth %q, expected optional or require", conf.ClientAuth)
+		}
+	}
+	return tlsConf, nil
+}
+
+// RedirectHandler sends every plain http request to the same url over https on httpsPort.
+// 308 keeps the method and body, a client posting credentials should still be fixed to use https.
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"pg-summary-service/internal/domain"
+	logger2 "pg-summary-service/internal/logger"
+	"pg-summary-service/internal/repository/alert"
+	"pg-summary-service/internal/repository/local"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultAlertsLimit = 50
+
+// AlertEvaluator checks a freshly stored summary against the alert rules
+type AlertEvaluator interface {
+	Evaluate(ctx context.Context, summaryId, source string) ([]domain.Alert, error)
+}
+
+// AlertService manages alert rules, evaluates them after each sync and routes the alerts that fire
+type AlertService struct {
+	store     alert.Store
+	localRepo local.Local
+	sinks     []EventSink
+}
+
+// NewAlertService builds the service, sinks get the alerts routed to webhooks
+func NewAlertService(store alert.Store, localRepo local.Local, sinks ...EventSink) *AlertService {
+	return &AlertService{store: store, localRepo: localRepo, sinks: sinks}
+}
+
+func (as *AlertService) CreateRule(ctx context.Context, req domain.AlertRuleRequest) (*domain.AlertRule, error) {
+	if err := validateAlertRuleRequest(req); err != nil {
+		return nil, err
+	}
+
+	rule := &domain.AlertRule{
+		Id:        uuid.New().String(),
+		Enabled:   true,
+		CreatedAt: time.Now(),
+	}
+	applyAlertRuleRequest(rule, req)
+	if err := as.store.AddRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (as *AlertService) GetRule(ctx context.Context, id string) (*domain.AlertRule, error) {
+	return as.store.GetRule(ctx, id)
+}
+
+func (as *AlertService) ListRules(ctx context.Context) ([]domain.AlertRule, error) {
+	return as.store.ListRules(ctx)
+}
+
+func (as *AlertService) UpdateRule(ctx context.Context, id string, req domain.AlertRuleRequest) (*domain.AlertRule, error) {
+	if err := validateAlertRuleRequest(req); err != nil {
+		return nil, err
+	}
+
+	rule, err := as.store.GetRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	applyAlertRuleRequest(rule, req)
+	if err = as.store.UpdateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (as *AlertService) DeleteRule(ctx context.Context, id string) error {
+	return as.store.DeleteRule(ctx, id)
+}
+
+func (as *AlertService) ListAlerts(ctx context.Context, filter domain.AlertFilter) ([]domain.Alert, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAlertsLimit
+	}
+	return as.store.ListAlerts(ctx, filter)
+}
+
+func (as *AlertService) AcknowledgeAlert(ctx context.Context, id, by string) (*domain.Alert, error) {
+	if by == "" {
+		return nil, domain.NewBadRequestError("by cannot be empty")
+	}
+	return as.store.AcknowledgeAlert(ctx, id, by, time.Now())
+}
+
+// Evaluate runs every enabled rule of the source against the summary, stores and routes the alerts that fire
+func (as *AlertService) Evaluate(ctx context.Context, summaryId, source string) ([]domain.Alert, error) {
+	rules, err := as.store.ListRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		matching      []domain.AlertRule
+		needsPrevious bool
+	)
+	for _, rule := range rules {
+		if rule.Enabled && (rule.Source == "" || rule.Source == source) {
+			matching = append(matching, rule)
+			needsPrevious = needsPrevious || rule.Kind != domain.RuleTableSizeAbove
+		}
+	}
+	if len(matching) == 0 {
+		return nil, nil
+	}
+
+	current, err := as.localRepo.GetSummaryTables(ctx, summaryId)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []domain.SummaryTable
+	if needsPrevious {
+		if previous, err = as.previousTables(ctx, summaryId, source); err != nil {
+			return nil, err
+		}
+	}
+
+	var fired []domain.Alert
+	now := time.Now()
+	for _, rule := range matching {
+		for _, a := range EvaluateAlertRule(rule, current, previous) {
+			a.Id = uuid.New().String()
+			a.SummaryId = summaryId
+			a.Source = source
+			a.FiredAt = now
+			if err = as.store.AddAlert(ctx, &a); err != nil {
+				return fired, err
+			}
+			as.route(rule, a)
+			fired = append(fired, a)
+		}
+	}
+	return fired, nil
+}
+
+// previousTables returns the tables of the summary synced for source right before summaryId, nil on the first sync
+func (as *AlertService) previousTables(ctx context.Context, summaryId, source string) ([]domain.SummaryTable, error) {
+	summaries, err := as.localRepo.GetSourceSummaries(ctx, source, 0, 2)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range summaries {
+		if s.ID != summaryId {
+			return as.localRepo.GetSummaryTables(ctx, s.ID)
+		}
+	}
+	return nil, nil
+}
+
+func (as *AlertService) route(rule domain.AlertRule, a domain.Alert) {
+	for _, r := range rule.Routes {
+		switch r {
+		case domain.AlertRouteLog:
+			logger2.Log.Warn("alert fired", zap.String("rule", a.RuleName), zap.String("source", a.Source),
+				zap.String("schema", a.Schema), zap.String("table", a.Table), zap.String("message", a.Message))
+		case domain.AlertRouteWebhook:
+			alertCopy := a
+			for _, sink := range as.sinks {
+				sink.Publish(domain.Event{
+					Id:         uuid.New().String(),
+					Type:       domain.EventAlertFired,
+					SummaryId:  a.SummaryId,
+					Source:     a.Source,
+					StartedAt:  a.FiredAt,
+					FinishedAt: a.FiredAt,
+					Alert:      &alertCopy,
+				})
+			}
+		}
+	}
+}
+
+// EvaluateAlertRule returns the alerts rule fires for the current tables, previous holds the tables of the
+// previous sync of the same source (nil on the first sync, growth and drop rules never fire then)
+func EvaluateAlertRule(rule domain.AlertRule, current, previous []domain.SummaryTable) []domain.Alert {
+	var alerts []domain.Alert
+	newAlert := func(schema, table string, value float64, msg string) domain.Alert {
+		return domain.Alert{
+			RuleId:    rule.Id,
+			RuleName:  rule.Name,
+			Kind:      rule.Kind,
+			Schema:    schema,
+			Table:     table,
+			Value:     value,
+			Threshold: rule.Threshold,
+			Message:   msg,
+		}
+	}
+
+	switch rule.Kind {
+	case domain.RuleTableSizeAbove:
+		for _, t := range current {
+			if ruleMatches(rule, t.Schema, t.Name) && t.SizeMB > rule.Threshold {
+				alerts = append(alerts, newAlert(t.Schema, t.Name, t.SizeMB,
+					fmt.Sprintf("table %s.%s is %.2f MB, over %.2f MB", t.Schema, t.Name, t.SizeMB, rule.Threshold)))
+			}
+		}
+
+	case domain.RuleSchemaGrowth:
+		if previous == nil {
+			return nil
+		}
+		before := schemaSizes(previous)
+		after := schemaSizes(current)
+		for _, schema := range sortedKeys(after) {
+			if !ruleMatches(rule, schema, "") || before[schema] <= 0 {
+				continue
+			}
+			growth := (after[schema] - before[schema]) / before[schema] * 100
+			if growth > rule.Threshold {
+				alerts = append(alerts, newAlert(schema, "", growth,
+					fmt.Sprintf("schema %s grew %.2f%% (%.2f MB -> %.2f MB), over %.2f%%", schema, growth, before[schema], after[schema], rule.Threshold)))
+			}
+		}
+
+	case domain.RuleRowCountDrop:
+		if previous == nil {
+			return nil
+		}
+		before := map[string]int64{}
+		for _, t := range previous {
+			before[t.Schema+"."+t.Name] = t.TotalRows
+		}
+		for _, t := range current {
+			prev, ok := before[t.Schema+"."+t.Name]
+			if !ok || !ruleMatches(rule, t.Schema, t.Name) || prev <= 0 || t.TotalRows >= prev {
+				continue
+			}
+			drop := float64(prev-t.TotalRows) / float64(prev) * 100
+			if drop > rule.Threshold {
+				alerts = append(alerts, newAlert(t.Schema, t.Name, drop,
+					fmt.Sprintf("row count of %s.%s dropped %.2f%% (%d -> %d)", t.Schema, t.Name, drop, prev, t.TotalRows)))
+			}
+		}
+	}
+	return alerts
+}
+
+func ruleMatches(rule domain.AlertRule, schema, table string) bool {
+	if rule.Schema != "" && rule.Schema != schema {
+		return false
+	}
+	return rule.Table == "" || table == "" || rule.Table == table
+}
+
+func schemaSizes(tables []domain.SummaryTable) map[string]float64 {
+	sizes := map[string]float64{}
+	for _, t := range tables {
+		sizes[t.Schema] += t.SizeMB
+	}
+	return sizes
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func validateAlertRuleRequest(req domain.AlertRuleRequest) error {
+	if req.Name == "" {
+		return domain.NewBadRequestError("name cannot be empty")
+	}
+	if !req.Kind.Valid() {
+		return domain.NewBadRequestError(fmt.Sprintf("unknown rule kind %q", req.Kind))
+	}
+	if req.Threshold < 0 {
+		return domain.NewBadRequestError("threshold cannot be negative")
+	}
+	for _, r := range req.Routes {
+		if r != domain.AlertRouteLog && r != domain.AlertRouteWebhook {
+			return domain.NewBadRequestError(fmt.Sprintf("unknown alert route %q", r))
+		}
+	}
+	return nil
+}
+
+func applyAlertRuleRequest(rule *domain.AlertRule, req domain.AlertRuleRequest) {
+	rule.Name = req.Name
+	rule.Kind = req.Kind
+	rule.Source = req.Source
+	rule.Schema = req.Schema
+	rule.Table = req.Table
+	rule.Threshold = req.Threshold
+	rule.Routes = req.Routes
+	if len(rule.Routes) == 0 {
+		rule.Routes = []domain.AlertRoute{domain.AlertRouteLog}
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+}
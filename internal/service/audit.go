@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"pg-summary-service/internal/domain"
+	logger2 "pg-summary-service/internal/logger"
+	"pg-summary-service/internal/repository/audit"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditLimit = 50
+	auditTimeout      = 5 * time.Second
+)
+
+// AuditLog records who synced or deleted what, when and how it went
+type AuditLog struct {
+	store audit.Store
+}
+
+func NewAuditLog(store audit.Store) *AuditLog {
+	return &AuditLog{store: store}
+}
+
+// auditEntry is what an audited operation knows about itself, the actor and job come from the context
+type auditEntry struct {
+	action    string
+	target    string
+	dbUser    string
+	summaryId string
+	startedAt time.Time
+	err       error
+}
+
+// Record appends the entry to the log. It runs even when the request was canceled, and a failure is only logged:
+// the audited operation already happened.
+func (al *AuditLog) Record(ctx context.Context, entry auditEntry) {
+	actor := domain.ActorFrom(ctx)
+	event := &domain.AuditEvent{
+		Id:           uuid.New().String(),
+		At:           time.Now().UTC().Truncate(time.Microsecond),
+		Actor:        actor.Name,
+		ClaimedActor: actor.Claimed,
+		RemoteAddr:   actor.RemoteAddr,
+		Action:       entry.action,
+		Target:       entry.target,
+		DBUser:       entry.dbUser,
+		SummaryId:    entry.summaryId,
+		JobId:        domain.JobIdFrom(ctx),
+		Outcome:      domain.AuditSuccess,
+		DurationMs:   time.Since(entry.startedAt).Milliseconds(),
+		ErrorClass:   domain.ErrorClass(entry.err),
+	}
+	if entry.err != nil {
+		event.Outcome = domain.AuditFailure
+	}
+
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), auditTimeout)
+	defer cancel()
+	if err := al.store.AddEvent(storeCtx, event); err != nil {
+		logger2.Log.Error("src :Record error while saving audit event", zap.Error(err),
+			zap.String("action", event.Action), zap.String("target", event.Target), zap.String("actor", event.Actor))
+	}
+}
+
+func (al *AuditLog) ListEvents(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEvent, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	events := []domain.AuditEvent{}
+	err := al.store.StreamEvents(ctx, filter, func(event domain.AuditEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// StreamEvents hands every matching event to fn, a Limit of 0 exports them all
+func (al *AuditLog) StreamEvents(ctx context.Context, filter domain.AuditFilter, fn func(domain.AuditEvent) error) error {
+	return al.store.StreamEvents(ctx, filter, fn)
+}
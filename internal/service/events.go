@@ -0,0 +1,142 @@
+package service
+
+import (
+	"pg-summary-service/internal/domain"
+	"sync"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// StreamEvent is an event numbered in the order it was published, Seq is the SSE event id
+type StreamEvent struct {
+	Seq   uint64
+	Event domain.Event
+}
+
+// EventStream is an EventSink keeping the latest events in a bounded buffer for replay and fanning every new
+// one out to its subscribers. A subscriber too slow to keep up is dropped rather than blocking the sync, it
+// reconnects from its last seen event.
+type EventStream struct {
+	mu     sync.Mutex
+	seq    uint64
+	buffer []StreamEvent // ring of the latest events, buffer[next] is the oldest once full
+	next   int
+	subs   map[*subscription]struct{}
+	jobs   map[string]*bufferedJob // the jobs with events in the buffer
+}
+
+// bufferedJob counts the buffered events of a job, evicted is closed once the last of them left the buffer
+type bufferedJob struct {
+	events  int
+	evicted chan struct{}
+}
+
+type subscription struct {
+	filter func(domain.Event) bool
+	ch     chan StreamEvent
+}
+
+func NewEventStream(bufferSize int) *EventStream {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &EventStream{
+		buffer: make([]StreamEvent, 0, bufferSize),
+		subs:   map[*subscription]struct{}{},
+		jobs:   map[string]*bufferedJob{},
+	}
+}
+
+// Publish numbers the event, buffers it and sends it to every matching subscriber without blocking
+func (es *EventStream) Publish(event domain.Event) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	es.seq++
+	item := StreamEvent{Seq: es.seq, Event: event}
+	es.trackLocked(event.JobId)
+	if len(es.buffer) < cap(es.buffer) {
+		es.buffer = append(es.buffer, item)
+	} else {
+		es.forgetLocked(es.buffer[es.next].Event.JobId)
+		es.buffer[es.next] = item
+		es.next = (es.next + 1) % len(es.buffer)
+	}
+
+	for sub := range es.subs {
+		if !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.ch <- item:
+		default:
+			delete(es.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Subscribe returns the buffered events after lastSeq that match filter, and a channel with the ones published
+// from now on. The channel is closed when the subscriber fell too far behind; cancel stops the subscription.
+// A lastSeq older than the buffer replays what is still buffered.
+func (es *EventStream) Subscribe(lastSeq uint64, filter func(domain.Event) bool) (replay []StreamEvent, events <-chan StreamEvent, cancel func()) {
+	if filter == nil {
+		filter = func(domain.Event) bool { return true }
+	}
+	sub := &subscription{filter: filter, ch: make(chan StreamEvent, subscriberBuffer)}
+
+	es.mu.Lock()
+	for i := range es.buffer {
+		item := es.buffer[(es.next+i)%len(es.buffer)]
+		if item.Seq > lastSeq && filter(item.Event) {
+			replay = append(replay, item)
+		}
+	}
+	es.subs[sub] = struct{}{}
+	es.mu.Unlock()
+
+	cancel = func() {
+		es.mu.Lock()
+		defer es.mu.Unlock()
+		if _, ok := es.subs[sub]; ok {
+			delete(es.subs, sub)
+			close(sub.ch)
+		}
+	}
+	return replay, sub.ch, cancel
+}
+
+// Job reports whether the job has events in the buffer, evicted is closed once the last of them left it
+func (es *EventStream) Job(id string) (evicted <-chan struct{}, ok bool) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	job, ok := es.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return job.evicted, true
+}
+
+func (es *EventStream) trackLocked(jobId string) {
+	if jobId == "" {
+		return
+	}
+	job, ok := es.jobs[jobId]
+	if !ok {
+		job = &bufferedJob{evicted: make(chan struct{})}
+		es.jobs[jobId] = job
+	}
+	job.events++
+}
+
+func (es *EventStream) forgetLocked(jobId string) {
+	job, ok := es.jobs[jobId]
+	if !ok {
+		return
+	}
+	if job.events--; job.events == 0 {
+		delete(es.jobs, jobId)
+		close(job.evicted)
+	}
+}
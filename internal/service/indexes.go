@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"pg-summary-service/internal/domain"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// indexName matches the name in an index definition, e.g. the users_email_idx of
+// CREATE UNIQUE INDEX users_email_idx ON public.users USING btree (email)
+var indexName = regexp.MustCompile(`(?i)^(CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?)\S+\s+(ON\s)`)
+
+func (s *SummaryService) GetSummaryIndexes(ctx context.Context, id string) ([]domain.TableIndex, error) {
+	return s.localRepo.GetSummaryIndexes(ctx, id)
+}
+
+// IndexReport lists the unused and duplicate indexes of a summary and the index to heap ratio of every schema.
+// A schema only gets a heap size and a ratio when every table of it has a heap_size_mb, size_mb counts the
+// TOAST and index relations too and would skew the ratio.
+func (s *SummaryService) IndexReport(ctx context.Context, id string) (*domain.IndexReport, error) {
+	indexes, err := s.localRepo.GetSummaryIndexes(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := s.localRepo.GetSummaryTables(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.IndexReport{SummaryId: id, Unused: []domain.TableIndex{}, Duplicates: []domain.DuplicateIndexes{}}
+	usage := map[string]*domain.SchemaIndexUsage{}
+	schema := func(name string) *domain.SchemaIndexUsage {
+		if usage[name] == nil {
+			usage[name] = &domain.SchemaIndexUsage{Schema: name}
+		}
+		return usage[name]
+	}
+	heapUnknown := map[string]bool{}
+	for _, t := range tables {
+		u := schema(t.Schema)
+		if t.HeapSizeMB == nil {
+			heapUnknown[t.Schema] = true
+			continue
+		}
+		if u.HeapSizeMB == nil {
+			u.HeapSizeMB = new(float64)
+		}
+		*u.HeapSizeMB += *t.HeapSizeMB
+	}
+
+	type groupKey struct{ tableId, definition string }
+	groups := map[groupKey][]domain.TableIndex{}
+	for _, index := range indexes {
+		u := schema(index.Schema)
+		u.IndexCount++
+		u.IndexSizeMB += index.SizeMB
+		if index.Scans != nil && *index.Scans == 0 {
+			report.Unused = append(report.Unused, index)
+			u.UnusedCount++
+			u.UnusedSizeMB += index.SizeMB
+		}
+		key := groupKey{index.TableId, normalizeIndexDefinition(index.Definition)}
+		groups[key] = append(groups[key], index)
+	}
+
+	sort.SliceStable(report.Unused, func(a, b int) bool {
+		return report.Unused[a].SizeMB > report.Unused[b].SizeMB
+	})
+
+	for key, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(a, b int) bool { return keepBefore(group[a], group[b]) })
+		dup := domain.DuplicateIndexes{Schema: group[0].Schema, Table: group[0].Table, Definition: key.definition, Indexes: group}
+		for _, index := range group[1:] {
+			dup.WastedMB += index.SizeMB
+		}
+		report.Duplicates = append(report.Duplicates, dup)
+	}
+	sort.Slice(report.Duplicates, func(a, b int) bool {
+		x, y := report.Duplicates[a], report.Duplicates[b]
+		if x.Schema != y.Schema {
+			return x.Schema < y.Schema
+		}
+		if x.Table != y.Table {
+			return x.Table < y.Table
+		}
+		return x.Definition < y.Definition
+	})
+
+	report.Schemas = make([]domain.SchemaIndexUsage, 0, len(usage))
+	for name, u := range usage {
+		if heapUnknown[name] {
+			u.HeapSizeMB = nil
+		}
+		if u.HeapSizeMB != nil && *u.HeapSizeMB > 0 {
+			ratio := u.IndexSizeMB / *u.HeapSizeMB
+			u.IndexToHeap = &ratio
+		}
+		report.Schemas = append(report.Schemas, *u)
+	}
+	sort.Slice(report.Schemas, func(a, b int) bool {
+		return report.Schemas[a].Schema < report.Schemas[b].Schema
+	})
+	return report, nil
+}
+
+// keepBefore orders a duplicate group by the index to keep: the primary key, then a unique index, then the
+// largest. Dropping a primary or unique index drops its constraint, so it is never the one to go.
+func keepBefore(a, b domain.TableIndex) bool {
+	if a.Primary != b.Primary {
+		return a.Primary
+	}
+	if a.Unique != b.Unique {
+		return a.Unique
+	}
+	return a.SizeMB > b.SizeMB
+}
+
+// normalizeIndexDefinition leaves the index name out of a definition and collapses its whitespace, so two
+// indexes that only differ by name compare equal
+func normalizeIndexDefinition(definition string) string {
+	definition = strings.Join(strings.Fields(definition), " ")
+	return indexName.ReplaceAllString(definition, "${1}${2}")
+}
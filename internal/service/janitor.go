@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"pg-summary-service/internal/domain"
+	logger2 "pg-summary-service/internal/logger"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	PruneReasonKeepLast = "keep_last"
+	PruneReasonMaxAge   = "max_age"
+	PruneReasonDaily    = "daily"
+)
+
+type PrunedSummary struct {
+	Id       string    `json:"id"`
+	Source   string    `json:"source"`
+	SyncedAt time.Time `json:"synced_at"`
+	Reason   string    `json:"reason"`
+}
+
+// PruneReport is what a janitor run did
+type PruneReport struct {
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Scanned    int             `json:"scanned"`
+	Deleted    []PrunedSummary `json:"deleted"`
+	Errors     []string        `json:"errors,omitempty"`
+}
+
+// Janitor prunes summaries according to a domain.RetentionPolicy
+type Janitor struct {
+	svc    *SummaryService
+	policy domain.RetentionPolicy
+
+	mu   sync.Mutex // one run at a time, guards last
+	last *PruneReport
+}
+
+func NewJanitor(svc *SummaryService, policy domain.RetentionPolicy) *Janitor {
+	if policy.BatchSize < 1 {
+		policy.BatchSize = 100
+	}
+	return &Janitor{svc: svc, policy: policy}
+}
+
+func (j *Janitor) Policy() domain.RetentionPolicy {
+	return j.policy
+}
+
+// LastReport returns the report of the latest run, nil before the first one
+func (j *Janitor) LastReport() *PruneReport {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.last
+}
+
+// Start runs the janitor every policy.Interval until ctx is done
+func (j *Janitor) Start(ctx context.Context) {
+	if !j.policy.Enabled() || j.policy.Interval <= 0 {
+		logger2.Log.Info("retention janitor disabled")
+		return
+	}
+
+	ticker := time.NewTicker(j.policy.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := j.Run(ctx); err != nil {
+				logger2.Log.Error("src :Janitor run failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Run prunes source by source. The summaries of a source are read newest first, BatchSize at a time, and the
+// ones the policy prunes are deleted before the next page is read, so a run only holds one page in memory.
+func (j *Janitor) Run(ctx context.Context) (*PruneReport, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	report := &PruneReport{StartedAt: time.Now(), Deleted: []PrunedSummary{}}
+
+	latest, err := j.svc.localRepo.GetLatestSummaries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]string, 0, len(latest))
+	for _, item := range latest {
+		sources = append(sources, item.DBName)
+	}
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		if err = j.pruneSource(ctx, source, report); err != nil {
+			return nil, err
+		}
+		if err = ctx.Err(); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			break
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	j.last = report
+	logger2.Log.Info("retention janitor run finished",
+		zap.Int("scanned", report.Scanned), zap.Int("deleted", len(report.Deleted)), zap.Int("errors", len(report.Errors)))
+	return report, nil
+}
+
+// pruneSource pages through the summaries of source and deletes what the policy prunes, page by page.
+// A deleted summary no longer takes a place in the listing, so the next page starts after the kept ones.
+func (j *Janitor) pruneSource(ctx context.Context, source string, report *PruneReport) error {
+	pruner := newSourcePruner(j.policy, report.StartedAt)
+	previous := map[string]bool{}
+	for offset := 0; ctx.Err() == nil; {
+		page, err := j.svc.localRepo.GetSourceSummaries(ctx, source, offset, j.policy.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		kept := 0
+		current := make(map[string]bool, len(page))
+		for _, item := range page {
+			current[item.ID] = true
+			// a sync during the run shifts the listing, the page can start with the end of the previous one
+			if previous[item.ID] {
+				kept++
+				continue
+			}
+			report.Scanned++
+
+			reason := pruner.reason(item)
+			if reason == "" {
+				kept++
+				continue
+			}
+			p := PrunedSummary{Id: item.ID, Source: source, SyncedAt: item.SyncedAt, Reason: reason}
+			if _, err = j.svc.DeleteSummary(ctx, p.Id); err != nil {
+				logger2.Log.Error("src :Janitor error while deleting summary", zap.Error(err), zap.String("summary id", p.Id))
+				report.Errors = append(report.Errors, p.Id+": "+err.Error())
+				kept++
+				continue
+			}
+			report.Deleted = append(report.Deleted, p)
+		}
+
+		if len(page) < j.policy.BatchSize {
+			return nil
+		}
+		offset += kept
+		previous = current
+	}
+	return nil
+}
+
+// sourcePruner applies a policy to the summaries of one source, fed to it newest first. A summary is pruned
+// when it is beyond KeepLast, older than MaxAge, or older than DailyAfter and not the newest of its day. The
+// newest summary of a source is always kept, even past MaxAge, so a source never loses its latest state.
+type sourcePruner struct {
+	policy   domain.RetentionPolicy
+	now      time.Time
+	seen     int
+	keptDays map[string]bool
+}
+
+func newSourcePruner(policy domain.RetentionPolicy, now time.Time) *sourcePruner {
+	return &sourcePruner{policy: policy, now: now, keptDays: map[string]bool{}}
+}
+
+// reason returns why item is pruned, empty when it is kept
+func (p *sourcePruner) reason(item domain.LocalSummaryListItem) string {
+	i := p.seen
+	p.seen++
+	age := p.now.Sub(item.SyncedAt)
+	day := item.SyncedAt.UTC().Format(time.DateOnly)
+
+	reason := ""
+	switch {
+	case i == 0: // the newest summary of the source
+	case p.policy.KeepLast > 0 && i >= p.policy.KeepLast:
+		reason = PruneReasonKeepLast
+	case p.policy.MaxAge > 0 && age > p.policy.MaxAge:
+		reason = PruneReasonMaxAge
+	case p.policy.DailyAfter > 0 && age > p.policy.DailyAfter && p.keptDays[day]:
+		reason = PruneReasonDaily
+	}
+	if reason == "" {
+		p.keptDays[day] = true
+	}
+	return reason
+}
@@ -8,30 +8,130 @@ import (
 	logger2 "pg-summary-service/internal/logger"
 	"pg-summary-service/internal/repository/external"
 	"pg-summary-service/internal/repository/local"
+	"time"
+
+	"github.com/google/uuid"
 )
 
+// EventSink receives sync lifecycle events, Publish must not block the caller
+type EventSink interface {
+	Publish(event domain.Event)
+}
+
 type SummaryService struct {
 	externalRepo external.External
 	localRepo    local.Local
+	sinks        []EventSink
+	alerts       AlertEvaluator
+	audit        *AuditLog
+}
+
+type Option func(*SummaryService)
+
+// WithEventSinks registers sinks that get every event emitted by the service
+func WithEventSinks(sinks ...EventSink) Option {
+	return func(s *SummaryService) {
+		s.sinks = append(s.sinks, sinks...)
+	}
 }
 
-func NewSummaryService(extRepo external.External, localRepo local.Local) *SummaryService {
-	return &SummaryService{externalRepo: extRepo, localRepo: localRepo}
+// WithAlertEvaluator evaluates the alert rules right after a summary is stored
+func WithAlertEvaluator(evaluator AlertEvaluator) Option {
+	return func(s *SummaryService) {
+		s.alerts = evaluator
+	}
+}
+
+// WithAuditLog records every sync and delete with its actor and outcome
+func WithAuditLog(log *AuditLog) Option {
+	return func(s *SummaryService) {
+		s.audit = log
+	}
+}
+
+func NewSummaryService(extRepo external.External, localRepo local.Local, opts ...Option) *SummaryService {
+	s := &SummaryService{externalRepo: extRepo, localRepo: localRepo}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s *SummaryService) SyncSummary(ctx context.Context, details domain.RemoteDBDetails) (any, error) {
+func (s *SummaryService) SyncSummary(ctx context.Context, details domain.RemoteDBDetails) (resp any, err error) {
+	sourceInfo := fmt.Sprintf("%s:%s", details.Host, details.DBName) // Don't store pass
+	startedAt := time.Now()
+	if domain.JobIdFrom(ctx) == "" {
+		ctx = domain.WithJobId(ctx, uuid.New().String())
+	}
+	// every outcome is audited, a rejected request included
+	var summaryId string
+	if s.audit != nil {
+		defer func() {
+			s.audit.Record(ctx, auditEntry{action: domain.AuditActionSync, target: sourceInfo, dbUser: details.User,
+				summaryId: summaryId, startedAt: startedAt, err: err})
+		}()
+	}
+
 	if details.Host == "" || details.DBName == "" || details.User == "" || details.Password == "" || details.Port == 0 {
 		return nil, domain.NewBadRequestError("invalid input")
 	}
+	s.publish(ctx, domain.Event{Type: domain.EventSyncStarted, Source: sourceInfo, StartedAt: startedAt}, nil)
 
-	externalResp, err := s.externalRepo.FetchSummaries(details)
+	externalResp, err := s.externalRepo.FetchSummaries(ctx, details)
 	if err != nil {
 		logger2.Log.Error("src :SyncSummary error while fetching from external repo: ", zap.Error(err))
+		s.publish(ctx, domain.Event{Type: domain.EventSyncFailed, Source: sourceInfo, StartedAt: startedAt}, err)
 		return nil, err
 	}
+	summaryId = externalResp.Id
+	totals := externalResp.Totals()
+	s.publish(ctx, domain.Event{Type: domain.EventSyncFetched, SummaryId: externalResp.Id, Source: sourceInfo, StartedAt: startedAt, Progress: &totals}, nil)
 
-	sourceInfo := fmt.Sprintf("%s:%s", details.Host, details.DBName) // Don't store pass
-	return s.localRepo.AddSummary(ctx, sourceInfo, externalResp)
+	// the repository reports what it has written while it stores the summary
+	storeCtx := domain.WithProgress(ctx, func(progress domain.SyncProgress) {
+		s.publish(ctx, domain.Event{Type: domain.EventSyncStoring, SummaryId: externalResp.Id, Source: sourceInfo, StartedAt: startedAt, Progress: &progress}, nil)
+	})
+	resp, err = s.localRepo.AddSummary(storeCtx, sourceInfo, externalResp)
+	if err != nil {
+		s.publish(ctx, domain.Event{Type: domain.EventSyncFailed, SummaryId: externalResp.Id, Source: sourceInfo, StartedAt: startedAt}, err)
+		return nil, err
+	}
+	s.publish(ctx, domain.Event{Type: domain.EventSyncSucceeded, SummaryId: externalResp.Id, Source: sourceInfo, StartedAt: startedAt}, nil)
+
+	// a failing rule evaluation must not fail the sync, the summary is already stored
+	if s.alerts != nil {
+		if _, err = s.alerts.Evaluate(ctx, externalResp.Id, sourceInfo); err != nil {
+			logger2.Log.Error("src :SyncSummary error while evaluating alert rules", zap.Error(err), zap.String("summary id", externalResp.Id))
+		}
+	}
+	return resp, nil
+}
+
+// RejectSync audits a sync request turned down before it reached SyncSummary, e.g. one with a malformed body
+func (s *SummaryService) RejectSync(ctx context.Context, details domain.RemoteDBDetails, err error) {
+	if s.audit == nil {
+		return
+	}
+	s.audit.Record(ctx, auditEntry{action: domain.AuditActionSync, target: fmt.Sprintf("%s:%s", details.Host, details.DBName),
+		dbUser: details.User, startedAt: time.Now(), err: err})
+}
+
+// publish stamps event with an id, the job id of ctx and its duration, and hands it to every sink
+func (s *SummaryService) publish(ctx context.Context, event domain.Event, err error) {
+	if len(s.sinks) == 0 {
+		return
+	}
+
+	event.Id = uuid.New().String()
+	event.JobId = domain.JobIdFrom(ctx)
+	event.FinishedAt = time.Now()
+	event.DurationMs = event.FinishedAt.Sub(event.StartedAt).Milliseconds()
+	if err != nil {
+		event.Error = err.Error()
+	}
+	for _, sink := range s.sinks {
+		sink.Publish(event)
+	}
 }
 
 func (s *SummaryService) GetSummaries(ctx context.Context, offset, limit int) ([]domain.LocalSummaryListItem, error) {
@@ -39,6 +139,55 @@ func (s *SummaryService) GetSummaries(ctx context.Context, offset, limit int) ([
 	return s.localRepo.GetSummary(ctx, offset, limit)
 }
 
+// StreamSummaries hands every summary of the page to fn as it is read from the repository
+func (s *SummaryService) StreamSummaries(ctx context.Context, offset, limit int, fn func(domain.LocalSummaryListItem) error) error {
+	return s.localRepo.StreamSummaries(ctx, offset, limit, fn)
+}
+
 func (s *SummaryService) GetSummaryByID(ctx context.Context, id string) (*domain.LocalSummaryByIdResp, error) {
 	return s.localRepo.GetSummaryById(ctx, id)
 }
+
+// DeleteSummary removes a summary with its schemas and tables and emits summary.deleted
+func (s *SummaryService) DeleteSummary(ctx context.Context, id string) (*domain.LocalSummaryListItem, error) {
+	startedAt := time.Now()
+	deleted, err := s.localRepo.DeleteSummary(ctx, id)
+	if s.audit != nil {
+		entry := auditEntry{action: domain.AuditActionDelete, summaryId: id, startedAt: startedAt, err: err}
+		if deleted != nil {
+			entry.target = deleted.DBName
+		}
+		s.audit.Record(ctx, entry)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, domain.Event{Type: domain.EventSummaryDeleted, SummaryId: deleted.ID, Source: deleted.DBName, StartedAt: time.Now()}, nil)
+	return deleted, nil
+}
+
+func (s *SummaryService) GetSummaryTables(ctx context.Context, id string) ([]domain.SummaryTable, error) {
+	return s.localRepo.GetSummaryTables(ctx, id)
+}
+
+func (s *SummaryService) StreamSummaryTables(ctx context.Context, id string, fn func(domain.SummaryTable) error) error {
+	return s.localRepo.StreamSummaryTables(ctx, id, fn)
+}
+
+func (s *SummaryService) GetTableColumns(ctx context.Context, summaryId string, tableId string) ([]domain.TableColumn, error) {
+	return s.localRepo.GetTableColumns(ctx, summaryId, tableId)
+}
+
+func (s *SummaryService) ExportSourceTables(ctx context.Context, src string, fn func(domain.SummaryTable) error) error {
+	return s.localRepo.StreamSourceTables(ctx, src, fn)
+}
+
+// LatestSnapshots returns the latest summary of every source together with its tables
+func (s *SummaryService) LatestSnapshots(ctx context.Context) ([]domain.SourceSnapshot, error) {
+	snapshots, err := s.localRepo.GetLatestSnapshots(ctx)
+	if err != nil {
+		logger2.Log.Error("src :LatestSnapshots error while fetching the latest snapshots", zap.Error(err))
+		return nil, err
+	}
+	return snapshots, nil
+}
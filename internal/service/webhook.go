@@ -0,0 +1,307 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"pg-summary-service/internal/domain"
+	logger2 "pg-summary-service/internal/logger"
+	"pg-summary-service/internal/repository/webhook"
+	"pg-summary-service/internal/utils"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	webhookMaxBackoff = time.Minute
+	webhookTimeout    = 10 * time.Second
+)
+
+// WebhookService manages webhook subscriptions and delivers events to them.
+// note: deliveries run in the background, Close waits for the in-flight ones and drops later events
+type WebhookService struct {
+	store       webhook.Store
+	client      *http.Client
+	maxAttempts int
+	baseBackoff time.Duration
+
+	mu     sync.Mutex // guards closed, so no wg.Add races Close's wg.Wait
+	closed bool
+	wg     sync.WaitGroup
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func NewWebhookService(store webhook.Store, maxAttempts int, baseBackoff time.Duration) *WebhookService {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &WebhookService{
+		store:       store,
+		client:      &http.Client{Timeout: webhookTimeout},
+		maxAttempts: maxAttempts,
+		baseBackoff: baseBackoff,
+		stop:        make(chan struct{}),
+	}
+}
+
+func (ws *WebhookService) CreateWebhook(ctx context.Context, req domain.WebhookRequest) (*domain.Webhook, error) {
+	if err := validateWebhookRequest(req); err != nil {
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = newWebhookSecret(); err != nil {
+			return nil, domain.NewInternalError("failed to generate webhook secret")
+		}
+	}
+
+	hook := &domain.Webhook{
+		Id:        uuid.New().String(),
+		URL:       req.URL,
+		Secret:    secret,
+		Events:    req.Events,
+		Active:    req.Active == nil || *req.Active,
+		CreatedAt: time.Now(),
+	}
+	if err := ws.store.AddWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+	// the secret is only handed out once, on creation
+	return hook, nil
+}
+
+func (ws *WebhookService) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	hook, err := ws.store.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	hook.Secret = ""
+	return hook, nil
+}
+
+func (ws *WebhookService) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	hooks, err := ws.store.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return hooks, nil
+}
+
+func (ws *WebhookService) UpdateWebhook(ctx context.Context, id string, req domain.WebhookRequest) (*domain.Webhook, error) {
+	if err := validateWebhookRequest(req); err != nil {
+		return nil, err
+	}
+
+	hook, err := ws.store.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	hook.URL = req.URL
+	hook.Events = req.Events
+	if req.Secret != "" {
+		hook.Secret = req.Secret
+	}
+	if req.Active != nil {
+		hook.Active = *req.Active
+	}
+
+	if err = ws.store.UpdateWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+	hook.Secret = ""
+	return hook, nil
+}
+
+func (ws *WebhookService) DeleteWebhook(ctx context.Context, id string) error {
+	return ws.store.DeleteWebhook(ctx, id)
+}
+
+func (ws *WebhookService) ListDeliveries(ctx context.Context, id string, offset, limit int) ([]domain.WebhookDelivery, error) {
+	if _, err := ws.store.GetWebhook(ctx, id); err != nil {
+		return nil, err
+	}
+	return ws.store.ListDeliveries(ctx, id, offset, limit)
+}
+
+// Publish fans the event out to every active webhook subscribed to its type, in the background
+func (ws *WebhookService) Publish(event domain.Event) {
+	// progress events are for the event stream only, no webhook can subscribe to them
+	if !event.Type.Valid() {
+		return
+	}
+	if !ws.spawn(func() { ws.dispatch(event) }) {
+		logger2.Log.Warn("webhook service closed, event dropped", zap.String("event", string(event.Type)), zap.String("event id", event.Id))
+	}
+}
+
+// spawn runs fn in the background for Close to wait on, it reports false and does nothing once closed
+func (ws *WebhookService) spawn(fn func()) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return false
+	}
+	ws.wg.Add(1)
+	go func() {
+		defer ws.wg.Done()
+		fn()
+	}()
+	return true
+}
+
+func (ws *WebhookService) dispatch(event domain.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	hooks, err := ws.store.ListWebhooks(ctx)
+	if err != nil {
+		logger2.Log.Error("src :dispatch error while listing webhooks", zap.Error(err), zap.String("event", string(event.Type)))
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger2.Log.Error("src :dispatch error while encoding event", zap.Error(err))
+		return
+	}
+
+	for _, hook := range hooks {
+		if !hook.Active || !subscribed(hook, event.Type) {
+			continue
+		}
+		if !ws.spawn(func() { ws.deliver(hook, event, body) }) {
+			return
+		}
+	}
+}
+
+// Close stops pending retries and waits for in-flight deliveries
+func (ws *WebhookService) Close() {
+	ws.once.Do(func() {
+		ws.mu.Lock()
+		ws.closed = true
+		ws.mu.Unlock()
+		close(ws.stop)
+	})
+	ws.wg.Wait()
+}
+
+// deliver posts the event until the webhook answers 2xx, backing off exponentially between attempts
+func (ws *WebhookService) deliver(hook domain.Webhook, event domain.Event, body []byte) {
+	for attempt := 1; attempt <= ws.maxAttempts; attempt++ {
+		delivery := ws.attempt(hook, event, body, attempt)
+
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		if err := ws.store.AddDelivery(ctx, &delivery); err != nil {
+			logger2.Log.Error("src :deliver error while saving webhook delivery", zap.Error(err), zap.String("webhook id", hook.Id))
+		}
+		cancel()
+
+		if delivery.Success || attempt == ws.maxAttempts {
+			return
+		}
+
+		backoff := ws.baseBackoff * time.Duration(1<<(attempt-1))
+		if backoff > webhookMaxBackoff {
+			backoff = webhookMaxBackoff
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ws.stop:
+			return
+		}
+	}
+}
+
+func (ws *WebhookService) attempt(hook domain.Webhook, event domain.Event, body []byte, attempt int) domain.WebhookDelivery {
+	start := time.Now()
+	delivery := domain.WebhookDelivery{
+		Id:          uuid.New().String(),
+		WebhookId:   hook.Id,
+		EventId:     event.Id,
+		EventType:   event.Type,
+		Attempt:     attempt,
+		DeliveredAt: start,
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", event.Id)
+	req.Header.Set("X-Webhook-Event", string(event.Type))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhookPayload(hook.Secret, timestamp, body))
+
+	resp, err := ws.client.Do(req)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		logger2.Log.Warn(fmt.Sprintf("webhook delivery failed (try %d)", attempt), zap.Error(err), zap.String("webhook id", hook.Id))
+		delivery.Error = err.Error()
+		return delivery
+	}
+	resp.Body.Close()
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !delivery.Success {
+		delivery.Error = fmt.Sprintf("webhook returned %d", resp.StatusCode)
+	}
+	return delivery
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of "<timestamp>.<body>", receivers recompute it to verify a delivery
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	return utils.SignPayload(secret, timestamp, body)
+}
+
+func subscribed(hook domain.Webhook, eventType domain.EventType) bool {
+	for _, e := range hook.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func validateWebhookRequest(req domain.WebhookRequest) error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return domain.NewBadRequestError("url must be an absolute http(s) url")
+	}
+	if len(req.Events) == 0 {
+		return domain.NewBadRequestError("events cannot be empty")
+	}
+	for _, e := range req.Events {
+		if !e.Valid() {
+			return domain.NewBadRequestError(fmt.Sprintf("unknown event type %q", e))
+		}
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
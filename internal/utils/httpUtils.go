@@ -2,10 +2,15 @@ package utils
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"go.uber.org/zap"
+	"math/rand/v2"
 	"net/http"
 	"pg-summary-service/internal/domain"
 	logger1 "pg-summary-service/internal/logger"
@@ -17,10 +22,37 @@ import (
 const (
 	MaxRetry     = 30
 	MaxSleepTime = 30 * time.Second
+	BaseSleep    = time.Second
 )
 
-func PostWithRetry(url string, noOfRetry int, payload any) (*http.Response, error) {
+// Poster posts with retries through Client, Sign (optional) adds authentication headers to every try
+type Poster struct {
+	Client *http.Client
+	Sign   func(req *http.Request, body []byte)
+}
+
+// PostWithRetry posts payload as JSON until it gets a 2xx, the other 4xx responses are not retried except 429.
+// Waits between tries use exponential backoff with full jitter, or the Retry-After of a 429/503, and end early
+// when ctx is done.
+func PostWithRetry(ctx context.Context, url string, noOfRetry int, payload any) (*http.Response, error) {
+	return Poster{}.PostWithRetry(ctx, url, noOfRetry, payload)
+}
+
+// RefuseRedirects is a CheckRedirect that hands the redirect back instead of following it. A client following
+// a 307/308 would send the signed body, with its database credentials, to wherever the Location points.
+func RefuseRedirects(req *http.Request, via []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+// PostWithRetry is the package PostWithRetry over p's client, a nil Client times out after 5s.
+// Redirects are never followed, a 3xx fails the post.
+func (p Poster) PostWithRetry(ctx context.Context, url string, noOfRetry int, payload any) (*http.Response, error) {
 	client := &http.Client{Timeout: 5 * time.Second}
+	if p.Client != nil {
+		copied := *p.Client
+		client = &copied
+	}
+	client.CheckRedirect = RefuseRedirects
 
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
@@ -33,48 +65,103 @@ func PostWithRetry(url string, noOfRetry int, payload any) (*http.Response, erro
 		noOfRetry = MaxRetry // prevent too many retries
 	}
 
+	rateLimited := false
 	for try := 0; try < noOfRetry; try++ {
-		resp, err := client.Post(url, "application/json", bytes.NewBuffer(jsonPayload))
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonPayload))
 		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if p.Sign != nil {
+			p.Sign(req, jsonPayload)
+		}
+
+		sleepTime := BackoffWithJitter(try)
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			logger1.Log.Warn(fmt.Sprintf("request failed (try %d), retrying...", try+1), zap.Error(err))
 		} else {
 			// check status code
 			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 				return resp, nil
 			}
+			resp.Body.Close()
 
-			// client error, don’t retry
-			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-				resp.Body.Close()
+			if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+				return nil, domain.NewBadGatewayError(fmt.Sprintf("external API redirected with %d, redirects are not followed", resp.StatusCode))
+			}
+			rateLimited = resp.StatusCode == http.StatusTooManyRequests
+			// client error, don’t retry, a 429 only asks us to slow down
+			if resp.StatusCode >= 400 && resp.StatusCode < 500 && !rateLimited {
 				return nil, domain.NewBadRequestError(fmt.Sprintf("external API returned %d", resp.StatusCode))
 			}
+			if wait, ok := RetryAfter(resp); ok && (rateLimited || resp.StatusCode == http.StatusServiceUnavailable) {
+				sleepTime = min(wait, MaxSleepTime)
+			}
 			// server error retry
-			resp.Body.Close()
 			logger1.Log.Warn(fmt.Sprintf("server error (status %d), retrying (try %d)...", resp.StatusCode, try+1))
 		}
 
-		// exponential backoff with max cap
-		sleepTime := time.Second * time.Duration(1<<try)
-		if sleepTime > MaxSleepTime {
-			sleepTime = MaxSleepTime
+		if try == noOfRetry-1 {
+			break
+		}
+		if err = Sleep(ctx, sleepTime); err != nil {
+			return nil, err
 		}
-		time.Sleep(sleepTime)
 	}
 
+	if rateLimited {
+		return nil, domain.NewServiceUnavailableError("external API is rate limiting, try again later")
+	}
 	return nil, domain.ErrExternalServiceUnreachable
 }
 
-func ExtractIDFromPath(r *http.Request, prefix string) (string, error) {
-	path := r.URL.Path
-	if !strings.HasPrefix(path, prefix) {
-		return "", errors.New("invalid path")
+// SignPayload returns the hex HMAC-SHA256 of "<timestamp>.<body>"
+func SignPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// BackoffWithJitter returns a random wait between 0 and the exponential backoff of try, capped at MaxSleepTime
+func BackoffWithJitter(try int) time.Duration {
+	backoff := MaxSleepTime
+	if try < 16 {
+		backoff = min(BaseSleep*time.Duration(1<<try), MaxSleepTime)
+	}
+	return time.Duration(rand.Int64N(int64(backoff) + 1))
+}
+
+// RetryAfter reads the Retry-After header, in seconds or as an http date
+func RetryAfter(resp *http.Response) (time.Duration, bool) {
+	raw := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if raw == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(raw); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
 	}
-	id := strings.TrimPrefix(path, prefix)
-	id = strings.Trim(id, "/")
-	if id == "" {
-		return "", errors.New("id not provided in path")
+	if at, err := http.ParseTime(raw); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// Sleep waits for d or until ctx is done, whichever comes first
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
-	return id, nil
 }
 
 func ParseQueryInt(r *http.Request, key string, defaultValue int) int {
@@ -97,6 +184,11 @@ func SendError(w http.ResponseWriter, err error) {
 		http.Error(w, appErr.Message, appErr.Code)
 		return
 	}
+	// the external API failed us after every retry, it is not this service's fault
+	if errors.Is(err, domain.ErrExternalServiceUnreachable) {
+		http.Error(w, domain.ErrExternalServiceUnreachable.Error(), http.StatusBadGateway)
+		return
+	}
 
 	http.Error(w, "internal server error", http.StatusInternalServerError)
 }
@@ -0,0 +1,70 @@
+package mockexternal
+
+import "time"
+
+// VersionHeader carries the contract version of a response, as the service reads it
+const VersionHeader = "X-Summary-Version"
+
+// ContractVersion is the version of the external contract the mock implements
+const ContractVersion = "1"
+
+// Request is the body the service posts, the connection details of the database to summarize
+type Request struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	DBName   string `json:"dbname"`
+}
+
+// Summary is the body of a successful response
+type Summary struct {
+	Id      string   `json:"summary_id"`
+	Schemas []Schema `json:"schemas"`
+}
+
+type Schema struct {
+	Name   string  `json:"name"`
+	Tables []Table `json:"tables"`
+}
+
+type Table struct {
+	Name      string  `json:"name"`
+	TotalRows int     `json:"row_count"`
+	Size      float64 `json:"size_mb"`
+	TableStats
+	Columns []Column `json:"columns,omitempty"`
+	Indexes []Index  `json:"indexes,omitempty"`
+}
+
+// TableStats are the optional statistics of a table, the mock always sends the sizes, tuples and scans
+type TableStats struct {
+	HeapSizeMB     *float64   `json:"heap_size_mb,omitempty"`
+	IndexSizeMB    *float64   `json:"index_size_mb,omitempty"`
+	ToastSizeMB    *float64   `json:"toast_size_mb,omitempty"`
+	DeadTuples     *int64     `json:"dead_tuples,omitempty"`
+	LastVacuum     *time.Time `json:"last_vacuum,omitempty"`
+	LastAutovacuum *time.Time `json:"last_autovacuum,omitempty"`
+	LastAnalyze    *time.Time `json:"last_analyze,omitempty"`
+	SeqScans       *int64     `json:"seq_scans,omitempty"`
+	IdxScans       *int64     `json:"idx_scans,omitempty"`
+}
+
+type Column struct {
+	Name      string   `json:"name"`
+	DataType  string   `json:"data_type"`
+	Nullable  bool     `json:"nullable"`
+	Default   *string  `json:"default,omitempty"`
+	NullFrac  *float64 `json:"null_frac,omitempty"`
+	NDistinct *float64 `json:"n_distinct,omitempty"`
+	AvgWidth  *int64   `json:"avg_width,omitempty"`
+}
+
+type Index struct {
+	Name       string  `json:"name"`
+	Definition string  `json:"definition"`
+	SizeMB     float64 `json:"size_mb"`
+	Scans      *int64  `json:"scans,omitempty"`
+	Unique     bool    `json:"unique"`
+	Primary    bool    `json:"primary"`
+}
@@ -0,0 +1,128 @@
+package mockexternal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+
+	"github.com/google/uuid"
+)
+
+var (
+	schemaNames = []string{"public", "sales", "inventory", "billing", "analytics", "audit", "auth", "reporting"}
+	tableNames  = []string{"users", "orders", "order_items", "products", "customers", "payments", "invoices",
+		"sessions", "events", "addresses", "shipments", "categories", "reviews", "logs", "settings", "accounts"}
+)
+
+// Shape bounds the size of a generated summary
+type Shape struct {
+	MaxSchemas int // schemas per target, at least 1
+	MaxTables  int // tables per schema, 0 allows empty schemas
+}
+
+// Target identifies a database the way the generator seeds from it, the password plays no part
+func Target(details Request) string {
+	return fmt.Sprintf("%s@%s:%d/%s", details.User, details.Host, details.Port, details.DBName)
+}
+
+// Generate returns the summary of the seq-th sync of a target. The schemas and tables of a target never change,
+// its row counts grow by 1% per sync and every sync gets its own id, so the same inputs always give the same payload.
+func Generate(details Request, seq int, shape Shape) *Summary {
+	target := Target(details)
+	h := fnv.New64a()
+	h.Write([]byte(target))
+	rng := rand.New(rand.NewPCG(h.Sum64(), 0))
+
+	summary := &Summary{
+		Id: uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("%s#%d", target, seq))).String(),
+	}
+
+	schemaCount := 1 + rng.IntN(max(shape.MaxSchemas, 1))
+	for i := 0; i < schemaCount; i++ {
+		schema := Schema{Name: indexedName(schemaNames, i), Tables: []Table{}}
+
+		tableCount := 0
+		if shape.MaxTables > 0 {
+			tableCount = rng.IntN(shape.MaxTables + 1)
+		}
+		// names are drawn without repeats, so a schema never lists a table twice
+		for _, pick := range rng.Perm(max(tableCount, len(tableNames)))[:tableCount] {
+			rows := rng.IntN(1_000_000)
+			rows += rows * seq / 100
+			rowBytes := 64 + rng.IntN(960)
+			table := Table{
+				Name:      indexedName(tableNames, pick),
+				TotalRows: rows,
+				Size:      math.Round(float64(rows*rowBytes)/(1<<20)*100) / 100,
+			}
+			table.TableStats = stats(table)
+			table.Columns = columns(table)
+			table.Indexes = indexes(schema.Name, table)
+			schema.Tables = append(schema.Tables, table)
+		}
+		summary.Schemas = append(summary.Schemas, schema)
+	}
+	return summary
+}
+
+// stats splits the size of a table into heap, index and TOAST and derives its tuple and scan counts from the rows,
+// so they take no draws from the generator and the tables stay those of earlier releases
+func stats(table Table) TableStats {
+	round := func(mb float64) *float64 {
+		mb = math.Round(mb*100) / 100
+		return &mb
+	}
+	count := func(n int) *int64 {
+		c := int64(n)
+		return &c
+	}
+	heap, index := round(table.Size*0.7), round(table.Size*0.25)
+	return TableStats{
+		HeapSizeMB:  heap,
+		IndexSizeMB: index,
+		ToastSizeMB: round(max(table.Size-*heap-*index, 0)),
+		DeadTuples:  count(table.TotalRows / 50),
+		SeqScans:    count(table.TotalRows%97 + 1),
+		IdxScans:    count(table.TotalRows / 10),
+	}
+}
+
+// columns gives every table an id, a timestamp and a free text column, with pg_stats figures derived from the rows
+func columns(table Table) []Column {
+	now := "now()"
+	frac := func(f float64) *float64 { return &f }
+	width := func(w int64) *int64 { return &w }
+	return []Column{
+		{Name: "id", DataType: "bigint", NDistinct: frac(-1), NullFrac: frac(0), AvgWidth: width(8)},
+		{Name: "created_at", DataType: "timestamp with time zone", Default: &now, NDistinct: frac(-0.9), NullFrac: frac(0), AvgWidth: width(8)},
+		{Name: "note", DataType: "text", Nullable: true, NDistinct: frac(float64(table.TotalRows%50 + 1)),
+			NullFrac: frac(0.25), AvgWidth: width(int64(16 + table.TotalRows%48))},
+	}
+}
+
+// indexes gives every table its primary key and an index on created_at, which a third of the tables never scan
+func indexes(schema string, table Table) []Index {
+	size := func(share float64) float64 {
+		return math.Round(*table.IndexSizeMB*share*100) / 100
+	}
+	scans := func(n int64) *int64 { return &n }
+	createdScans := scans(*table.IdxScans / 4)
+	if table.TotalRows%3 == 0 {
+		createdScans = scans(0)
+	}
+	return []Index{
+		{Name: table.Name + "_pkey", Definition: fmt.Sprintf("CREATE UNIQUE INDEX %s_pkey ON %s.%s USING btree (id)", table.Name, schema, table.Name),
+			SizeMB: size(0.6), Scans: scans(*table.IdxScans - *createdScans), Unique: true, Primary: true},
+		{Name: table.Name + "_created_at_idx", Definition: fmt.Sprintf("CREATE INDEX %s_created_at_idx ON %s.%s USING btree (created_at)", table.Name, schema, table.Name),
+			SizeMB: size(0.4), Scans: createdScans},
+	}
+}
+
+// indexedName returns names[i], past the end of the list the names repeat with a suffix, e.g. users_2
+func indexedName(names []string, i int) string {
+	if i < len(names) {
+		return names[i]
+	}
+	return fmt.Sprintf("%s_%d", names[i%len(names)], i/len(names)+1)
+}
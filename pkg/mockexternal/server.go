@@ -0,0 +1,159 @@
+// Package mockexternal is a stand-in for the external summary API, for local development and tests.
+// It answers POSTs of a Request with a generated Summary of contract version 1, and imports nothing of the service.
+package mockexternal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Config tunes the mock, the zero value answers every request at once with up to 3 schemas of 8 tables
+type Config struct {
+	Shape
+	Latency       time.Duration // added to every response
+	Jitter        time.Duration // random extra latency, up to this much
+	ErrorRate     float64       // share of requests, 0 to 1, answered with one of ErrorStatuses
+	ErrorStatuses []int         // defaults to 500, 502 and 503
+	RetryAfter    time.Duration // sent with 429 and 503 responses, 0 sends none
+	Seed          uint64        // seeds the error draws and the jitter
+}
+
+// Stats counts what the mock answered
+type Stats struct {
+	Requests  int `json:"requests"`
+	Summaries int `json:"summaries"`
+	Failures  int `json:"failures"`
+}
+
+// Server is an http.Handler implementing the external contract.
+// A request can force its response with the query parameters status (e.g. ?status=503) and latency (e.g. ?latency=2s).
+type Server struct {
+	conf Config
+
+	mu    sync.Mutex
+	rng   *rand.Rand
+	syncs map[string]int // syncs per target, seeds the next summary
+	stats Stats
+}
+
+func New(conf Config) *Server {
+	if conf.MaxSchemas == 0 && conf.MaxTables == 0 {
+		conf.Shape = Shape{MaxSchemas: 3, MaxTables: 8}
+	}
+	if len(conf.ErrorStatuses) == 0 {
+		conf.ErrorStatuses = []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}
+	}
+	return &Server{
+		conf:  conf,
+		rng:   rand.New(rand.NewPCG(conf.Seed, conf.Seed)),
+		syncs: map[string]int{},
+	}
+}
+
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var details Request
+	if err := json.NewDecoder(r.Body).Decode(&details); err != nil {
+		s.fail()
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if details.Host == "" || details.DBName == "" {
+		s.fail()
+		http.Error(w, "host and dbname are required", http.StatusBadRequest)
+		return
+	}
+
+	latency, status, err := s.plan(r)
+	if err != nil {
+		s.fail()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-r.Context().Done():
+		return
+	case <-timer.C:
+	}
+
+	if status != http.StatusOK {
+		s.fail()
+		if s.conf.RetryAfter > 0 && (status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(s.conf.RetryAfter.Seconds())))
+		}
+		http.Error(w, fmt.Sprintf("mock external API failure %d", status), status)
+		return
+	}
+
+	s.mu.Lock()
+	target := Target(details)
+	seq := s.syncs[target]
+	s.syncs[target]++
+	s.stats.Requests++
+	s.stats.Summaries++
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(VersionHeader, ContractVersion)
+	_ = json.NewEncoder(w).Encode(struct {
+		Version string `json:"version"`
+		*Summary
+	}{ContractVersion, Generate(details, seq, s.conf.Shape)})
+}
+
+// plan draws the latency and status of a request, the query parameters win over the config
+func (s *Server) plan(r *http.Request) (time.Duration, int, error) {
+	s.mu.Lock()
+	latency := s.conf.Latency
+	if s.conf.Jitter > 0 {
+		latency += time.Duration(s.rng.Int64N(int64(s.conf.Jitter)))
+	}
+	status := http.StatusOK
+	if s.conf.ErrorRate > 0 && s.rng.Float64() < s.conf.ErrorRate {
+		status = s.conf.ErrorStatuses[s.rng.IntN(len(s.conf.ErrorStatuses))]
+	}
+	s.mu.Unlock()
+
+	query := r.URL.Query()
+	if raw := query.Get("latency"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid latency %q", raw)
+		}
+		latency = d
+	}
+	if raw := query.Get("status"); raw != "" {
+		code, err := strconv.Atoi(raw)
+		if err != nil || code < 200 || code > 599 {
+			return 0, 0, fmt.Errorf("invalid status %q", raw)
+		}
+		status = code
+	}
+	return latency, status, nil
+}
+
+func (s *Server) fail() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Requests++
+	s.stats.Failures++
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"context"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock Alert Evaluator
+type MockAlertEvaluator struct {
+	mock.Mock
+}
+
+func (m *MockAlertEvaluator) Evaluate(ctx context.Context, summaryId, source string) ([]domain.Alert, error) {
+	args := m.Called(ctx, summaryId, source)
+	return nil, args.Error(1)
+}
+
+// Test EvaluateAlertRule for every rule kind
+func TestEvaluateAlertRule(t *testing.T) {
+	previous := []domain.SummaryTable{
+		{Schema: "public", Name: "orders", TotalRows: 1000, SizeMB: 100},
+		{Schema: "public", Name: "users", TotalRows: 50, SizeMB: 10},
+		{Schema: "sales", Name: "leads", TotalRows: 10, SizeMB: 5},
+	}
+	current := []domain.SummaryTable{
+		{Schema: "public", Name: "orders", TotalRows: 700, SizeMB: 130},
+		{Schema: "public", Name: "users", TotalRows: 60, SizeMB: 13},
+		{Schema: "sales", Name: "leads", TotalRows: 10, SizeMB: 5},
+	}
+
+	tests := []struct {
+		name     string
+		rule     domain.AlertRule
+		previous []domain.SummaryTable
+		tables   []string
+		values   []float64
+	}{
+		{
+			name:     "table over size",
+			rule:     domain.AlertRule{Kind: domain.RuleTableSizeAbove, Threshold: 100},
+			previous: previous,
+			tables:   []string{"public.orders"},
+			values:   []float64{130},
+		},
+		{
+			name:     "schema growth over threshold",
+			rule:     domain.AlertRule{Kind: domain.RuleSchemaGrowth, Schema: "public", Threshold: 20},
+			previous: previous,
+			tables:   []string{"public."},
+			values:   []float64{30},
+		},
+		{
+			name:     "schema growth under threshold",
+			rule:     domain.AlertRule{Kind: domain.RuleSchemaGrowth, Schema: "public", Threshold: 50},
+			previous: previous,
+		},
+		{
+			name:     "row count dropped",
+			rule:     domain.AlertRule{Kind: domain.RuleRowCountDrop, Table: "orders"},
+			previous: previous,
+			tables:   []string{"public.orders"},
+			values:   []float64{30},
+		},
+		{
+			name: "first sync has nothing to compare",
+			rule: domain.AlertRule{Kind: domain.RuleRowCountDrop},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerts := service.EvaluateAlertRule(tt.rule, current, tt.previous)
+
+			var (
+				tables []string
+				values []float64
+			)
+			for _, a := range alerts {
+				tables = append(tables, a.Schema+"."+a.Table)
+				values = append(values, a.Value)
+			}
+			assert.Equal(t, tt.tables, tables)
+			assert.InDeltaSlice(t, tt.values, values, 0.001)
+		})
+	}
+}
+
+// Test SyncSummary evaluates alert rules after storing the summary
+func TestSyncSummaryEvaluatesAlerts(t *testing.T) {
+	mockExt := new(MockExtRepo)
+	mockLocal := new(MockLocalRepo)
+	evaluator := new(MockAlertEvaluator)
+	svc := service.NewSummaryService(mockExt, mockLocal, service.WithAlertEvaluator(evaluator))
+
+	details := domain.RemoteDBDetails{Host: "test", Port: 5432, User: "user", Password: "pass", DBName: "db"}
+	extResp := &domain.ExternalSummaryResp{Id: "summary1"}
+
+	mockExt.On("FetchSummaries", mock.Anything, details).Return(extResp, nil)
+	mockLocal.On("AddSummary", mock.Anything, "test:db", extResp).Return(nil, nil)
+	evaluator.On("Evaluate", mock.Anything, "summary1", "test:db").Return(nil, nil)
+
+	_, err := svc.SyncSummary(context.Background(), details)
+	assert.NoError(t, err)
+	evaluator.AssertExpectations(t)
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"context"
+	"os"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/repository/audit"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The conformance suite runs against every audit.Store implementation.
+// audit_events cannot be truncated, so every run writes under its own actor.
+
+func TestMemoryAuditConformance(t *testing.T) {
+	runAuditConformance(t, audit.NewMemoryRepository())
+}
+
+func TestAuditRepositoryConformance(t *testing.T) {
+	dbUrl := os.Getenv("TEST_LOCAL_DB_URL")
+	if dbUrl == "" {
+		t.Skip("TEST_LOCAL_DB_URL not set")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dbUrl)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	require.NoError(t, audit.CreateTables(ctx, pool))
+
+	runAuditConformance(t, audit.NewAuditRepository(pool))
+}
+
+func runAuditConformance(t *testing.T, store audit.Store) {
+	ctx := context.Background()
+	actor := "conformance-" + uuid.NewString()
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, outcome := range []domain.AuditOutcome{domain.AuditSuccess, domain.AuditFailure, domain.AuditSuccess} {
+		require.NoError(t, store.AddEvent(ctx, &domain.AuditEvent{
+			Id:      uuid.NewString(),
+			At:      base.Add(time.Duration(i) * time.Hour),
+			Actor:   actor,
+			Action:  domain.AuditActionSync,
+			Target:  "db.local:shop",
+			Outcome: outcome,
+		}))
+	}
+
+	list := func(filter domain.AuditFilter) []domain.AuditEvent {
+		t.Helper()
+		filter.Actor = actor
+		var events []domain.AuditEvent
+		require.NoError(t, store.StreamEvents(ctx, filter, func(event domain.AuditEvent) error {
+			events = append(events, event)
+			return nil
+		}))
+		return events
+	}
+
+	t.Run("newest first", func(t *testing.T) {
+		events := list(domain.AuditFilter{})
+		require.Len(t, events, 3)
+		assert.True(t, events[0].At.Equal(base.Add(2*time.Hour)))
+		assert.True(t, events[2].At.Equal(base))
+	})
+
+	t.Run("outcome, limit and offset", func(t *testing.T) {
+		assert.Len(t, list(domain.AuditFilter{Outcome: domain.AuditFailure}), 1)
+		events := list(domain.AuditFilter{Limit: 1, Offset: 1})
+		require.Len(t, events, 1)
+		assert.True(t, events[0].At.Equal(base.Add(time.Hour)))
+	})
+
+	t.Run("since and until with a non UTC offset", func(t *testing.T) {
+		// 14:00+02:00 is 12:00 UTC, 08:00-05:00 is 13:00 UTC
+		since := base.In(time.FixedZone("", 2*60*60))
+		until := base.Add(time.Hour).In(time.FixedZone("", -5*60*60))
+		events := list(domain.AuditFilter{Since: &since, Until: &until})
+		require.Len(t, events, 1)
+		assert.True(t, events[0].At.Equal(base))
+
+		later := base.Add(90 * time.Minute).In(time.FixedZone("", 9*60*60))
+		assert.Len(t, list(domain.AuditFilter{Since: &later}), 1)
+	})
+}
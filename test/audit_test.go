@@ -0,0 +1,139 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/repository/audit"
+	"pg-summary-service/internal/repository/local"
+	"pg-summary-service/internal/service"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test syncs and deletes land in the audit log with their actor and outcome, and never with the password
+func TestE2EAudit(t *testing.T) {
+	e := newE2E(t)
+	actor := map[string]string{"X-Actor": "alice"}
+
+	resp, body := e.do(http.MethodPost, "/v1/summary/sync", e2eTarget, actor)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	resp, body = e.do(http.MethodPost, "/v1/summary/sync", strings.Replace(e2eTarget, "db.local", "db.down", 1), nil)
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode, body)
+
+	list := func(query string) []domain.AuditEvent {
+		t.Helper()
+		resp, body := e.do(http.MethodGet, "/v1/audit"+query, "", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.NotContains(t, body, "secret")
+		var events []domain.AuditEvent
+		require.NoError(t, json.Unmarshal([]byte(body), &events))
+		return events
+	}
+
+	events := list("")
+	require.Len(t, events, 2)
+	failed, synced := events[0], events[1] // newest first
+	assert.Equal(t, "alice", synced.Actor)
+	assert.Equal(t, domain.AuditActionSync, synced.Action)
+	assert.Equal(t, e.source, synced.Target)
+	assert.Equal(t, "app", synced.DBUser)
+	assert.Equal(t, domain.AuditSuccess, synced.Outcome)
+	assert.NotEmpty(t, synced.SummaryId)
+	assert.NotEmpty(t, synced.JobId)
+	assert.Empty(t, synced.ErrorClass)
+
+	assert.Equal(t, "anonymous", failed.Actor)
+	assert.Equal(t, "db.down:shop", failed.Target)
+	assert.Equal(t, domain.AuditFailure, failed.Outcome)
+	assert.Equal(t, "unreachable", failed.ErrorClass)
+
+	resp, body = e.do(http.MethodDelete, "/v1/summaries/"+synced.SummaryId, "", actor)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode, body)
+
+	// X-Actor and basic auth users from anywhere else are only claims
+	for _, set := range []func(*http.Request){
+		func(r *http.Request) { r.Header.Set("X-Actor", "mallory") },
+		func(r *http.Request) { r.SetBasicAuth("mallory", "any password") },
+	} {
+		req := httptest.NewRequest(http.MethodDelete, "/v1/summaries/missing", nil)
+		req.RemoteAddr = "203.0.113.9:40000"
+		set(req)
+		rec := httptest.NewRecorder()
+		e.server.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+	claimed := list("?action=summary.delete&outcome=failure")
+	require.Len(t, claimed, 2)
+	for _, event := range claimed {
+		assert.Equal(t, "anonymous", event.Actor)
+		assert.Equal(t, "mallory", event.ClaimedActor)
+		assert.Equal(t, "203.0.113.9", event.RemoteAddr)
+	}
+	assert.Empty(t, synced.ClaimedActor)
+	assert.Empty(t, list("?actor=mallory"))
+
+	assert.Len(t, list("?actor=alice"), 2)
+	assert.Len(t, list("?outcome=failure"), 3)
+	assert.Len(t, list("?action=summary.delete&target="+e.source), 1)
+	assert.Len(t, list("?limit=1&offset=2"), 1)
+	assert.Empty(t, list("?since=2999-01-01T00:00:00Z"))
+
+	// the export has one event per line
+	resp, body = e.do(http.MethodGet, "/v1/audit?format=ndjson", "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	lines := 0
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		var event domain.AuditEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		lines++
+	}
+	assert.Equal(t, 5, lines)
+
+	// rejected requests are audited too, before they reach the external API
+	resp, body = e.do(http.MethodPost, "/v1/summary/sync", strings.Replace(e2eTarget, `"secret"`, `""`, 1), actor)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+	resp, body = e.do(http.MethodPost, "/v1/summary/sync", `{"host": `, actor)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+	rejected := list("?action=summary.sync&actor=alice&outcome=failure")
+	require.Len(t, rejected, 2)
+	assert.Equal(t, "bad_request", rejected[0].ErrorClass)
+	assert.Equal(t, e.source, rejected[1].Target)
+	assert.Equal(t, "app", rejected[1].DBUser)
+	assert.Equal(t, "bad_request", rejected[1].ErrorClass)
+
+	e.run([]e2eCase{
+		{name: "invalid outcome", method: http.MethodGet, path: "/v1/audit?outcome=ok", code: http.StatusBadRequest},
+		{name: "invalid since", method: http.MethodGet, path: "/v1/audit?since=yesterday", code: http.StatusBadRequest},
+		{name: "not acceptable", method: http.MethodGet, path: "/v1/audit", headers: map[string]string{"Accept": "text/csv"}, code: http.StatusNotAcceptable},
+	})
+}
+
+// Test a sync the service itself rejects is audited
+func TestAuditRejectedSync(t *testing.T) {
+	store := audit.NewMemoryRepository()
+	svc := service.NewSummaryService(nil, local.NewMemoryRepository(), service.WithAuditLog(service.NewAuditLog(store)))
+
+	_, err := svc.SyncSummary(context.Background(), domain.RemoteDBDetails{Host: "db.local", DBName: "shop", User: "app"})
+	assertAppError(t, err, http.StatusBadRequest)
+
+	var events []domain.AuditEvent
+	require.NoError(t, store.StreamEvents(context.Background(), domain.AuditFilter{}, func(event domain.AuditEvent) error {
+		events = append(events, event)
+		return nil
+	}))
+	require.Len(t, events, 1)
+	assert.Equal(t, domain.AuditActionSync, events[0].Action)
+	assert.Equal(t, "db.local:shop", events[0].Target)
+	assert.Equal(t, domain.AuditFailure, events[0].Outcome)
+	assert.Equal(t, "bad_request", events[0].ErrorClass)
+	assert.Equal(t, "system", events[0].Actor)
+}
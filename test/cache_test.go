@@ -0,0 +1,217 @@
+package test
+
+import (
+	"context"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/repository/local"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCachedRepositoryConformance(t *testing.T) {
+	runLocalConformance(t, func(t *testing.T) local.Local {
+		return local.NewCachedRepository(local.NewMemoryRepository(), domain.CacheConfig{Enabled: true, TTL: time.Minute, MaxEntries: 100})
+	})
+}
+
+// Test lookups are served from the cache until a write or delete invalidates them
+func TestCachedRepositoryInvalidation(t *testing.T) {
+	ctx := context.Background()
+	mockLocal := new(MockLocalRepo)
+	cached := local.NewCachedRepository(mockLocal, domain.CacheConfig{Enabled: true, MaxEntries: 10})
+
+	resp := &domain.LocalSummaryByIdResp{ID: "s1", Source: "a:db", Schemas: []domain.SchemaSummary{{Name: "public"}}}
+	mockLocal.On("GetSummaryById", mock.Anything, "s1").Return(resp, nil).Twice()
+	mockLocal.On("DeleteSummary", mock.Anything, "s1").Return(&domain.LocalSummaryListItem{ID: "s1"}, nil).Once()
+
+	for i := 0; i < 3; i++ {
+		got, err := cached.GetSummaryById(ctx, "s1")
+		assert.NoError(t, err)
+		assert.Equal(t, resp, got)
+	}
+	stats := cached.Stats()
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, 1, stats.Entries)
+	assert.Positive(t, stats.Bytes)
+	cachedBytes := stats.Bytes
+
+	// callers get copies, changing one does not leak into the cache
+	got, _ := cached.GetSummaryById(ctx, "s1")
+	got.Schemas[0].Name = "changed"
+	got, _ = cached.GetSummaryById(ctx, "s1")
+	assert.Equal(t, "public", got.Schemas[0].Name)
+
+	_, err := cached.DeleteSummary(ctx, "s1")
+	assert.NoError(t, err)
+	assert.Zero(t, cached.Stats().Bytes, "the dropped entry gives its bytes back")
+	_, err = cached.GetSummaryById(ctx, "s1")
+	assert.NoError(t, err)
+
+	mockLocal.AssertExpectations(t)
+	assert.Equal(t, uint64(2), cached.Stats().Misses)
+	assert.Equal(t, cachedBytes, cached.Stats().Bytes)
+}
+
+// Test an invalidation only drops the loads in flight for the keys it invalidates
+func TestCachedRepositoryInvalidationInFlight(t *testing.T) {
+	ctx := context.Background()
+	mockLocal := new(MockLocalRepo)
+	cached := local.NewCachedRepository(mockLocal, domain.CacheConfig{Enabled: true, MaxEntries: 10})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	for _, id := range []string{"s1", "s2"} {
+		mockLocal.On("GetSummaryById", mock.Anything, id).Return(&domain.LocalSummaryByIdResp{ID: id}, nil).
+			Run(func(mock.Arguments) {
+				started <- struct{}{}
+				<-release
+			})
+	}
+	mockLocal.On("DeleteSummary", mock.Anything, "s1").Return(&domain.LocalSummaryListItem{ID: "s1"}, nil)
+
+	// both loads read the store before s1 is deleted
+	done := make(chan struct{})
+	for _, id := range []string{"s1", "s2"} {
+		go func() {
+			_, err := cached.GetSummaryById(ctx, id)
+			assert.NoError(t, err)
+			done <- struct{}{}
+		}()
+		<-started
+	}
+	_, err := cached.DeleteSummary(ctx, "s1")
+	assert.NoError(t, err)
+	close(release)
+	<-done
+	<-done
+
+	// s2 was stored, the s1 load may predate the delete and was not
+	assert.Equal(t, 1, cached.Stats().Entries)
+	go func() {
+		for range started { // later loads do not wait
+		}
+	}()
+	_, _ = cached.GetSummaryById(ctx, "s2")
+	mockLocal.AssertNumberOfCalls(t, "GetSummaryById", 2)
+	_, _ = cached.GetSummaryById(ctx, "s1")
+	mockLocal.AssertNumberOfCalls(t, "GetSummaryById", 3)
+}
+
+// Test the stats and scans pointers of cached values are not shared between callers
+func TestCachedRepositoryDeepCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := local.NewMemoryRepository()
+	summary := conformanceSummary("s1")
+	summary.Schemas[0].Tables[0].HeapSizeMB = ptr(1.0)
+	summary.Schemas[0].Tables[1].HeapSizeMB = ptr(1.0)
+	summary.Schemas[0].Tables[0].Indexes = []domain.Index{{Name: "users_pkey", Definition: "CREATE UNIQUE INDEX users_pkey ON public.users USING btree (id)", Scans: ptr[int64](9)}}
+	_, err := repo.AddSummary(ctx, "a:db", summary)
+	assert.NoError(t, err)
+	cached := local.NewCachedRepository(repo, domain.CacheConfig{Enabled: true, MaxEntries: 10})
+
+	for i := 0; i < 2; i++ { // the miss fills the cache, the hit reads it
+		resp, err := cached.GetSummaryById(ctx, "s1")
+		assert.NoError(t, err)
+		*resp.Schemas[0].HeapSizeMB = 100
+		tables, err := cached.GetSummaryTables(ctx, "s1")
+		assert.NoError(t, err)
+		*tables[0].HeapSizeMB = 100
+		indexes, err := cached.GetSummaryIndexes(ctx, "s1")
+		assert.NoError(t, err)
+		*indexes[0].Scans = 100
+	}
+
+	resp, _ := cached.GetSummaryById(ctx, "s1")
+	assert.Equal(t, 2.0, *resp.Schemas[0].HeapSizeMB)
+	tables, _ := cached.GetSummaryTables(ctx, "s1")
+	assert.Equal(t, 1.0, *tables[0].HeapSizeMB)
+	indexes, _ := cached.GetSummaryIndexes(ctx, "s1")
+	assert.Equal(t, int64(9), *indexes[0].Scans)
+	assert.Equal(t, uint64(6), cached.Stats().Hits)
+}
+
+// Test a stream read to the end fills the cache, one larger than MaxBytes is only passed through
+func TestCachedRepositoryStreamFill(t *testing.T) {
+	ctx := context.Background()
+	rows := []domain.SummaryTable{{SummaryId: "s1", Name: "users"}, {SummaryId: "s1", Name: "orders"}}
+	stream := func(cached *local.CachedRepository) []string {
+		var names []string
+		assert.NoError(t, cached.StreamSummaryTables(ctx, "s1", func(table domain.SummaryTable) error {
+			names = append(names, table.Name)
+			return nil
+		}))
+		return names
+	}
+
+	mockLocal := new(MockLocalRepo)
+	mockLocal.On("StreamSummaryTables", mock.Anything, "s1").Return(rows, nil)
+	cached := local.NewCachedRepository(mockLocal, domain.CacheConfig{Enabled: true, MaxEntries: 10})
+	assert.Equal(t, []string{"users", "orders"}, stream(cached))
+	assert.Equal(t, []string{"users", "orders"}, stream(cached))
+	tables, err := cached.GetSummaryTables(ctx, "s1")
+	assert.NoError(t, err)
+	assert.Len(t, tables, 2)
+	mockLocal.AssertNumberOfCalls(t, "StreamSummaryTables", 1)
+
+	mockLocal = new(MockLocalRepo)
+	mockLocal.On("StreamSummaryTables", mock.Anything, "s1").Return(rows, nil)
+	cached = local.NewCachedRepository(mockLocal, domain.CacheConfig{Enabled: true, MaxBytes: 300})
+	assert.Equal(t, []string{"users", "orders"}, stream(cached), "the rows past MaxBytes are still streamed")
+	assert.Equal(t, []string{"users", "orders"}, stream(cached))
+	mockLocal.AssertNumberOfCalls(t, "StreamSummaryTables", 2)
+	assert.Zero(t, cached.Stats().Entries)
+}
+
+// Test the least recently used entry is evicted and expired entries are reloaded
+func TestCachedRepositoryBounds(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("max entries", func(t *testing.T) {
+		mockLocal := new(MockLocalRepo)
+		cached := local.NewCachedRepository(mockLocal, domain.CacheConfig{Enabled: true, MaxEntries: 2})
+		for _, id := range []string{"s1", "s2", "s3"} {
+			mockLocal.On("GetSummaryTables", mock.Anything, id).Return([]domain.SummaryTable{{SummaryId: id}}, nil)
+		}
+
+		_, _ = cached.GetSummaryTables(ctx, "s1")
+		_, _ = cached.GetSummaryTables(ctx, "s2")
+		_, _ = cached.GetSummaryTables(ctx, "s1") // s2 is now the least recently used
+		_, _ = cached.GetSummaryTables(ctx, "s3")
+		_, _ = cached.GetSummaryTables(ctx, "s1")
+
+		mockLocal.AssertNumberOfCalls(t, "GetSummaryTables", 3)
+		stats := cached.Stats()
+		assert.Equal(t, uint64(1), stats.Evictions)
+		assert.Equal(t, 2, stats.Entries)
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		mockLocal := new(MockLocalRepo)
+		cached := local.NewCachedRepository(mockLocal, domain.CacheConfig{Enabled: true, MaxBytes: 1})
+		mockLocal.On("GetSummaryTables", mock.Anything, "s1").Return([]domain.SummaryTable{{SummaryId: "s1"}}, nil)
+
+		_, _ = cached.GetSummaryTables(ctx, "s1")
+		_, _ = cached.GetSummaryTables(ctx, "s1")
+
+		// an entry larger than the whole cache is never stored
+		mockLocal.AssertNumberOfCalls(t, "GetSummaryTables", 2)
+		assert.Equal(t, int64(0), cached.Stats().Bytes)
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		mockLocal := new(MockLocalRepo)
+		cached := local.NewCachedRepository(mockLocal, domain.CacheConfig{Enabled: true, TTL: 20 * time.Millisecond, MaxEntries: 10})
+		mockLocal.On("GetLatestSummaries", mock.Anything).Return([]domain.LocalSummaryListItem{{ID: "s1"}}, nil)
+
+		_, _ = cached.GetLatestSummaries(ctx)
+		_, _ = cached.GetLatestSummaries(ctx)
+		mockLocal.AssertNumberOfCalls(t, "GetLatestSummaries", 1)
+
+		time.Sleep(30 * time.Millisecond)
+		_, _ = cached.GetLatestSummaries(ctx)
+		mockLocal.AssertNumberOfCalls(t, "GetLatestSummaries", 2)
+	})
+}
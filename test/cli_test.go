@@ -0,0 +1,144 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"pg-summary-service/internal/cli"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/pkg/mockexternal"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runCLI runs pgsummary with args and returns its exit code, stdout and stderr
+func runCLI(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := cli.Run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+var cliSyncArgs = []string{"-host", "db.local", "-port", "5432", "-user", "app", "-dbname", "shop"}
+
+// Test every command against a server, in each output format
+func TestCLIAgainstServer(t *testing.T) {
+	e := newE2E(t)
+	server := []string{"-server", e.url}
+	t.Setenv("PGPASSWORD", "secret")
+
+	for i := 0; i < 2; i++ {
+		code, out, stderr := runCLI(append(append([]string{"sync"}, server...), cliSyncArgs...)...)
+		require.Equal(t, 0, code, stderr)
+		assert.Contains(t, out, "db.local:shop")
+	}
+
+	code, out, stderr := runCLI("list", "-server", e.url, "-o", "json")
+	require.Equal(t, 0, code, stderr)
+	var items []domain.LocalSummaryListItem
+	require.NoError(t, json.Unmarshal([]byte(out), &items))
+	require.Len(t, items, 2)
+	before, after := items[1].ID, items[0].ID
+
+	// flags may follow the args
+	code, out, _ = runCLI("show", after, "-server", e.url)
+	require.Equal(t, 0, code)
+	assert.True(t, strings.HasPrefix(out, "SCHEMA"), out)
+	assert.Contains(t, out, "public")
+
+	code, out, _ = runCLI("tables", after, "-server", e.url, "-o", "csv")
+	require.Equal(t, 0, code)
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"schema", "name", "row_count", "size_mb"}, records[0])
+	assert.Greater(t, len(records), 1)
+
+	// the mock grows every table by 1% per sync
+	code, out, _ = runCLI("diff", before, after, "-server", e.url, "-o", "json")
+	require.Equal(t, 0, code)
+	var diffs []cli.TableDiff
+	require.NoError(t, json.Unmarshal([]byte(out), &diffs))
+	require.Len(t, diffs, len(records)-1)
+	for _, d := range diffs {
+		assert.Contains(t, []cli.DiffStatus{cli.DiffChanged, cli.DiffUnchanged}, d.Status)
+		assert.GreaterOrEqual(t, d.RowsDelta, int64(0))
+	}
+
+	code, out, _ = runCLI("export", e.source, "-server", e.url, "-o", "csv")
+	require.Equal(t, 0, code)
+	assert.True(t, strings.HasPrefix(out, "summary_id,synced_at,schema,name"), out)
+
+	// watch prints only what is stored after it started
+	code, out, stderr = runCLI(append([]string{"watch", "-server", e.url, "-sync", "-interval", "1ms", "-count", "2", "-o", "json"}, cliSyncArgs...)...)
+	require.Equal(t, 0, code, stderr)
+	assert.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2)
+
+	code, _, stderr = runCLI("show", "missing", "-server", e.url)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "error:")
+	code, _, _ = runCLI("show", "-server", e.url)
+	assert.Equal(t, 2, code)
+	code, _, _ = runCLI("list", "-server", e.url, "-o", "xml")
+	assert.Equal(t, 1, code)
+	for _, flags := range [][]string{{"-limit", "-1"}, {"-limit", "0"}, {"-offset", "-1"}} {
+		code, _, stderr = runCLI(append([]string{"list", "-server", e.url}, flags...)...)
+		assert.Equal(t, 2, code, flags)
+		assert.Contains(t, stderr, "limit must be at least 1")
+	}
+	code, _, _ = runCLI("nope")
+	assert.Equal(t, 2, code)
+}
+
+// Test the password is found in a pgpass file when no flag or env sets it
+func TestPgpassLookup(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".pgpass")
+	require.NoError(t, os.WriteFile(file, []byte(strings.Join([]string{
+		"# comment",
+		"other.local:5432:shop:app:nope",
+		`db.local:*:shop:app:pa\:ss\\word`,
+		"*:*:*:*:fallback",
+	}, "\n")), 0o600))
+
+	details := domain.RemoteDBDetails{Host: "db.local", Port: 6543, DBName: "shop", User: "app"}
+	assert.Equal(t, `pa:ss\word`, cli.PgpassLookup(file, details))
+	details.Host = "elsewhere"
+	assert.Equal(t, "fallback", cli.PgpassLookup(file, details))
+	assert.Empty(t, cli.PgpassLookup(filepath.Join(t.TempDir(), "missing"), details))
+}
+
+// Test -in-process syncs through a SummaryService configured from the environment, the snapshot carries it over
+func TestCLIInProcess(t *testing.T) {
+	mock := httptest.NewServer(mockexternal.New(mockexternal.Config{}))
+	defer mock.Close()
+
+	snapshot := filepath.Join(t.TempDir(), "summaries.json")
+	pgpass := filepath.Join(t.TempDir(), ".pgpass")
+	require.NoError(t, os.WriteFile(pgpass, []byte("db.local:5432:shop:app:secret\n"), 0o600))
+	t.Setenv("STORAGE", "memory")
+	t.Setenv("MEMORY_SNAPSHOT_FILE", snapshot)
+	t.Setenv("EXTERNAL_API_URL", mock.URL)
+	t.Setenv("PGPASSFILE", pgpass)
+	t.Setenv("PGPASSWORD", "")
+
+	code, _, stderr := runCLI(append([]string{"sync", "-in-process"}, cliSyncArgs...)...)
+	require.Equal(t, 0, code, stderr)
+
+	code, out, stderr := runCLI("list", "-in-process", "-o", "csv")
+	require.Equal(t, 0, code, stderr)
+	assert.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2)
+
+	code, _, _ = runCLI("list", "-in-process", "-limit", "-1")
+	assert.Equal(t, 2, code)
+
+	// the sync succeeds but its summary cannot be saved
+	t.Setenv("MEMORY_SNAPSHOT_FILE", filepath.Join(t.TempDir(), "missing", "summaries.json"))
+	code, _, stderr = runCLI(append([]string{"sync", "-in-process"}, cliSyncArgs...)...)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "failed to write memory snapshot")
+}
@@ -0,0 +1,158 @@
+package test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/handler"
+	"pg-summary-service/internal/repository/local"
+	"pg-summary-service/internal/service"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSummaryMux(t *testing.T, ids ...string) *handler.Server {
+	repo := local.NewMemoryRepository()
+	for _, id := range ids {
+		_, err := repo.AddSummary(context.Background(), "a:db", conformanceSummary(id))
+		assert.NoError(t, err)
+	}
+
+	return handler.NewServer(handler.Services{Summary: service.NewSummaryService(new(MockExtRepo), repo)})
+}
+
+// Test responses are gzipped when accepted, and left alone otherwise
+func TestResponseCompression(t *testing.T) {
+	mux := newSummaryMux(t, "s1")
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		gzipped        bool
+	}{
+		{"gzip", "gzip", true},
+		{"gzip among others", "br;q=1.0, gzip;q=0.5", true},
+		{"wildcard", "*", true},
+		{"refused", "gzip;q=0, *", false},
+		{"identity", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/summaries/s1/tables", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Header().Values("Vary"), "Accept-Encoding")
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			var body io.Reader = rec.Body
+			if tt.gzipped {
+				assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+				gz, err := gzip.NewReader(rec.Body)
+				if !assert.NoError(t, err) {
+					return
+				}
+				body = gz
+			} else {
+				assert.Empty(t, rec.Header().Get("Content-Encoding"))
+			}
+
+			var tables []domain.SummaryTable
+			assert.NoError(t, json.NewDecoder(body).Decode(&tables))
+			assert.Len(t, tables, 3)
+		})
+	}
+
+	// the gzip representation has its own ETag, a 304 carries it and has no body to encode
+	req := httptest.NewRequest(http.MethodGet, "/v1/summaries/s1/tables", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	identity := rec.Header().Get("ETag")
+	assert.NotContains(t, identity, "-gzip")
+
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	etag := rec.Header().Get("ETag")
+	assert.Equal(t, strings.TrimSuffix(identity, `"`)+`-gzip"`, etag)
+
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Empty(t, rec.Body.Bytes())
+
+	// the gzip ETag does not validate the identity representation
+	req.Header.Del("Accept-Encoding")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, identity, rec.Header().Get("ETag"))
+}
+
+// Test the summary list is streamed as NDJSON when asked for
+func TestSummariesNDJSON(t *testing.T) {
+	mux := newSummaryMux(t, "s1", "s2")
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/summaries?offset=0&limit=10", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+	var ids []string
+	scanner := bufio.NewScanner(strings.NewReader(rec.Body.String()))
+	for scanner.Scan() {
+		var item domain.LocalSummaryListItem
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &item))
+		ids = append(ids, item.ID)
+	}
+	assert.ElementsMatch(t, []string{"s1", "s2"}, ids)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/summaries?format=csv", nil)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+}
+
+// Test a flush before the first write still labels the body with the encoding it is sent in
+func TestResponseCompressionFlushFirst(t *testing.T) {
+	for _, body := range []string{"hello", ""} {
+		srv := httptest.NewServer(handler.ApplyMiddlewares(http.MethodGet, handler.AuthTypeNone, nil, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.(http.Flusher).Flush()
+			_, _ = io.WriteString(w, body)
+		}))
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip") // set by hand, so the transport leaves the body encoded
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			srv.Close()
+			continue
+		}
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		gz, err := gzip.NewReader(resp.Body)
+		if assert.NoError(t, err, "the body is a gzip stream, even an empty one") {
+			plain, err := io.ReadAll(gz)
+			assert.NoError(t, err)
+			assert.Equal(t, body, string(plain))
+		}
+		resp.Body.Close()
+		srv.Close()
+	}
+}
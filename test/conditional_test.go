@@ -0,0 +1,81 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"pg-summary-service/internal/handler"
+	"pg-summary-service/internal/repository/local"
+	"pg-summary-service/internal/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test summaries answer conditional GETs with 304 and carry caching headers
+func TestSummaryConditionalRequests(t *testing.T) {
+	repo := local.NewMemoryRepository()
+	_, err := repo.AddSummary(context.Background(), "a:db", conformanceSummary("s1"))
+	assert.NoError(t, err)
+	stored, _ := repo.GetSummaryById(context.Background(), "s1")
+
+	mux := handler.NewServer(handler.Services{Summary: service.NewSummaryService(new(MockExtRepo), repo)})
+
+	get := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := get("/v1/summaries/s1", nil)
+	assert.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, stored.SyncedAt.UTC().Format(http.TimeFormat), first.Header().Get("Last-Modified"))
+	// a summary can be deleted, so nothing is immutable
+	for _, path := range []string{"/v1/summaries/s1", "/v1/summaries/s1/tables", "/v1/summaries/s1/tables?format=csv", "/v1/summaries/s1/indexes", "/v1/summaries/s1/indexes/report"} {
+		rec := get(path, nil)
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+		assert.Equal(t, "private, no-cache", rec.Header().Get("Cache-Control"), path)
+		assert.NotEmpty(t, rec.Header().Get("ETag"), path)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		code    int
+	}{
+		{"matching etag", "/v1/summaries/s1", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"weak and listed etag", "/v1/summaries/s1", map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{"stale etag", "/v1/summaries/s1", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"not modified since", "/v1/summaries/s1", map[string]string{"If-Modified-Since": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}, http.StatusNotModified},
+		{"modified since", "/v1/summaries/s1", map[string]string{"If-Modified-Since": stored.SyncedAt.Add(-time.Hour).UTC().Format(http.TimeFormat)}, http.StatusOK},
+		{"etag wins over date", "/v1/summaries/s1", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}, http.StatusOK},
+		{"csv has its own etag", "/v1/summaries/s1?format=csv", map[string]string{"If-None-Match": etag}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(tt.path, tt.headers)
+			assert.Equal(t, tt.code, rec.Code)
+			if tt.code == http.StatusNotModified {
+				assert.Empty(t, rec.Body.String())
+				assert.Equal(t, etag, rec.Header().Get("ETag"))
+			}
+		})
+	}
+
+	list := get("/v1/summaries?offset=0&limit=10", nil)
+	assert.Equal(t, http.StatusOK, list.Code)
+	assert.Equal(t, "private, no-cache", list.Header().Get("Cache-Control"))
+	assert.Equal(t, http.StatusNotModified, get("/v1/summaries?offset=0&limit=10", map[string]string{"If-None-Match": list.Header().Get("ETag")}).Code)
+
+	missing := get("/v1/summaries/missing", nil)
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+	assert.Empty(t, missing.Header().Get("Cache-Control"))
+}
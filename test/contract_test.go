@@ -0,0 +1,173 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/repository/external"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const validV1Payload = `{"summary_id": "s1", "schemas": [{"name": "public", "tables": [{"name": "users", "row_count": 10, "size_mb": 1.5}]}]}`
+
+// Test strict parsing lists every violation and lenient parsing keeps the old behaviour
+func TestParseExternalSummary(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		body       string
+		mode       external.ParseMode
+		violations []string // nil means the payload is accepted
+		notFound   bool
+	}{
+		{name: "valid v1", body: validV1Payload, mode: external.ParseStrict},
+		{name: "version field", body: `{"version": "v1", "summary_id": "s1", "schemas": [{"name": "public"}]}`, mode: external.ParseStrict},
+		{
+			name: "unknown fields", mode: external.ParseStrict,
+			body:       `{"summary_id": "s1", "extra": 1, "schemas": [{"name": "public", "owner": "x", "tables": [{"name": "t", "row_count": 1, "size_mb": 1, "bloat": 2}]}]}`,
+			violations: []string{"extra: unknown field", "schemas[0].owner: unknown field", "schemas[0].tables[0].bloat: unknown field"},
+		},
+		{
+			name: "negative values and duplicates", mode: external.ParseStrict,
+			body: `{"summary_id": "s1", "schemas": [
+				{"name": "public", "tables": [{"name": "t", "row_count": -1, "size_mb": 1}, {"name": "t", "row_count": 1, "size_mb": -2}]},
+				{"name": "public"}]}`,
+			violations: []string{
+				"schemas[0].tables[0].row_count: cannot be negative, got -1",
+				`schemas[0].tables[1].name: duplicate table "t", first at schemas[0].tables[0]`,
+				"schemas[0].tables[1].size_mb: must be a non negative number, got -2",
+				`schemas[1].name: duplicate schema "public", first at schemas[0]`,
+			},
+		},
+		{
+			name: "table statistics", mode: external.ParseStrict,
+			body: `{"summary_id": "s1", "schemas": [{"name": "public", "tables": [{"name": "t", "row_count": 1, "size_mb": 1,
+				"heap_size_mb": 0.75, "index_size_mb": 0.25, "toast_size_mb": 0, "dead_tuples": 3, "seq_scans": 5, "idx_scans": 7,
+				"last_vacuum": "2026-10-01T00:00:00Z", "last_autovacuum": "2026-10-02T00:00:00Z", "last_analyze": "2026-10-03T00:00:00Z"}]}]}`,
+		},
+		{
+			name: "negative table statistics", mode: external.ParseStrict,
+			body: `{"summary_id": "s1", "schemas": [{"name": "public", "tables": [{"name": "t", "row_count": 1, "size_mb": 1, "index_size_mb": -1, "seq_scans": -2}]}]}`,
+			violations: []string{
+				"schemas[0].tables[0].index_size_mb: must be a non negative number, got -1",
+				"schemas[0].tables[0].seq_scans: cannot be negative, got -2",
+			},
+		},
+		{
+			name: "columns", mode: external.ParseStrict,
+			body: `{"summary_id": "s1", "schemas": [{"name": "public", "tables": [{"name": "t", "row_count": 1, "size_mb": 1, "columns": [
+				{"name": "id", "data_type": "bigint", "nullable": false, "n_distinct": -1, "null_frac": 0, "avg_width": 8},
+				{"name": "id", "nullable": true, "default": "0", "null_frac": 1.5, "owner": "x"}]}]}]}`,
+			violations: []string{
+				"schemas[0].tables[0].columns[1].owner: unknown field",
+				`schemas[0].tables[0].columns[1].name: duplicate column "id", first at schemas[0].tables[0].columns[0]`,
+				"schemas[0].tables[0].columns[1].data_type: is required",
+				"schemas[0].tables[0].columns[1].null_frac: must be between 0 and 1, got 1.5",
+			},
+		},
+		{
+			name: "indexes", mode: external.ParseStrict,
+			body: `{"summary_id": "s1", "schemas": [{"name": "public", "tables": [{"name": "t", "row_count": 1, "size_mb": 1, "indexes": [
+				{"name": "t_pkey", "definition": "CREATE UNIQUE INDEX t_pkey ON public.t USING btree (id)", "size_mb": 0.1, "scans": 4, "unique": true, "primary": true},
+				{"name": "t_pkey", "size_mb": -1, "scans": -1}]}]}]}`,
+			violations: []string{
+				`schemas[0].tables[0].indexes[1].name: duplicate index "t_pkey", first at schemas[0].tables[0].indexes[0]`,
+				"schemas[0].tables[0].indexes[1].definition: is required",
+				"schemas[0].tables[0].indexes[1].size_mb: must be a non negative number, got -1",
+				"schemas[0].tables[0].indexes[1].scans: cannot be negative, got -1",
+			},
+		},
+		{
+			name: "missing id and schemas", mode: external.ParseStrict, body: `{}`,
+			violations: []string{"summary_id: is required", "schemas: at least one schema is required"},
+		},
+		{
+			name: "wrong type", mode: external.ParseLenient,
+			body:       `{"summary_id": "s1", "schemas": [{"name": "public", "tables": [{"name": "t", "row_count": "ten"}]}]}`,
+			violations: []string{"schemas[0].tables[0].row_count: expected int, got string"},
+		},
+		{
+			name: "unsupported version", mode: external.ParseLenient, header: "2", body: validV1Payload,
+			violations: []string{`version: unsupported contract version "2"`},
+		},
+		{
+			name: "lenient accepts violations", mode: external.ParseLenient,
+			body: `{"summary_id": "s1", "extra": true, "schemas": [{"name": "public", "tables": [{"name": "t", "row_count": -1}]}]}`,
+		},
+		{name: "lenient rejects empty", mode: external.ParseLenient, body: `{"summary_id": "s1", "schemas": []}`, notFound: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.header != "" {
+				header.Set(external.VersionHeader, tt.header)
+			}
+
+			summary, err := external.NewParser(tt.mode).Parse(header, []byte(tt.body))
+			switch {
+			case tt.notFound:
+				var appErr *domain.AppError
+				assert.True(t, errors.As(err, &appErr))
+				assert.Equal(t, http.StatusNotFound, appErr.Code)
+			case tt.violations == nil:
+				assert.NoError(t, err)
+				assert.Equal(t, "s1", summary.Id)
+			default:
+				var contractErr *domain.ContractError
+				if !assert.True(t, errors.As(err, &contractErr), "expected a ContractError, got %v", err) {
+					return
+				}
+				var got []string
+				for _, v := range contractErr.Violations {
+					got = append(got, v.String())
+				}
+				assert.Equal(t, tt.violations, got)
+
+				// and it is sent as a 502
+				var appErr *domain.AppError
+				assert.True(t, errors.As(err, &appErr))
+				assert.Equal(t, http.StatusBadGateway, appErr.Code)
+			}
+		})
+	}
+}
+
+// Test a decoder registered for a new version is picked from the header and the body
+func TestParserRegisterVersion(t *testing.T) {
+	parser := external.NewParser(external.ParseStrict)
+	parser.Register("v2", func(body []byte) (*domain.ExternalSummaryResp, []domain.ContractViolation, error) {
+		return &domain.ExternalSummaryResp{Id: "from-v2", Schemas: []domain.Schema{{Name: "public"}}}, nil, nil
+	})
+
+	header := http.Header{}
+	header.Set(external.VersionHeader, "2")
+	summary, err := parser.Parse(header, []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "from-v2", summary.Id)
+
+	summary, err = parser.Parse(http.Header{}, []byte(`{"version": 2}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "from-v2", summary.Id)
+}
+
+// Test FetchSummaries reads the version header of the response
+func TestFetchSummariesContract(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(external.VersionHeader, "1")
+		_, _ = w.Write([]byte(`{"summary_id": "s1", "schemas": [{"name": "public", "tables": [{"name": "t", "row_count": -5}]}]}`))
+	}))
+	defer server.Close()
+
+	repo := external.NewExternalRepository(server.URL, 1, external.WithParser(external.NewParser(external.ParseStrict)))
+	_, err := repo.FetchSummaries(context.Background(), domain.RemoteDBDetails{})
+
+	var appErr *domain.AppError
+	assert.True(t, errors.As(err, &appErr))
+	assert.Equal(t, http.StatusBadGateway, appErr.Code)
+	assert.Contains(t, appErr.Message, "schemas[0].tables[0].row_count")
+}
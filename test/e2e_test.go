@@ -0,0 +1,361 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/handler"
+	"pg-summary-service/internal/repository/alert"
+	"pg-summary-service/internal/repository/audit"
+	"pg-summary-service/internal/repository/external"
+	"pg-summary-service/internal/repository/local"
+	"pg-summary-service/internal/repository/webhook"
+	"pg-summary-service/internal/service"
+	"pg-summary-service/pkg/mockexternal"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// e2e is the service wired like main, over in-memory stores and a mock external API, served by an httptest server
+type e2e struct {
+	t      *testing.T
+	url    string
+	server http.Handler // the handler behind url, for requests from other client addresses
+	mock   *mockexternal.Server
+	hooks  *httptest.Server // receives webhook deliveries
+	source string           // source of e2eTarget
+}
+
+// e2eTarget syncs from the mock, targets under .down and .bad go to providers answering 503 and 400
+var e2eTarget = `{"host": "db.local", "port": 5432, "user": "app", "password": "secret", "dbname": "shop"}`
+
+func newE2E(t *testing.T) *e2e {
+	mock := mockexternal.New(mockexternal.Config{Shape: mockexternal.Shape{MaxSchemas: 2, MaxTables: 4}})
+	mockServer := httptest.NewServer(mock)
+	t.Cleanup(mockServer.Close)
+	hooks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(hooks.Close)
+
+	provider := func(name, url string, hosts ...string) external.Provider {
+		return external.Provider{
+			ExternalProvider: domain.ExternalProvider{Name: name, URL: url, Hosts: hosts},
+			Repo:             external.NewExternalRepository(url, 1, external.WithBreaker(domain.BreakerConfig{FailureThreshold: 5, CoolDown: time.Minute})),
+		}
+	}
+	extRepo := external.NewProviderRouter([]external.Provider{
+		provider("mock", mockServer.URL),
+		provider("down", mockServer.URL+"?status=503", "*.down"),
+		provider("bad", mockServer.URL+"?status=400", "*.bad"),
+	})
+
+	cache := local.NewCachedRepository(local.NewMemoryRepository(), domain.CacheConfig{Enabled: true, MaxEntries: 100})
+	webhookSvc := service.NewWebhookService(webhook.NewMemoryRepository(), 1, time.Millisecond)
+	t.Cleanup(webhookSvc.Close)
+	alertSvc := service.NewAlertService(alert.NewMemoryRepository(), cache, webhookSvc)
+	events := service.NewEventStream(100)
+	auditLog := service.NewAuditLog(audit.NewMemoryRepository())
+	svc := service.NewSummaryService(extRepo, cache, service.WithEventSinks(webhookSvc, events), service.WithAlertEvaluator(alertSvc),
+		service.WithAuditLog(auditLog))
+
+	srv := handler.NewServer(handler.Services{
+		Summary:   svc,
+		Webhooks:  webhookSvc,
+		Alerts:    alertSvc,
+		Janitor:   service.NewJanitor(svc, domain.RetentionPolicy{}),
+		Cache:     cache,
+		Breakers:  extRepo,
+		Providers: extRepo,
+		Events:    events,
+		Audit:     auditLog,
+		Legacy:    e2eLegacy,
+		// the test client connects from loopback, like a proxy in front of the service
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+	})
+	server := httptest.NewServer(srv)
+	t.Cleanup(server.Close)
+
+	return &e2e{t: t, url: server.URL, server: srv, mock: mock, hooks: hooks, source: "db.local:shop"}
+}
+
+// e2eLegacy serves the unversioned paths with LEGACY_ROUTES_DEPRECATED and LEGACY_ROUTES_SUNSET set
+var e2eLegacy = domain.LegacyRoutes{
+	Enabled:    true,
+	Deprecated: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+	Sunset:     time.Date(2027, 4, 30, 0, 0, 0, 0, time.UTC),
+}
+
+// do sends a request and returns the response with its body read
+func (e *e2e) do(method, path, body string, headers map[string]string) (*http.Response, string) {
+	e.t.Helper()
+	req, err := http.NewRequest(method, e.url+path, strings.NewReader(body))
+	require.NoError(e.t, err)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(e.t, err)
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(e.t, err)
+	return resp, string(raw)
+}
+
+// create posts body and returns the id of the created resource
+func (e *e2e) create(path, body string) string {
+	e.t.Helper()
+	resp, raw := e.do(http.MethodPost, path, body, nil)
+	require.Equal(e.t, http.StatusCreated, resp.StatusCode, raw)
+	var created struct {
+		Id string `json:"id"`
+	}
+	require.NoError(e.t, json.Unmarshal([]byte(raw), &created))
+	return created.Id
+}
+
+type e2eCase struct {
+	name    string
+	method  string
+	path    string
+	body    string
+	headers map[string]string
+	code    int
+	// contains is a substring of the body, contentType a prefix of the Content-Type, both optional
+	contains    string
+	contentType string
+	allow       string
+}
+
+func (e *e2e) run(tests []e2eCase) {
+	for _, tt := range tests {
+		e.t.Run(tt.name, func(t *testing.T) {
+			resp, body := e.do(tt.method, tt.path, tt.body, tt.headers)
+			assert.Equal(t, tt.code, resp.StatusCode, body)
+			assert.Contains(t, body, tt.contains)
+			assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), tt.contentType),
+				"Content-Type %q, want %q", resp.Header.Get("Content-Type"), tt.contentType)
+			if tt.allow != "" {
+				assert.Equal(t, tt.allow, resp.Header.Get("Allow"))
+			}
+		})
+	}
+}
+
+// Test syncs end to end: validation, the external API's failures and how each maps to a status
+func TestE2ESync(t *testing.T) {
+	e := newE2E(t)
+	target := func(host string) string {
+		return strings.Replace(e2eTarget, "db.local", host, 1)
+	}
+
+	e.run([]e2eCase{
+		{name: "sync", method: http.MethodPost, path: "/v1/summary/sync", body: e2eTarget, code: http.StatusOK, contains: `"status":"sync triggered"`, contentType: "application/json"},
+		{name: "invalid json", method: http.MethodPost, path: "/v1/summary/sync", body: `{"host": `, code: http.StatusBadRequest, contains: "invalid request payload"},
+		{name: "missing password", method: http.MethodPost, path: "/v1/summary/sync", body: strings.Replace(e2eTarget, `"secret"`, `""`, 1), code: http.StatusBadRequest, contains: "password cannot be empty"},
+		{name: "missing port", method: http.MethodPost, path: "/v1/summary/sync", body: strings.Replace(e2eTarget, `5432`, `0`, 1), code: http.StatusBadRequest, contains: "port cannot be empty"},
+		{name: "external client error", method: http.MethodPost, path: "/v1/summary/sync", body: target("db.bad"), code: http.StatusBadRequest, contains: "external API returned 400"},
+		{name: "external unreachable", method: http.MethodPost, path: "/v1/summary/sync", body: target("db.down"), code: http.StatusBadGateway, contains: "external service unreachable"},
+		{name: "wrong method", method: http.MethodGet, path: "/v1/summary/sync", code: http.StatusMethodNotAllowed, allow: http.MethodPost},
+	})
+
+	// a rejected request is answered once and never reaches the external API
+	resp, body := e.do(http.MethodPost, "/v1/summary/sync", strings.Replace(e2eTarget, `"app"`, `""`, 1), nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "user cannot be empty\n", body)
+	assert.Equal(t, mockexternal.Stats{Requests: 3, Summaries: 1, Failures: 2}, e.mock.Stats())
+
+	resp, body = e.do(http.MethodGet, "/admin/providers", "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var providers []domain.ProviderStats
+	require.NoError(t, json.Unmarshal([]byte(body), &providers))
+	require.Len(t, providers, 3)
+	assert.Equal(t, uint64(1), providers[0].Successes)
+}
+
+// Test pagination of the summary list, limit and offset are read from their own params
+func TestE2EPagination(t *testing.T) {
+	e := newE2E(t)
+	for i := 0; i < 3; i++ {
+		resp, body := e.do(http.MethodPost, "/v1/summary/sync", e2eTarget, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	}
+
+	page := func(query string) []domain.LocalSummaryListItem {
+		resp, body := e.do(http.MethodGet, "/v1/summaries"+query, "", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		var items []domain.LocalSummaryListItem
+		require.NoError(t, json.Unmarshal([]byte(body), &items))
+		return items
+	}
+
+	all := page("")
+	assert.Len(t, all, 3)
+	assert.Equal(t, listIds(all[:2]), listIds(page("?limit=2")))
+	assert.Equal(t, listIds(all[1:]), listIds(page("?offset=1")))
+	assert.Equal(t, listIds(all[2:]), listIds(page("?offset=2&limit=2")))
+	assert.Empty(t, page("?offset=5&limit=2"))
+	// a value that is not a number falls back to the default
+	assert.Len(t, page("?limit=abc"), 3)
+
+	e.run([]e2eCase{
+		{name: "zero limit", method: http.MethodGet, path: "/v1/summaries?limit=0", code: http.StatusBadRequest, contains: "limit must be at least 1"},
+		{name: "negative offset", method: http.MethodGet, path: "/v1/summaries?offset=-1", code: http.StatusBadRequest, contains: "offset cannot be negative"},
+		{name: "ndjson", method: http.MethodGet, path: "/v1/summaries?limit=1", headers: map[string]string{"Accept": "application/x-ndjson"}, code: http.StatusOK, contentType: "application/x-ndjson"},
+		{name: "not acceptable", method: http.MethodGet, path: "/v1/summaries", headers: map[string]string{"Accept": "text/csv"}, code: http.StatusNotAcceptable},
+	})
+}
+
+// Test every route once for its success status and its error statuses
+func TestE2ERoutes(t *testing.T) {
+	e := newE2E(t)
+	resp, body := e.do(http.MethodPost, "/v1/summary/sync", e2eTarget, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	_, body = e.do(http.MethodGet, "/v1/summaries", "", nil)
+	var items []domain.LocalSummaryListItem
+	require.NoError(t, json.Unmarshal([]byte(body), &items))
+	require.Len(t, items, 1)
+	id := items[0].ID
+	_, body = e.do(http.MethodGet, "/v1/summaries/"+id+"/tables", "", nil)
+	var tables []domain.SummaryTable
+	require.NoError(t, json.Unmarshal([]byte(body), &tables))
+	require.NotEmpty(t, tables)
+	columnsPath := "/v1/summaries/" + id + "/tables/" + tables[0].Id + "/columns"
+
+	hookId := e.create("/v1/webhooks", fmt.Sprintf(`{"url": %q, "events": ["sync.succeeded"]}`, e.hooks.URL))
+	ruleId := e.create("/v1/alert-rules", `{"name": "big tables", "kind": "table_size_above", "threshold": 1000000}`)
+
+	e.run([]e2eCase{
+		// summaries
+		{name: "get summary", method: http.MethodGet, path: "/v1/summaries/" + id, code: http.StatusOK, contains: id, contentType: "application/json"},
+		{name: "summary as csv", method: http.MethodGet, path: "/v1/summaries/" + id + "?format=csv", code: http.StatusOK, contentType: "text/csv"},
+		{name: "unknown format", method: http.MethodGet, path: "/v1/summaries/" + id + "?format=xml", code: http.StatusBadRequest, contains: "unsupported format"},
+		{name: "missing summary", method: http.MethodGet, path: "/v1/summaries/missing", code: http.StatusNotFound},
+		{name: "summary tables", method: http.MethodGet, path: "/v1/summaries/" + id + "/tables", code: http.StatusOK, contentType: "application/json"},
+		{name: "tables of missing summary", method: http.MethodGet, path: "/v1/summaries/missing/tables", code: http.StatusNotFound},
+		{name: "table columns", method: http.MethodGet, path: columnsPath, code: http.StatusOK, contains: `"data_type":"bigint"`, contentType: "application/json"},
+		{name: "columns of missing table", method: http.MethodGet, path: "/v1/summaries/" + id + "/tables/missing/columns", code: http.StatusNotFound},
+		{name: "columns of table in missing summary", method: http.MethodGet, path: "/v1/summaries/missing/tables/" + tables[0].Id + "/columns", code: http.StatusNotFound},
+		{name: "columns as csv", method: http.MethodGet, path: columnsPath + "?format=csv", code: http.StatusNotAcceptable},
+		{name: "summary indexes", method: http.MethodGet, path: "/v1/summaries/" + id + "/indexes", code: http.StatusOK, contains: `"primary":true`, contentType: "application/json"},
+		{name: "index report", method: http.MethodGet, path: "/v1/summaries/" + id + "/indexes/report", code: http.StatusOK, contains: `"index_to_heap_ratio"`, contentType: "application/json"},
+		{name: "index report of missing summary", method: http.MethodGet, path: "/v1/summaries/missing/indexes/report", code: http.StatusNotFound},
+		{name: "index report as csv", method: http.MethodGet, path: "/v1/summaries/" + id + "/indexes/report?format=csv", code: http.StatusNotAcceptable},
+		{name: "unknown summary resource", method: http.MethodGet, path: "/v1/summaries/" + id + "/columns", code: http.StatusNotFound},
+		{name: "delete summary tables", method: http.MethodDelete, path: "/v1/summaries/" + id + "/tables", code: http.StatusMethodNotAllowed, allow: "GET, HEAD"},
+		{name: "export", method: http.MethodGet, path: "/v1/exports/summaries?source=" + e.source, code: http.StatusOK, contains: `"schema":"public"`},
+		{name: "export without source", method: http.MethodGet, path: "/v1/exports/summaries", code: http.StatusBadRequest, contains: "source query param is required"},
+		{name: "export of unknown source", method: http.MethodGet, path: "/v1/exports/summaries?source=nope", code: http.StatusNotFound},
+		{name: "export is a summary id", method: http.MethodGet, path: "/v1/summaries/export", code: http.StatusNotFound, contains: "summary with id export not found"},
+		{name: "metrics", method: http.MethodGet, path: "/metrics", code: http.StatusOK, contains: "pg_summary_external_provider_requests_total", contentType: "text/plain"},
+
+		// webhooks
+		{name: "list webhooks", method: http.MethodGet, path: "/v1/webhooks", code: http.StatusOK, contains: hookId},
+		{name: "create webhook with bad url", method: http.MethodPost, path: "/v1/webhooks", body: `{"url": "ftp://x", "events": ["sync.succeeded"]}`, code: http.StatusBadRequest, contains: "absolute http(s) url"},
+		{name: "create webhook with bad json", method: http.MethodPost, path: "/v1/webhooks", body: `[`, code: http.StatusBadRequest, contains: "invalid request payload"},
+		{name: "create webhook with unknown event", method: http.MethodPost, path: "/v1/webhooks", body: fmt.Sprintf(`{"url": %q, "events": ["nope"]}`, e.hooks.URL), code: http.StatusBadRequest, contains: "unknown event type"},
+		{name: "get webhook", method: http.MethodGet, path: "/v1/webhooks/" + hookId, code: http.StatusOK, contains: hookId},
+		{name: "get missing webhook", method: http.MethodGet, path: "/v1/webhooks/missing", code: http.StatusNotFound},
+		{name: "update webhook", method: http.MethodPut, path: "/v1/webhooks/" + hookId, body: fmt.Sprintf(`{"url": %q, "events": ["alert.fired"]}`, e.hooks.URL), code: http.StatusOK, contains: "alert.fired"},
+		{name: "webhook deliveries", method: http.MethodGet, path: "/v1/webhooks/" + hookId + "/deliveries", code: http.StatusOK},
+		{name: "webhook deliveries capped", method: http.MethodGet, path: "/v1/webhooks/" + hookId + "/deliveries?limit=100000", code: http.StatusOK},
+		{name: "webhook deliveries negative limit", method: http.MethodGet, path: "/v1/webhooks/" + hookId + "/deliveries?limit=-1", code: http.StatusBadRequest, contains: "limit must be at least 1"},
+		{name: "webhook deliveries zero limit", method: http.MethodGet, path: "/v1/webhooks/" + hookId + "/deliveries?limit=0", code: http.StatusBadRequest, contains: "limit must be at least 1"},
+		{name: "webhook deliveries negative offset", method: http.MethodGet, path: "/v1/webhooks/" + hookId + "/deliveries?offset=-1", code: http.StatusBadRequest, contains: "offset cannot be negative"},
+		{name: "post webhook deliveries", method: http.MethodPost, path: "/v1/webhooks/" + hookId + "/deliveries", code: http.StatusMethodNotAllowed, allow: "GET, HEAD"},
+		{name: "patch webhooks", method: http.MethodPatch, path: "/v1/webhooks", code: http.StatusMethodNotAllowed, allow: "GET, HEAD, POST"},
+
+		// alert rules and alerts
+		{name: "list alert rules", method: http.MethodGet, path: "/v1/alert-rules", code: http.StatusOK, contains: ruleId},
+		{name: "create alert rule with unknown kind", method: http.MethodPost, path: "/v1/alert-rules", body: `{"name": "x", "kind": "nope"}`, code: http.StatusBadRequest, contains: "unknown rule kind"},
+		{name: "get alert rule", method: http.MethodGet, path: "/v1/alert-rules/" + ruleId, code: http.StatusOK, contains: "big tables"},
+		{name: "update alert rule", method: http.MethodPut, path: "/v1/alert-rules/" + ruleId, body: `{"name": "huge tables", "kind": "table_size_above", "threshold": 2000000}`, code: http.StatusOK, contains: "huge tables"},
+		{name: "update alert rule with bad json", method: http.MethodPut, path: "/v1/alert-rules/" + ruleId, body: `{`, code: http.StatusBadRequest, contains: "invalid request payload"},
+		{name: "get missing alert rule", method: http.MethodGet, path: "/v1/alert-rules/missing", code: http.StatusNotFound},
+		{name: "list alerts", method: http.MethodGet, path: "/v1/alerts?source=" + e.source, code: http.StatusOK},
+		{name: "list alerts with bad filter", method: http.MethodGet, path: "/v1/alerts?acknowledged=maybe", code: http.StatusBadRequest, contains: "acknowledged must be true or false"},
+		{name: "ack missing alert", method: http.MethodPost, path: "/v1/alerts/missing/ack", body: `{"by": "ops"}`, code: http.StatusNotFound},
+		{name: "unknown alert action", method: http.MethodPost, path: "/v1/alerts/missing/mute", body: `{}`, code: http.StatusNotFound},
+
+		// admin
+		{name: "retention", method: http.MethodGet, path: "/admin/retention", code: http.StatusOK, contains: `"policy"`},
+		{name: "run retention without policy", method: http.MethodPost, path: "/admin/retention/run", code: http.StatusBadRequest, contains: "no retention policy configured"},
+		{name: "cache", method: http.MethodGet, path: "/admin/cache", code: http.StatusOK, contains: `"enabled":true`},
+		{name: "breakers", method: http.MethodGet, path: "/admin/breakers", code: http.StatusOK, contains: `"state":"closed"`},
+		{name: "providers", method: http.MethodGet, path: "/admin/providers", code: http.StatusOK, contains: `"name":"mock"`},
+
+		// deletes last, they remove what the cases above read
+		{name: "delete alert rule", method: http.MethodDelete, path: "/v1/alert-rules/" + ruleId, code: http.StatusNoContent},
+		{name: "delete webhook", method: http.MethodDelete, path: "/v1/webhooks/" + hookId, code: http.StatusNoContent},
+		{name: "delete missing webhook", method: http.MethodDelete, path: "/v1/webhooks/" + hookId, code: http.StatusNotFound},
+		{name: "delete summary", method: http.MethodDelete, path: "/v1/summaries/" + id, code: http.StatusNoContent},
+		{name: "get deleted summary", method: http.MethodGet, path: "/v1/summaries/" + id, code: http.StatusNotFound},
+		{name: "delete missing summary", method: http.MethodDelete, path: "/v1/summaries/" + id, code: http.StatusNotFound},
+
+		// unknown
+		{name: "unknown path", method: http.MethodGet, path: "/v1/nope", code: http.StatusNotFound, contains: `"code":404`, contentType: "application/json"},
+		{name: "trailing slash", method: http.MethodGet, path: "/v1/summaries/" + id + "/", code: http.StatusNotFound, contentType: "application/json"},
+		{name: "wrong method", method: http.MethodPut, path: "/v1/summaries", code: http.StatusMethodNotAllowed, allow: "GET, HEAD", contentType: "application/json"},
+	})
+}
+
+// Test the summary gauges and the service metrics are served apart
+func TestE2EMetrics(t *testing.T) {
+	e := newE2E(t)
+	resp, body := e.do(http.MethodPost, "/v1/summary/sync", e2eTarget, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	resp, body = e.do(http.MethodGet, "/metrics/summaries", "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Contains(t, body, "pg_summary_table_rows{")
+	for _, family := range []string{"pg_summary_cache_", "pg_summary_external_breaker_", "pg_summary_external_provider_"} {
+		assert.NotContains(t, body, family)
+	}
+
+	resp, body = e.do(http.MethodGet, "/metrics", "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	for _, family := range []string{"pg_summary_cache_", "pg_summary_external_breaker_", "pg_summary_external_provider_"} {
+		assert.Contains(t, body, family)
+	}
+	assert.NotContains(t, body, "pg_summary_table_rows")
+}
+
+// Test the unversioned paths answer like /v1 with deprecation headers pointing at their /v1 successor
+func TestE2ELegacyRoutes(t *testing.T) {
+	e := newE2E(t)
+	resp, body := e.do(http.MethodPost, "/summary/sync", e2eTarget, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	versioned, v1Body := e.do(http.MethodGet, "/v1/summaries", "", nil)
+	legacy, legacyBody := e.do(http.MethodGet, "/summaries", "", nil)
+	assert.Equal(t, http.StatusOK, legacy.StatusCode)
+	assert.JSONEq(t, v1Body, legacyBody)
+
+	assert.Empty(t, versioned.Header.Get("Deprecation"))
+	assert.Empty(t, versioned.Header.Get("Sunset"))
+	assert.Equal(t, "@1792368000", legacy.Header.Get("Deprecation"))
+	assert.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", legacy.Header.Get("Sunset"))
+	assert.Equal(t, `</v1/summaries>; rel="successor-version"`, legacy.Header.Get("Link"))
+
+	// a legacy path without the method is still a 405, and there is no legacy alias of an unknown /v1 path
+	e.run([]e2eCase{
+		{name: "legacy wrong method", method: http.MethodPut, path: "/summaries", code: http.StatusMethodNotAllowed, allow: "GET, HEAD"},
+		{name: "legacy unknown path", method: http.MethodGet, path: "/nope", code: http.StatusNotFound, contentType: "application/json"},
+		{name: "metrics are not versioned", method: http.MethodGet, path: "/v1/metrics/summaries", code: http.StatusNotFound},
+		{name: "admin is not versioned", method: http.MethodGet, path: "/v1/admin/cache", code: http.StatusNotFound},
+	})
+
+	// the operational routes are not aliases, nothing deprecates them
+	for _, path := range []string{"/metrics", "/metrics/summaries", "/admin/cache"} {
+		resp, body := e.do(http.MethodGet, path, "", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.Empty(t, resp.Header.Get("Deprecation"), path)
+		assert.Empty(t, resp.Header.Get("Link"), path)
+	}
+}
@@ -0,0 +1,202 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/handler"
+	"pg-summary-service/internal/repository/local"
+	"pg-summary-service/internal/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func streamSeqs(items []service.StreamEvent) []uint64 {
+	var seqs []uint64
+	for _, item := range items {
+		seqs = append(seqs, item.Seq)
+	}
+	return seqs
+}
+
+// Test the stream replays what its bounded buffer still holds and drops subscribers that fall behind
+func TestEventStreamReplay(t *testing.T) {
+	stream := service.NewEventStream(3)
+	for _, source := range []string{"a", "b", "a", "b", "a"} {
+		stream.Publish(domain.Event{Type: domain.EventSyncStarted, Source: source})
+	}
+
+	replay, _, cancel := stream.Subscribe(0, nil)
+	cancel()
+	assert.Equal(t, []uint64{3, 4, 5}, streamSeqs(replay), "the two oldest fell out of the buffer")
+
+	replay, _, cancel = stream.Subscribe(3, func(e domain.Event) bool { return e.Source == "a" })
+	cancel()
+	assert.Equal(t, []uint64{5}, streamSeqs(replay))
+
+	// a subscriber that never reads is dropped instead of blocking Publish
+	_, events, cancel := stream.Subscribe(5, nil)
+	defer cancel()
+	for i := 0; i < 100; i++ {
+		stream.Publish(domain.Event{Type: domain.EventSyncStarted})
+	}
+	received := 0
+	for range events {
+		received++
+	}
+	assert.Less(t, received, 100)
+}
+
+type sseEvent struct {
+	id    string
+	event string
+	data  domain.Event
+}
+
+// readSSE reads events from an open stream until n arrived or the stream ended
+func readSSE(t *testing.T, resp *http.Response, n int) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for len(events) < n && scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if current.event != "" {
+				events = append(events, current)
+			}
+			current = sseEvent{}
+		case strings.HasPrefix(line, "id: "):
+			current.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			current.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &current.data))
+		}
+	}
+	return events
+}
+
+func openStream(t *testing.T, ctx context.Context, url string, headers map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	// the transport asks for gzip by itself, an event stream is still sent as is
+	require.False(t, resp.Uncompressed, "the event stream was gzipped")
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// Test a job stream replays its sync from the start to the end, and the full stream resumes from Last-Event-ID
+func TestE2EEventStream(t *testing.T) {
+	e := newE2E(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	jobId := uuid.New().String()
+	live := openStream(t, ctx, e.url+"/v1/events?source="+e.source, nil)
+
+	// a job is only known once its sync published an event
+	e.run([]e2eCase{
+		{name: "job not synced yet", method: http.MethodGet, path: "/v1/jobs/" + jobId + "/events", code: http.StatusNotFound},
+	})
+
+	resp, body := e.do(http.MethodPost, "/v1/summary/sync", e2eTarget, map[string]string{"X-Job-Id": jobId})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Contains(t, body, jobId)
+
+	// the job stream ends by itself after sync.succeeded
+	job := openStream(t, ctx, e.url+"/v1/jobs/"+jobId+"/events", nil)
+	events := readSSE(t, job, 100)
+	var types []string
+	for _, event := range events {
+		types = append(types, event.event)
+		assert.Equal(t, jobId, event.data.JobId)
+	}
+	assert.Equal(t, []string{"sync.started", "sync.fetched", "sync.storing", "sync.succeeded"}, types)
+	fetched, storing := events[1].data.Progress, events[2].data.Progress
+	require.NotNil(t, fetched)
+	require.NotNil(t, storing)
+	assert.Positive(t, fetched.TablesTotal)
+	assert.Equal(t, fetched.TablesTotal, storing.Tables)
+	assert.Equal(t, fetched.SchemasTotal, storing.Schemas)
+
+	liveEvents := readSSE(t, live, 4)
+	require.Len(t, liveEvents, 4)
+	assert.Equal(t, events[0].id, liveEvents[0].id)
+
+	// a client resuming after the second event gets the other two replayed
+	resumed := openStream(t, ctx, e.url+"/v1/events?source="+e.source, map[string]string{"Last-Event-ID": events[1].id})
+	replayed := readSSE(t, resumed, 2)
+	require.Len(t, replayed, 2)
+	assert.Equal(t, events[2].id, replayed[0].id)
+	assert.Equal(t, events[3].id, replayed[1].id)
+
+	e.run([]e2eCase{
+		{name: "invalid job id", method: http.MethodPost, path: "/v1/summary/sync", body: e2eTarget, headers: map[string]string{"X-Job-Id": "job-1"}, code: http.StatusBadRequest},
+		{name: "invalid last event id", method: http.MethodGet, path: "/v1/events?last_event_id=x", code: http.StatusBadRequest},
+	})
+}
+
+// Test a job stream ends with job.expired when the events of its job leave the buffer or no final event comes
+func TestJobStreamExpires(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	newServer := func(stream *service.EventStream, timeout time.Duration) string {
+		srv := httptest.NewServer(handler.NewServer(handler.Services{
+			Summary: service.NewSummaryService(new(MockExtRepo), local.NewMemoryRepository()),
+			Events:  stream, JobStreamTimeout: timeout,
+		}))
+		t.Cleanup(srv.Close)
+		return srv.URL
+	}
+
+	stream := service.NewEventStream(2)
+	url := newServer(stream, time.Minute)
+	stream.Publish(domain.Event{Type: domain.EventSyncStarted, JobId: "evicted"})
+	job := openStream(t, ctx, url+"/v1/jobs/evicted/events", nil)
+	require.Len(t, readSSE(t, job, 1), 1)
+
+	// two events of another job push it out of the buffer
+	stream.Publish(domain.Event{Type: domain.EventSyncStarted, JobId: "other"})
+	stream.Publish(domain.Event{Type: domain.EventSyncFetched, JobId: "other"})
+	events := readSSE(t, job, 100)
+	require.Len(t, events, 1)
+	assert.Equal(t, "job.expired", events[0].event)
+	assert.Empty(t, events[0].id, "job.expired is not buffered")
+	assert.Equal(t, "evicted", events[0].data.JobId)
+	assert.Contains(t, events[0].data.Error, "evicted")
+
+	_, ok := stream.Job("evicted")
+	assert.False(t, ok)
+	resp, err := http.Get(url + "/v1/jobs/evicted/events")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// the sync of this job never ends
+	stream = service.NewEventStream(10)
+	url = newServer(stream, 50*time.Millisecond)
+	stream.Publish(domain.Event{Type: domain.EventSyncStarted, JobId: "stuck"})
+	events = readSSE(t, openStream(t, ctx, url+"/v1/jobs/stuck/events", nil), 100)
+	require.Len(t, events, 2)
+	assert.Equal(t, "sync.started", events[0].event)
+	assert.Equal(t, "job.expired", events[1].event)
+	assert.Contains(t, events[1].data.Error, "no final event within 50ms")
+}
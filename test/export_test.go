@@ -0,0 +1,146 @@
+package test
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/handler"
+	"pg-summary-service/internal/repository/local"
+	"pg-summary-service/internal/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newExportServer serves s1 from conformanceSummary, users has statistics and the other tables none
+func newExportServer(t *testing.T) http.Handler {
+	repo := local.NewMemoryRepository()
+	summary := conformanceSummary("s1")
+	vacuumed := time.Date(2026, 10, 1, 2, 0, 0, 0, time.UTC)
+	summary.Schemas[0].Tables[0].TableStats = domain.TableStats{HeapSizeMB: ptr(1.25), DeadTuples: ptr[int64](10),
+		LastAutovacuum: &vacuumed, IdxScans: ptr[int64](40)}
+	_, err := repo.AddSummary(context.Background(), "a:db", summary)
+	require.NoError(t, err)
+	return handler.NewServer(handler.Services{Summary: service.NewSummaryService(new(MockExtRepo), repo)})
+}
+
+func getExport(t *testing.T, srv http.Handler, path string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+// Test the csv and markdown exports carry the table statistics, empty where they were not reported
+func TestSummaryTablesExportStats(t *testing.T) {
+	srv := newExportServer(t)
+
+	rec := getExport(t, srv, "/v1/summaries/s1/tables?format=csv", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, []string{"summary_id", "synced_at", "schema", "table", "row_count", "size_mb",
+		"heap_size_mb", "index_size_mb", "toast_size_mb", "dead_tuples", "last_vacuum", "last_autovacuum", "last_analyze",
+		"seq_scans", "idx_scans"}, records[0])
+	byTable := map[string][]string{}
+	for _, record := range records[1:] {
+		byTable[record[2]+"."+record[3]] = record[4:]
+	}
+	assert.Equal(t, []string{"100", "1.5", "1.25", "", "", "10", "", "2026-10-01T02:00:00Z", "", "", "40"}, byTable["public.users"])
+	assert.Equal(t, []string{"7", "0.25", "", "", "", "", "", "", "", "", ""}, byTable["sales.leads"])
+
+	rec = getExport(t, srv, "/v1/summaries/s1/tables?format=md", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 5)
+	assert.True(t, strings.HasSuffix(lines[0], "| seq_scans | idx_scans |"), lines[0])
+	assert.Contains(t, lines[2]+lines[3]+lines[4], "| users | 100 | 1.5 | 1.25 |  |  | 10 |  | 2026-10-01T02:00:00Z |  |  | 40 |")
+}
+
+// Test the Accept header is weighed by q, q=0 refuses a media type even when a wildcard would match it
+func TestExportFormatNegotiation(t *testing.T) {
+	srv := newExportServer(t)
+
+	tests := []struct {
+		name        string
+		accept      string
+		code        int
+		contentType string
+	}{
+		{"no accept", "", http.StatusOK, "application/json"},
+		{"first listed on a tie", "text/markdown, text/csv", http.StatusOK, "text/markdown; charset=utf-8"},
+		{"highest q", "text/csv;q=0.5, application/x-ndjson;q=0.9", http.StatusOK, "application/x-ndjson"},
+		{"refused", "text/csv;q=0", http.StatusNotAcceptable, ""},
+		{"refused before a wildcard", "text/csv;q=0, text/*", http.StatusNotAcceptable, ""},
+		{"refused after a wildcard", "*/*, application/json;q=0, text/csv;q=0.1", http.StatusOK, "text/csv; charset=utf-8"},
+		{"unsupported", "image/png", http.StatusNotAcceptable, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := getExport(t, srv, "/v1/summaries/s1/tables", map[string]string{"Accept": tt.accept})
+			assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+// Test the csv and markdown bodies of a summary and of a source export, table by table
+func TestExportBodies(t *testing.T) {
+	srv := newExportServer(t)
+
+	for _, path := range []string{"/v1/summaries/s1", "/v1/exports/summaries?source=a:db"} {
+		rec := getExport(t, srv, path, map[string]string{"Accept": "text/csv"})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 4, path)
+		var tables []string
+		for _, record := range records[1:] {
+			assert.Equal(t, "s1", record[0])
+			tables = append(tables, record[2]+"."+record[3]+" "+record[4]+" "+record[5])
+		}
+		assert.ElementsMatch(t, []string{"public.users 100 1.5", "public.orders 300 4.5", "sales.leads 7 0.25"}, tables, path)
+
+		rec = getExport(t, srv, path, map[string]string{"Accept": "text/markdown"})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "text/markdown; charset=utf-8", rec.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		require.Len(t, lines, 5, path)
+		assert.True(t, strings.HasPrefix(lines[0], "| summary_id | synced_at | schema | table | row_count | size_mb |"), lines[0])
+		assert.Regexp(t, `^(\|\s*-+\s*)+\|$`, lines[1])
+		assert.Contains(t, rec.Body.String(), "| sales | leads | 7 | 0.25 |")
+	}
+}
+
+// Test a source whose summaries have no tables exports an empty body, an unknown one is a 404
+func TestExportSourceWithoutTables(t *testing.T) {
+	repo := local.NewMemoryRepository()
+	_, err := repo.AddSummary(context.Background(), "c:db", &domain.ExternalSummaryResp{Id: "s1", Schemas: []domain.Schema{{Name: "empty"}}})
+	require.NoError(t, err)
+	srv := handler.NewServer(handler.Services{Summary: service.NewSummaryService(new(MockExtRepo), repo)})
+
+	rec := getExport(t, srv, "/v1/exports/summaries?source=c:db", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, "[]", rec.Body.String())
+
+	rec = getExport(t, srv, "/v1/exports/summaries?source=c:db&format=csv", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "summary_id,synced_at,"), "only the header")
+	assert.Equal(t, 1, strings.Count(rec.Body.String(), "\n"))
+
+	rec = getExport(t, srv, "/v1/exports/summaries?source=nope:db", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/repository/local"
+	"pg-summary-service/internal/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test the report finds unused and duplicate indexes and sums the index to heap ratio per schema
+func TestIndexReport(t *testing.T) {
+	ctx := context.Background()
+	summary := conformanceSummary("s1")
+	users, orders := &summary.Schemas[0].Tables[0], &summary.Schemas[0].Tables[1]
+	users.HeapSizeMB = ptr(1.0) // users has 1.5 in size_mb, only the heap counts
+	orders.HeapSizeMB = ptr(4.5)
+	users.Indexes = []domain.Index{
+		{Name: "users_id_key", Definition: "CREATE UNIQUE INDEX users_id_key ON public.users USING btree (id)", SizeMB: 1, Scans: ptr[int64](4), Unique: true},
+		{Name: "users_pkey", Definition: "CREATE UNIQUE INDEX users_pkey ON public.users USING btree (id)", SizeMB: 0.5, Scans: ptr[int64](90), Unique: true, Primary: true},
+		{Name: "users_email_idx", Definition: "CREATE INDEX users_email_idx ON public.users USING btree (email)", SizeMB: 0.25, Scans: ptr[int64](0)},
+		{Name: "users_email_idx1", Definition: "CREATE INDEX  users_email_idx1 ON public.users USING btree (email)", SizeMB: 0.5, Scans: ptr[int64](3)},
+		{Name: "users_email_key", Definition: "CREATE UNIQUE INDEX users_email_key ON public.users USING btree (email)", SizeMB: 0.5},
+	}
+	orders.Indexes = []domain.Index{
+		{Name: "orders_user_idx", Definition: "CREATE INDEX orders_user_idx ON public.orders USING btree (user_id)", SizeMB: 1, Scans: ptr[int64](0)},
+	}
+
+	repo := local.NewMemoryRepository()
+	_, err := repo.AddSummary(ctx, "a:db", summary)
+	require.NoError(t, err)
+	svc := service.NewSummaryService(new(MockExtRepo), repo)
+
+	report, err := svc.IndexReport(ctx, "s1")
+	require.NoError(t, err)
+
+	var unused []string
+	for _, index := range report.Unused {
+		unused = append(unused, index.Name)
+	}
+	assert.Equal(t, []string{"orders_user_idx", "users_email_idx"}, unused, "largest first, unknown scans are not unused")
+
+	// the unique index on email is not a duplicate of the plain ones
+	require.Len(t, report.Duplicates, 2)
+	dup := report.Duplicates[0]
+	assert.Equal(t, "CREATE INDEX ON public.users USING btree (email)", dup.Definition)
+	assert.Equal(t, "users_email_idx1", dup.Indexes[0].Name, "the largest is kept")
+	assert.Equal(t, 0.25, dup.WastedMB)
+	dup = report.Duplicates[1]
+	assert.Equal(t, "CREATE UNIQUE INDEX ON public.users USING btree (id)", dup.Definition)
+	assert.Equal(t, []string{"users_pkey", "users_id_key"}, []string{dup.Indexes[0].Name, dup.Indexes[1].Name}, "the primary key is kept, even when smaller")
+	assert.Equal(t, 1.0, dup.WastedMB)
+
+	require.Len(t, report.Schemas, 2)
+	public, sales := report.Schemas[0], report.Schemas[1]
+	assert.Equal(t, "public", public.Schema)
+	assert.Equal(t, 6, public.IndexCount)
+	assert.Equal(t, 3.75, public.IndexSizeMB)
+	require.NotNil(t, public.HeapSizeMB)
+	assert.Equal(t, 5.5, *public.HeapSizeMB)
+	require.NotNil(t, public.IndexToHeap)
+	assert.InDelta(t, 3.75/5.5, *public.IndexToHeap, 1e-9)
+	assert.Equal(t, 2, public.UnusedCount)
+	assert.Equal(t, 1.25, public.UnusedSizeMB)
+	assert.Equal(t, "sales", sales.Schema)
+	assert.Zero(t, sales.IndexCount)
+	assert.Nil(t, sales.HeapSizeMB, "leads has no heap size, its size_mb is not used in its place")
+	assert.Nil(t, sales.IndexToHeap)
+
+	_, err = svc.IndexReport(ctx, "missing")
+	assertAppError(t, err, http.StatusNotFound)
+}
@@ -0,0 +1,476 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/repository/local"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The conformance suite runs against every local.Local implementation.
+// Postgres is only covered when TEST_LOCAL_DB_URL points at a database the suite may truncate.
+
+func TestMemoryRepositoryConformance(t *testing.T) {
+	runLocalConformance(t, func(t *testing.T) local.Local {
+		return local.NewMemoryRepository()
+	})
+}
+
+func TestLocalRepositoryConformance(t *testing.T) {
+	dbUrl := os.Getenv("TEST_LOCAL_DB_URL")
+	if dbUrl == "" {
+		t.Skip("TEST_LOCAL_DB_URL not set")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dbUrl)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	require.NoError(t, local.CreateTables(ctx, pool))
+
+	runLocalConformance(t, func(t *testing.T) local.Local {
+		_, err := pool.Exec(ctx, `TRUNCATE columns, indexes, tables, schemas, summaries`)
+		require.NoError(t, err)
+		return local.NewLocalRepository(pool)
+	})
+}
+
+// Test a memory snapshot survives a save and load
+func TestMemoryRepositorySnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "summaries.json")
+
+	repo := local.NewMemoryRepository()
+	_, err := repo.AddSummary(ctx, "a:db", conformanceSummary("s1"))
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveSnapshot(path))
+
+	loaded, err := local.LoadMemorySnapshot(path)
+	require.NoError(t, err)
+
+	want, _ := repo.GetSummaryById(ctx, "s1")
+	got, err := loaded.GetSummaryById(ctx, "s1")
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	empty, err := local.LoadMemorySnapshot(filepath.Join(t.TempDir(), "missing.json"))
+	require.NoError(t, err)
+	items, _ := empty.GetSummary(ctx, 0, 10)
+	assert.Empty(t, items)
+}
+
+func conformanceSummary(id string) *domain.ExternalSummaryResp {
+	return &domain.ExternalSummaryResp{
+		Id: id,
+		Schemas: []domain.Schema{
+			{Name: "public", Tables: []domain.Table{
+				{Name: "users", TotalRows: 100, Size: 1.5},
+				{Name: "orders", TotalRows: 300, Size: 4.5},
+			}},
+			{Name: "sales", Tables: []domain.Table{
+				{Name: "leads", TotalRows: 7, Size: 0.25},
+			}},
+			{Name: "empty"},
+		},
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func assertAppError(t *testing.T, err error, code int) {
+	t.Helper()
+	var appErr *domain.AppError
+	if assert.True(t, errors.As(err, &appErr), "expected an AppError, got %v", err) {
+		assert.Equal(t, code, appErr.Code)
+	}
+}
+
+func runLocalConformance(t *testing.T, newRepo func(t *testing.T) local.Local) {
+	ctx := context.Background()
+
+	// seed adds summaries one by one so every one gets a distinct synced_at
+	seed := func(t *testing.T, repo local.Local, sources map[string]string, order ...string) {
+		for _, id := range order {
+			_, err := repo.AddSummary(ctx, sources[id], conformanceSummary(id))
+			require.NoError(t, err)
+			time.Sleep(2 * time.Millisecond)
+		}
+	}
+	sources := map[string]string{"s1": "a:db", "s2": "b:db", "s3": "a:db"}
+
+	t.Run("AddSummary validates input", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.AddSummary(ctx, "", conformanceSummary("s1"))
+		assertAppError(t, err, http.StatusBadRequest)
+		_, err = repo.AddSummary(ctx, "a:db", nil)
+		assertAppError(t, err, http.StatusBadRequest)
+	})
+
+	t.Run("AddSummary rejects duplicate ids", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, sources, "s1")
+		_, err := repo.AddSummary(ctx, "a:db", conformanceSummary("s1"))
+		assert.Error(t, err)
+	})
+
+	t.Run("AddSummary reports progress up to the totals", func(t *testing.T) {
+		repo := newRepo(t)
+		var reports []domain.SyncProgress
+		progressCtx := domain.WithProgress(ctx, func(p domain.SyncProgress) { reports = append(reports, p) })
+		summary := conformanceSummary("s1")
+		_, err := repo.AddSummary(progressCtx, "a:db", summary)
+		require.NoError(t, err)
+
+		require.NotEmpty(t, reports)
+		last := reports[len(reports)-1]
+		assert.Equal(t, summary.Totals().SchemasTotal, last.Schemas)
+		assert.Equal(t, summary.Totals().TablesTotal, last.Tables)
+	})
+
+	t.Run("GetSummaryById aggregates schemas", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, sources, "s1")
+
+		got, err := repo.GetSummaryById(ctx, "s1")
+		require.NoError(t, err)
+		assert.Equal(t, "s1", got.ID)
+		assert.Equal(t, "a:db", got.Source)
+		assert.WithinDuration(t, time.Now(), got.SyncedAt, time.Minute)
+
+		type agg struct {
+			Name   string
+			Tables int
+			Rows   int64
+			Size   float64
+		}
+		var schemas []agg
+		for _, s := range got.Schemas {
+			assert.NotEmpty(t, s.Id)
+			schemas = append(schemas, agg{s.Name, s.TableCount, s.TotalRows, s.TotalSizeMB})
+		}
+		assert.ElementsMatch(t, []agg{
+			{"public", 2, 400, 6},
+			{"sales", 1, 7, 0.25},
+			{"empty", 0, 0, 0},
+		}, schemas)
+	})
+
+	t.Run("GetSummaryById errors", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.GetSummaryById(ctx, "")
+		assertAppError(t, err, http.StatusBadRequest)
+		_, err = repo.GetSummaryById(ctx, "missing")
+		assertAppError(t, err, http.StatusNotFound)
+	})
+
+	t.Run("GetSummary orders newest first and paginates", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, sources, "s1", "s2", "s3")
+
+		all, err := repo.GetSummary(ctx, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"s3", "s2", "s1"}, listIds(all))
+
+		paged, err := repo.GetSummary(ctx, 1, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"s2"}, listIds(paged))
+
+		past, err := repo.GetSummary(ctx, 5, 10)
+		require.NoError(t, err)
+		assert.Empty(t, past)
+	})
+
+	t.Run("GetSourceSummaries and GetLatestSummaries", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, sources, "s1", "s2", "s3")
+
+		bySource, err := repo.GetSourceSummaries(ctx, "a:db", 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"s3", "s1"}, listIds(bySource))
+
+		_, err = repo.GetSourceSummaries(ctx, "", 0, 10)
+		assertAppError(t, err, http.StatusBadRequest)
+
+		latest, err := repo.GetLatestSummaries(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"s3", "s2"}, listIds(latest))
+	})
+
+	t.Run("GetLatestSnapshots joins the latest summaries with their tables", func(t *testing.T) {
+		repo := newRepo(t)
+		snapshots, err := repo.GetLatestSnapshots(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, snapshots)
+
+		seed(t, repo, sources, "s1", "s2", "s3")
+		_, err = repo.AddSummary(ctx, "c:db", &domain.ExternalSummaryResp{Id: "s4"})
+		require.NoError(t, err)
+
+		snapshots, err = repo.GetLatestSnapshots(ctx)
+		require.NoError(t, err)
+		require.Len(t, snapshots, 3)
+		for i, want := range []struct{ source, id string }{{"a:db", "s3"}, {"b:db", "s2"}, {"c:db", "s4"}} {
+			assert.Equal(t, want.source, snapshots[i].Source)
+			assert.Equal(t, want.id, snapshots[i].SummaryId)
+			assert.False(t, snapshots[i].SyncedAt.IsZero())
+		}
+
+		tables, err := repo.GetSummaryTables(ctx, "s3")
+		require.NoError(t, err)
+		assert.Equal(t, tables, snapshots[0].Tables)
+		assert.Empty(t, snapshots[2].Tables)
+	})
+
+	t.Run("GetSummaryTables orders by schema and table", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, sources, "s1")
+
+		tables, err := repo.GetSummaryTables(ctx, "s1")
+		require.NoError(t, err)
+		var names []string
+		for _, table := range tables {
+			assert.NotEmpty(t, table.Id)
+			assert.Equal(t, "s1", table.SummaryId)
+			names = append(names, table.Schema+"."+table.Name)
+		}
+		assert.Equal(t, []string{"public.orders", "public.users", "sales.leads"}, names)
+		assert.Equal(t, int64(300), tables[0].TotalRows)
+		assert.Equal(t, 4.5, tables[0].SizeMB)
+
+		_, err = repo.GetSummaryTables(ctx, "missing")
+		assertAppError(t, err, http.StatusNotFound)
+	})
+
+	t.Run("table statistics are stored and aggregated", func(t *testing.T) {
+		repo := newRepo(t)
+		vacuumed := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+		autovacuumed := vacuumed.Add(48 * time.Hour)
+		summary := conformanceSummary("s1")
+		users, orders := &summary.Schemas[0].Tables[0], &summary.Schemas[0].Tables[1]
+		users.TableStats = domain.TableStats{HeapSizeMB: ptr(1.0), IndexSizeMB: ptr(0.5), DeadTuples: ptr[int64](10),
+			LastVacuum: &vacuumed, LastAutovacuum: &autovacuumed, SeqScans: ptr[int64](3), IdxScans: ptr[int64](40)}
+		orders.TableStats = domain.TableStats{HeapSizeMB: ptr(4.0), DeadTuples: ptr[int64](5), LastVacuum: &vacuumed,
+			LastAnalyze: &autovacuumed}
+		leads := &summary.Schemas[1].Tables[0]
+		leads.TableStats = domain.TableStats{DeadTuples: ptr[int64](2)} // never vacuumed
+		_, err := repo.AddSummary(ctx, "a:db", summary)
+		require.NoError(t, err)
+
+		tables, err := repo.GetSummaryTables(ctx, "s1")
+		require.NoError(t, err)
+		assert.Equal(t, users.TableStats, tables[1].TableStats, "public.users")
+		assert.Equal(t, orders.TableStats, tables[0].TableStats, "public.orders")
+		assert.Equal(t, leads.TableStats, tables[2].TableStats, "sales.leads")
+
+		got, err := repo.GetSummaryById(ctx, "s1")
+		require.NoError(t, err)
+		stats := map[string]domain.SchemaStats{}
+		for _, schema := range got.Schemas {
+			stats[schema.Name] = schema.SchemaStats
+		}
+		// users was autovacuumed after its vacuum, orders only vacuumed, so orders is the oldest
+		assert.Equal(t, domain.SchemaStats{HeapSizeMB: ptr(5.0), IndexSizeMB: ptr(0.5), DeadTuples: ptr[int64](15),
+			SeqScans: ptr[int64](3), IdxScans: ptr[int64](40), OldestVacuum: &vacuumed, OldestAnalyze: &autovacuumed,
+			NeverVacuumed: ptr[int64](0)}, stats["public"])
+		// a never vacuumed table does not show in the oldest vacuum, it is counted instead
+		assert.Equal(t, domain.SchemaStats{DeadTuples: ptr[int64](2), NeverVacuumed: ptr[int64](1)}, stats["sales"])
+
+		// without any statistics there is nothing to count
+		repo = newRepo(t)
+		_, err = repo.AddSummary(ctx, "a:db", conformanceSummary("s1"))
+		require.NoError(t, err)
+		got, err = repo.GetSummaryById(ctx, "s1")
+		require.NoError(t, err)
+		for _, schema := range got.Schemas {
+			assert.Equal(t, domain.SchemaStats{}, schema.SchemaStats, schema.Name)
+		}
+	})
+
+	t.Run("GetTableColumns keeps the column order", func(t *testing.T) {
+		repo := newRepo(t)
+		def := "now()"
+		summary := conformanceSummary("s1")
+		summary.Schemas[0].Tables[0].Columns = []domain.Column{
+			{Name: "id", DataType: "bigint", NDistinct: ptr(-1.0), NullFrac: ptr(0.0), AvgWidth: ptr[int64](8)},
+			{Name: "email", DataType: "text", Nullable: true},
+			{Name: "created_at", DataType: "timestamp with time zone", Default: &def},
+		}
+		_, err := repo.AddSummary(ctx, "a:db", summary)
+		require.NoError(t, err)
+		seed(t, repo, sources, "s2")
+
+		tables, err := repo.GetSummaryTables(ctx, "s1")
+		require.NoError(t, err)
+		users, orders := tables[1], tables[0]
+		require.Equal(t, "users", users.Name)
+
+		columns, err := repo.GetTableColumns(ctx, "s1", users.Id)
+		require.NoError(t, err)
+		require.Len(t, columns, 3)
+		for i, column := range columns {
+			assert.NotEmpty(t, column.Id)
+			assert.Equal(t, users.Id, column.TableId)
+			assert.Equal(t, i+1, column.Position)
+			assert.Equal(t, summary.Schemas[0].Tables[0].Columns[i], column.Column)
+		}
+
+		columns, err = repo.GetTableColumns(ctx, "s1", orders.Id)
+		require.NoError(t, err)
+		assert.Empty(t, columns)
+		assert.NotNil(t, columns, "a table without columns gives an empty list")
+
+		_, err = repo.GetTableColumns(ctx, "s2", users.Id)
+		assertAppError(t, err, http.StatusNotFound)
+		_, err = repo.GetTableColumns(ctx, "s1", "missing")
+		assertAppError(t, err, http.StatusNotFound)
+		_, err = repo.GetTableColumns(ctx, "s1", "")
+		assertAppError(t, err, http.StatusBadRequest)
+
+		// the columns go with the summary
+		_, err = repo.DeleteSummary(ctx, "s1")
+		require.NoError(t, err)
+		_, err = repo.GetTableColumns(ctx, "s1", users.Id)
+		assertAppError(t, err, http.StatusNotFound)
+	})
+
+	t.Run("GetSummaryIndexes orders by schema, table and name", func(t *testing.T) {
+		repo := newRepo(t)
+		summary := conformanceSummary("s1")
+		summary.Schemas[0].Tables[0].Indexes = []domain.Index{
+			{Name: "users_pkey", Definition: "CREATE UNIQUE INDEX users_pkey ON public.users USING btree (id)", SizeMB: 0.5, Scans: ptr[int64](9), Unique: true, Primary: true},
+			{Name: "users_email_idx", Definition: "CREATE INDEX users_email_idx ON public.users USING btree (email)", SizeMB: 0.25},
+		}
+		summary.Schemas[1].Tables[0].Indexes = []domain.Index{
+			{Name: "leads_pkey", Definition: "CREATE UNIQUE INDEX leads_pkey ON sales.leads USING btree (id)", SizeMB: 0.1, Scans: ptr[int64](0), Unique: true, Primary: true},
+		}
+		_, err := repo.AddSummary(ctx, "a:db", summary)
+		require.NoError(t, err)
+		seed(t, repo, sources, "s2")
+
+		indexes, err := repo.GetSummaryIndexes(ctx, "s1")
+		require.NoError(t, err)
+		var names []string
+		for _, index := range indexes {
+			assert.NotEmpty(t, index.Id)
+			assert.NotEmpty(t, index.TableId)
+			names = append(names, index.Schema+"."+index.Table+"."+index.Name)
+		}
+		assert.Equal(t, []string{"public.users.users_email_idx", "public.users.users_pkey", "sales.leads.leads_pkey"}, names)
+		assert.Equal(t, summary.Schemas[0].Tables[0].Indexes[0], indexes[1].Index)
+		assert.Nil(t, indexes[0].Scans)
+
+		empty, err := repo.GetSummaryIndexes(ctx, "s2")
+		require.NoError(t, err)
+		assert.NotNil(t, empty)
+		assert.Empty(t, empty)
+
+		_, err = repo.GetSummaryIndexes(ctx, "missing")
+		assertAppError(t, err, http.StatusNotFound)
+		_, err = repo.DeleteSummary(ctx, "s1")
+		require.NoError(t, err)
+		_, err = repo.GetSummaryIndexes(ctx, "s1")
+		assertAppError(t, err, http.StatusNotFound)
+	})
+
+	t.Run("StreamSummaries and StreamSummaryTables match the list lookups", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, sources, "s1", "s2", "s3")
+
+		var streamed []domain.LocalSummaryListItem
+		err := repo.StreamSummaries(ctx, 1, 2, func(item domain.LocalSummaryListItem) error {
+			streamed = append(streamed, item)
+			return nil
+		})
+		require.NoError(t, err)
+		listed, _ := repo.GetSummary(ctx, 1, 2)
+		assert.Equal(t, listed, streamed)
+
+		var tables []domain.SummaryTable
+		err = repo.StreamSummaryTables(ctx, "s1", func(table domain.SummaryTable) error {
+			tables = append(tables, table)
+			return nil
+		})
+		require.NoError(t, err)
+		all, _ := repo.GetSummaryTables(ctx, "s1")
+		assert.Equal(t, all, tables)
+
+		err = repo.StreamSummaryTables(ctx, "missing", func(domain.SummaryTable) error { return nil })
+		assertAppError(t, err, http.StatusNotFound)
+	})
+
+	t.Run("StreamSourceTables streams newest summary first", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, sources, "s1", "s2", "s3")
+
+		var rows []string
+		err := repo.StreamSourceTables(ctx, "a:db", func(table domain.SummaryTable) error {
+			rows = append(rows, table.SummaryId+"/"+table.Schema+"."+table.Name)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"s3/public.orders", "s3/public.users", "s3/sales.leads",
+			"s1/public.orders", "s1/public.users", "s1/sales.leads",
+		}, rows)
+
+		err = repo.StreamSourceTables(ctx, "missing:db", func(domain.SummaryTable) error { return nil })
+		assertAppError(t, err, http.StatusNotFound)
+
+		stop := errors.New("stop")
+		err = repo.StreamSourceTables(ctx, "a:db", func(domain.SummaryTable) error { return stop })
+		assert.ErrorIs(t, err, stop)
+
+		// a source whose summaries have no tables exists, its export is empty
+		_, err = repo.AddSummary(ctx, "c:db", &domain.ExternalSummaryResp{Id: "s4", Schemas: []domain.Schema{{Name: "empty"}}})
+		require.NoError(t, err)
+		_, err = repo.AddSummary(ctx, "c:db", &domain.ExternalSummaryResp{Id: "s5", Schemas: []domain.Schema{}})
+		require.NoError(t, err)
+		called := false
+		err = repo.StreamSourceTables(ctx, "c:db", func(domain.SummaryTable) error {
+			called = true
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.False(t, called)
+	})
+
+	t.Run("DeleteSummary removes schemas and tables", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, sources, "s1", "s2")
+
+		deleted, err := repo.DeleteSummary(ctx, "s1")
+		require.NoError(t, err)
+		assert.Equal(t, "s1", deleted.ID)
+		assert.Equal(t, "a:db", deleted.DBName)
+
+		_, err = repo.GetSummaryById(ctx, "s1")
+		assertAppError(t, err, http.StatusNotFound)
+		_, err = repo.GetSummaryTables(ctx, "s1")
+		assertAppError(t, err, http.StatusNotFound)
+		_, err = repo.DeleteSummary(ctx, "s1")
+		assertAppError(t, err, http.StatusNotFound)
+
+		// the id can be stored again once deleted
+		_, err = repo.AddSummary(ctx, "a:db", conformanceSummary("s1"))
+		assert.NoError(t, err)
+	})
+}
+
+func listIds(items []domain.LocalSummaryListItem) []string {
+	var ids []string
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
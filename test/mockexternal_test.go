@@ -0,0 +1,88 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/repository/external"
+	"pg-summary-service/pkg/mockexternal"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var mockTarget = mockexternal.Request{Host: "db.local", Port: 5432, User: "app", Password: "secret", DBName: "shop"}
+
+// mockDetails are the details the service posts for mockTarget
+var mockDetails = domain.RemoteDBDetails(mockTarget)
+
+// asContract decodes a generated summary the way the service does, a field the service does not know fails the test
+func asContract(t *testing.T, summary *mockexternal.Summary) *domain.ExternalSummaryResp {
+	t.Helper()
+	body, err := json.Marshal(summary)
+	require.NoError(t, err)
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	var resp domain.ExternalSummaryResp
+	require.NoError(t, decoder.Decode(&resp))
+	return &resp
+}
+
+// Test summaries are deterministic per target and sync, valid, and keep their shape across syncs
+func TestMockExternalGenerate(t *testing.T) {
+	shape := mockexternal.Shape{MaxSchemas: 4, MaxTables: 20}
+	first := mockexternal.Generate(mockTarget, 0, shape)
+	assert.Equal(t, first, mockexternal.Generate(mockTarget, 0, shape))
+	assert.Empty(t, external.ValidateSummary(asContract(t, first)))
+	assert.Equal(t, external.VersionHeader, mockexternal.VersionHeader)
+
+	// the password is not part of the seed
+	other := mockTarget
+	other.Password = "rotated"
+	assert.Equal(t, first, mockexternal.Generate(other, 0, shape))
+
+	second := mockexternal.Generate(mockTarget, 1, shape)
+	assert.NotEqual(t, first.Id, second.Id)
+	require.Len(t, second.Schemas, len(first.Schemas))
+	for i, schema := range first.Schemas {
+		require.Len(t, second.Schemas[i].Tables, len(schema.Tables))
+		for j, table := range schema.Tables {
+			assert.Equal(t, table.Name, second.Schemas[i].Tables[j].Name)
+			assert.GreaterOrEqual(t, second.Schemas[i].Tables[j].TotalRows, table.TotalRows)
+		}
+	}
+
+	other.DBName = "billing"
+	assert.NotEqual(t, first.Schemas, mockexternal.Generate(other, 0, shape).Schemas)
+}
+
+// Test the repository fetches from the mock, retries its failures and gives up on forced client errors
+func TestMockExternalServer(t *testing.T) {
+	mock := mockexternal.New(mockexternal.Config{Shape: mockexternal.Shape{MaxSchemas: 2, MaxTables: 5}})
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	repo := external.NewExternalRepository(server.URL, 1, external.WithParser(external.NewParser(external.ParseStrict)))
+	resp, err := repo.FetchSummaries(context.Background(), mockDetails)
+	require.NoError(t, err)
+	assert.Equal(t, asContract(t, mockexternal.Generate(mockTarget, 0, mockexternal.Shape{MaxSchemas: 2, MaxTables: 5})), resp)
+
+	_, err = external.NewExternalRepository(server.URL+"?status=400", 3).FetchSummaries(context.Background(), mockDetails)
+	assertAppError(t, err, http.StatusBadRequest)
+	assert.Equal(t, mockexternal.Stats{Requests: 2, Summaries: 1, Failures: 1}, mock.Stats())
+
+	// every request fails, with a zero Retry-After the retries do not wait
+	failing := httptest.NewServer(mockexternal.New(mockexternal.Config{
+		ErrorRate: 1, ErrorStatuses: []int{http.StatusServiceUnavailable}, RetryAfter: 0,
+	}))
+	defer failing.Close()
+	_, err = external.NewExternalRepository(failing.URL+"?latency=1ms", 1).FetchSummaries(context.Background(), mockDetails)
+	assert.ErrorIs(t, err, domain.ErrExternalServiceUnreachable)
+
+	_, err = external.NewExternalRepository(server.URL, 1).FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db.local"})
+	assertAppError(t, err, http.StatusBadRequest)
+}
@@ -26,8 +26,8 @@ type MockExtRepo struct {
 	mock.Mock
 }
 
-func (m *MockExtRepo) FetchSummaries(details domain.RemoteDBDetails) (*domain.ExternalSummaryResp, error) {
-	args := m.Called(details)
+func (m *MockExtRepo) FetchSummaries(ctx context.Context, details domain.RemoteDBDetails) (*domain.ExternalSummaryResp, error) {
+	args := m.Called(ctx, details)
 	resp := args.Get(0)
 	if resp == nil {
 		return nil, args.Error(1)
@@ -67,6 +67,105 @@ func (m *MockLocalRepo) GetSummaryById(ctx context.Context, id string) (*domain.
 	return result.(*domain.LocalSummaryByIdResp), args.Error(1)
 }
 
+func (m *MockLocalRepo) GetSourceSummaries(ctx context.Context, src string, offset, limit int) ([]domain.LocalSummaryListItem, error) {
+	args := m.Called(ctx, src, offset, limit)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]domain.LocalSummaryListItem), args.Error(1)
+}
+
+func (m *MockLocalRepo) GetLatestSummaries(ctx context.Context) ([]domain.LocalSummaryListItem, error) {
+	args := m.Called(ctx)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]domain.LocalSummaryListItem), args.Error(1)
+}
+
+func (m *MockLocalRepo) GetLatestSnapshots(ctx context.Context) ([]domain.SourceSnapshot, error) {
+	args := m.Called(ctx)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]domain.SourceSnapshot), args.Error(1)
+}
+
+func (m *MockLocalRepo) DeleteSummary(ctx context.Context, id string) (*domain.LocalSummaryListItem, error) {
+	args := m.Called(ctx, id)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.LocalSummaryListItem), args.Error(1)
+}
+
+func (m *MockLocalRepo) GetSummaryTables(ctx context.Context, id string) ([]domain.SummaryTable, error) {
+	args := m.Called(ctx, id)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]domain.SummaryTable), args.Error(1)
+}
+
+func (m *MockLocalRepo) GetTableColumns(ctx context.Context, summaryId string, tableId string) ([]domain.TableColumn, error) {
+	args := m.Called(ctx, summaryId, tableId)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]domain.TableColumn), args.Error(1)
+}
+
+func (m *MockLocalRepo) GetSummaryIndexes(ctx context.Context, id string) ([]domain.TableIndex, error) {
+	args := m.Called(ctx, id)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]domain.TableIndex), args.Error(1)
+}
+
+func (m *MockLocalRepo) StreamSourceTables(ctx context.Context, src string, fn func(domain.SummaryTable) error) error {
+	args := m.Called(ctx, src)
+	if rows, ok := args.Get(0).([]domain.SummaryTable); ok {
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockLocalRepo) StreamSummaryTables(ctx context.Context, id string, fn func(domain.SummaryTable) error) error {
+	args := m.Called(ctx, id)
+	if rows, ok := args.Get(0).([]domain.SummaryTable); ok {
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockLocalRepo) StreamSummaries(ctx context.Context, offset, limit int, fn func(domain.LocalSummaryListItem) error) error {
+	args := m.Called(ctx, offset, limit)
+	if items, ok := args.Get(0).([]domain.LocalSummaryListItem); ok {
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
 // Test SyncSummary Success
 func TestSyncSummary(t *testing.T) {
 	mockExt := new(MockExtRepo)
@@ -90,7 +189,7 @@ func TestSyncSummary(t *testing.T) {
 		ID: "local1",
 	}
 
-	mockExt.On("FetchSummaries", details).Return(extResp, nil)
+	mockExt.On("FetchSummaries", mock.Anything, details).Return(extResp, nil)
 	mockLocal.On("AddSummary", mock.Anything, "test:db", extResp).Return(localResp, nil)
 
 	resAny, err := svc.SyncSummary(context.Background(), details)
@@ -131,7 +230,7 @@ func TestSyncSummaryExternalError(t *testing.T) {
 		DBName:   "db",
 	}
 
-	mockExt.On("FetchSummaries", details).Return(nil, errors.New("external service down"))
+	mockExt.On("FetchSummaries", mock.Anything, details).Return(nil, errors.New("external service down"))
 
 	res, err := svc.SyncSummary(context.Background(), details)
 	assert.Nil(t, res)
@@ -177,3 +276,43 @@ func TestGetSummaryByID(t *testing.T) {
 
 	mockLocal.AssertExpectations(t)
 }
+
+// Test GetSummaryTables
+func TestGetSummaryTables(t *testing.T) {
+	mockExt := new(MockExtRepo)
+	mockLocal := new(MockLocalRepo)
+	svc := service.NewSummaryService(mockExt, mockLocal)
+
+	expected := []domain.SummaryTable{
+		{Id: "t1", SummaryId: "local1", Schema: "public", Name: "users", TotalRows: 10, SizeMB: 1.5},
+	}
+
+	mockLocal.On("GetSummaryTables", mock.Anything, "local1").Return(expected, nil)
+
+	res, err := svc.GetSummaryTables(context.Background(), "local1")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, res)
+
+	mockLocal.AssertExpectations(t)
+}
+
+// Test ExportSourceTables stops when the writer fails
+func TestExportSourceTablesWriterError(t *testing.T) {
+	mockExt := new(MockExtRepo)
+	mockLocal := new(MockLocalRepo)
+	svc := service.NewSummaryService(mockExt, mockLocal)
+
+	rows := []domain.SummaryTable{
+		{Id: "t1", SummaryId: "s1", Schema: "public", Name: "users"},
+		{Id: "t2", SummaryId: "s1", Schema: "public", Name: "orders"},
+	}
+	mockLocal.On("StreamSourceTables", mock.Anything, "test:db").Return(rows, nil)
+
+	var seen []string
+	err := svc.ExportSourceTables(context.Background(), "test:db", func(table domain.SummaryTable) error {
+		seen = append(seen, table.Name)
+		return errors.New("client gone")
+	})
+	assert.EqualError(t, err, "client gone")
+	assert.Equal(t, []string{"users"}, seen)
+}
@@ -0,0 +1,128 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/repository/external"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubExternal answers every fetch with its id or its error
+type stubExternal struct {
+	id    string
+	err   error
+	calls int
+}
+
+func (s *stubExternal) FetchSummaries(ctx context.Context, details domain.RemoteDBDetails) (*domain.ExternalSummaryResp, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &domain.ExternalSummaryResp{Id: s.id}, nil
+}
+
+// Test targets go to the providers matching their host by priority, the catch-all ones otherwise
+func TestProviderRouterRouting(t *testing.T) {
+	eu := &stubExternal{id: "eu"}
+	euBackup := &stubExternal{id: "eu-backup"}
+	global := &stubExternal{id: "global"}
+	router := external.NewProviderRouter([]external.Provider{
+		{ExternalProvider: domain.ExternalProvider{Name: "global", Priority: 1}, Repo: global},
+		{ExternalProvider: domain.ExternalProvider{Name: "eu-backup", Priority: 2, Hosts: []string{"*.eu.example.com"}}, Repo: euBackup},
+		{ExternalProvider: domain.ExternalProvider{Name: "eu", Priority: 1, Region: "eu-west-1", Hosts: []string{"*.eu.example.com", "10.1.*"}}, Repo: eu},
+	})
+
+	resp, err := router.FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db1.EU.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "eu", resp.Id)
+
+	resp, err = router.FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "10.1.0.7"})
+	require.NoError(t, err)
+	assert.Equal(t, "eu", resp.Id)
+
+	resp, err = router.FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db.us.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "global", resp.Id)
+	assert.Equal(t, 0, euBackup.calls)
+
+	// no catch-all provider left for an unmatched host
+	router = external.NewProviderRouter([]external.Provider{
+		{ExternalProvider: domain.ExternalProvider{Name: "eu", Hosts: []string{"*.eu.example.com"}}, Repo: eu},
+	})
+	_, err = router.FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db.us.example.com"})
+	assertAppError(t, err, http.StatusBadRequest)
+}
+
+// Test an unreachable provider fails over to the next one, a client error does not
+func TestProviderRouterFailover(t *testing.T) {
+	down := &stubExternal{err: domain.ErrExternalServiceUnreachable}
+	open := &stubExternal{err: domain.NewServiceUnavailableError("circuit open")}
+	backup := &stubExternal{id: "backup"}
+	router := external.NewProviderRouter([]external.Provider{
+		{ExternalProvider: domain.ExternalProvider{Name: "primary", Priority: 1}, Repo: down},
+		{ExternalProvider: domain.ExternalProvider{Name: "secondary", Priority: 2}, Repo: open},
+		{ExternalProvider: domain.ExternalProvider{Name: "backup", Priority: 3}, Repo: backup},
+	})
+
+	resp, err := router.FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db"})
+	require.NoError(t, err)
+	assert.Equal(t, "backup", resp.Id)
+
+	stats := router.ProviderStats()
+	require.Len(t, stats, 3)
+	assert.Equal(t, "primary", stats[0].Name)
+	assert.Equal(t, uint64(1), stats[0].Failovers)
+	assert.Equal(t, 0.0, stats[0].SuccessRate)
+	assert.Equal(t, uint64(1), stats[2].Successes)
+	assert.Equal(t, 1.0, stats[2].SuccessRate)
+
+	// a bad request would fail the same way everywhere
+	down.err = domain.NewBadRequestError("bad credentials")
+	_, err = router.FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db"})
+	assertAppError(t, err, http.StatusBadRequest)
+	assert.Equal(t, 1, backup.calls)
+
+	// the last provider has nothing to fail over to
+	down.err = domain.ErrExternalServiceUnreachable
+	backup.err = domain.ErrExternalServiceUnreachable
+	_, err = router.FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db"})
+	assert.ErrorIs(t, err, domain.ErrExternalServiceUnreachable)
+	assert.Equal(t, uint64(1), router.ProviderStats()[2].Failures)
+}
+
+// Test the router over real repositories, a dead endpoint fails over and both breakers are reported
+func TestProviderRouterOverHTTP(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(validV1Payload))
+	}))
+	defer live.Close()
+
+	breaker := domain.BreakerConfig{FailureThreshold: 1, CoolDown: time.Minute}
+	router := external.NewProviderRouter([]external.Provider{
+		{ExternalProvider: domain.ExternalProvider{Name: "dead", Priority: 1}, Repo: external.NewExternalRepository(dead.URL, 1, external.WithBreaker(breaker))},
+		{ExternalProvider: domain.ExternalProvider{Name: "live", Priority: 2}, Repo: external.NewExternalRepository(live.URL, 1, external.WithBreaker(breaker))},
+	})
+
+	for i := 0; i < 2; i++ {
+		resp, err := router.FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db"})
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.Id)
+	}
+
+	states := router.BreakerStatus()
+	require.Len(t, states, 2)
+	assert.Equal(t, domain.BreakerOpen, states[0].State)
+	assert.Equal(t, domain.BreakerClosed, states[1].State)
+	// the second fetch was rejected by the open breaker before reaching the dead endpoint
+	assert.Equal(t, uint64(1), states[0].Rejected)
+	assert.Equal(t, uint64(2), router.ProviderStats()[0].Failovers)
+}
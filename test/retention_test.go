@@ -0,0 +1,170 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/repository/local"
+	"pg-summary-service/internal/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// agedRepository is a memory repository holding summaries synced ago before now, from a snapshot file since
+// AddSummary stamps the current time
+func agedRepository(t *testing.T, summaries map[string]struct {
+	source string
+	ago    time.Duration
+}) *local.MemoryRepository {
+	t.Helper()
+	type stored struct {
+		ID       string          `json:"id"`
+		Source   string          `json:"source"`
+		SyncedAt time.Time       `json:"synced_at"`
+		Schemas  []domain.Schema `json:"schemas"`
+	}
+	snapshot := struct {
+		Version   int      `json:"version"`
+		Summaries []stored `json:"summaries"`
+	}{Version: 1}
+	now := time.Now().UTC()
+	for id, s := range summaries {
+		snapshot.Summaries = append(snapshot.Summaries, stored{ID: id, Source: s.source, SyncedAt: now.Add(-s.ago)})
+	}
+	raw, err := json.Marshal(snapshot)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	require.NoError(t, os.WriteFile(path, raw, 0o600))
+	repo, err := local.LoadMemorySnapshot(path)
+	require.NoError(t, err)
+	return repo
+}
+
+// Test every retention rule through a janitor run, the newest summary of a source is kept even past MaxAge
+func TestJanitorRetentionRules(t *testing.T) {
+	day := 24 * time.Hour
+	summaries := map[string]struct {
+		source string
+		ago    time.Duration
+	}{
+		"a1": {"a:db", time.Hour},
+		"a2": {"a:db", 10*day - time.Hour},
+		"a3": {"a:db", 10 * day},
+		"a4": {"a:db", 40 * day},
+		"b1": {"b:db", 90 * day}, // only summary of its source
+	}
+
+	tests := []struct {
+		name   string
+		policy domain.RetentionPolicy
+		pruned map[string]string
+	}{
+		{
+			name:   "max age",
+			policy: domain.RetentionPolicy{MaxAge: 30 * day},
+			pruned: map[string]string{"a4": service.PruneReasonMaxAge},
+		},
+		{
+			name:   "keep last",
+			policy: domain.RetentionPolicy{KeepLast: 2},
+			pruned: map[string]string{"a3": service.PruneReasonKeepLast, "a4": service.PruneReasonKeepLast},
+		},
+		{
+			name:   "one per day",
+			policy: domain.RetentionPolicy{DailyAfter: 7 * day},
+			pruned: map[string]string{"a3": service.PruneReasonDaily},
+		},
+		{
+			name:   "no policy",
+			pruned: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := agedRepository(t, summaries)
+			svc := service.NewSummaryService(new(MockExtRepo), repo)
+			report, err := service.NewJanitor(svc, tt.policy).Run(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, len(summaries), report.Scanned)
+
+			got := map[string]string{}
+			for _, p := range report.Deleted {
+				got[p.Id] = p.Reason
+			}
+			assert.Equal(t, tt.pruned, got)
+
+			left, err := repo.GetSummary(context.Background(), 0, 10)
+			require.NoError(t, err)
+			assert.Len(t, left, len(summaries)-len(tt.pruned))
+		})
+	}
+}
+
+// Test the janitor deletes what the policy prunes and reports it
+func TestJanitorRun(t *testing.T) {
+	mockExt := new(MockExtRepo)
+	mockLocal := new(MockLocalRepo)
+	sink := &recordingSink{}
+	svc := service.NewSummaryService(mockExt, mockLocal, service.WithEventSinks(sink))
+
+	old := time.Now().Add(-48 * time.Hour)
+	summaries := []domain.LocalSummaryListItem{
+		{ID: "s2", DBName: "test:db", SyncedAt: time.Now()},
+		{ID: "s1", DBName: "test:db", SyncedAt: old},
+	}
+	mockLocal.On("GetLatestSummaries", mock.Anything).Return(summaries[:1], nil)
+	mockLocal.On("GetSourceSummaries", mock.Anything, "test:db", 0, 10).Return(summaries, nil)
+	mockLocal.On("DeleteSummary", mock.Anything, "s1").Return(&summaries[1], nil)
+
+	janitor := service.NewJanitor(svc, domain.RetentionPolicy{MaxAge: 24 * time.Hour, BatchSize: 10})
+	report, err := janitor.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Scanned)
+	assert.Equal(t, []service.PrunedSummary{
+		{Id: "s1", Source: "test:db", SyncedAt: old, Reason: service.PruneReasonMaxAge},
+	}, report.Deleted)
+	assert.Same(t, report, janitor.LastReport())
+
+	assert.Len(t, sink.events, 1)
+	assert.Equal(t, domain.EventSummaryDeleted, sink.events[0].Type)
+	assert.Equal(t, "s1", sink.events[0].SummaryId)
+	mockLocal.AssertExpectations(t)
+}
+
+// Test the janitor prunes a source page by page, the deleted summaries do not shift the next page
+func TestJanitorRunPages(t *testing.T) {
+	ctx := context.Background()
+	repo := local.NewMemoryRepository()
+	for i := range 7 {
+		src := "a:db"
+		if i == 6 {
+			src = "b:db"
+		}
+		_, err := repo.AddSummary(ctx, src, conformanceSummary(fmt.Sprintf("s%d", i)))
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond) // distinct synced_at
+	}
+	svc := service.NewSummaryService(new(MockExtRepo), repo)
+
+	report, err := service.NewJanitor(svc, domain.RetentionPolicy{KeepLast: 2, BatchSize: 2}).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 7, report.Scanned)
+	var deleted []string
+	for _, p := range report.Deleted {
+		deleted = append(deleted, p.Id)
+		assert.Equal(t, service.PruneReasonKeepLast, p.Reason)
+	}
+	assert.Equal(t, []string{"s3", "s2", "s1", "s0"}, deleted)
+
+	left, err := repo.GetSummary(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, left, 3)
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/utils"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptedServer answers with the given statuses in order and repeats the last one
+func scriptedServer(t *testing.T, calls *atomic.Int32, statuses []int, retryAfter string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		status := statuses[min(n, len(statuses))-1]
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Test which statuses are retried and what is returned once the tries run out
+func TestPostWithRetry(t *testing.T) {
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		name       string
+		statuses   []int
+		retryAfter string
+		retries    int
+		calls      int32
+		code       int // AppError code, 0 means success
+	}{
+		{name: "429 is retried after Retry-After", statuses: []int{429, 200}, retryAfter: "0", retries: 3, calls: 2},
+		{name: "503 is retried after an http date", statuses: []int{503, 200}, retryAfter: past, retries: 3, calls: 2},
+		{name: "other 4xx are not retried", statuses: []int{404}, retries: 3, calls: 1, code: http.StatusBadRequest},
+		{name: "rate limited until the end", statuses: []int{429}, retryAfter: "0", retries: 2, calls: 2, code: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := scriptedServer(t, &calls, tt.statuses, tt.retryAfter)
+
+			resp, err := utils.PostWithRetry(context.Background(), server.URL, tt.retries, map[string]string{})
+			assert.Equal(t, tt.calls, calls.Load())
+			if tt.code == 0 {
+				if assert.NoError(t, err) {
+					resp.Body.Close()
+				}
+				return
+			}
+			var appErr *domain.AppError
+			if assert.True(t, errors.As(err, &appErr), "expected an AppError, got %v", err) {
+				assert.Equal(t, tt.code, appErr.Code)
+			}
+		})
+	}
+}
+
+// Test a cancelled context stops the backoff sleep
+func TestPostWithRetryCancel(t *testing.T) {
+	var calls atomic.Int32
+	server := scriptedServer(t, &calls, []int{500}, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err := utils.PostWithRetry(ctx, server.URL, 10, map[string]string{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), time.Second)
+}
+
+func TestBackoffWithJitter(t *testing.T) {
+	for try := 0; try < 40; try++ {
+		sleep := utils.BackoffWithJitter(try)
+		assert.GreaterOrEqual(t, sleep, time.Duration(0))
+		assert.LessOrEqual(t, sleep, min(utils.BaseSleep<<min(try, 10), utils.MaxSleepTime))
+	}
+}
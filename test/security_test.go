@@ -0,0 +1,152 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/repository/external"
+	"pg-summary-service/internal/utils"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeClientCert writes a self-signed client certificate and its key to dir
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	return writeCert(t, dir, "client", &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pg-summary-service"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+}
+
+// writeCert self-signs template and writes it and its key to dir as <name>.crt and <name>.key
+func writeCert(t *testing.T, dir, name string, template *x509.Certificate) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	return certFile, keyFile, cert
+}
+
+// Test the external API is called over mTLS with the bearer token and a valid HMAC signature
+func TestExternalRepositorySecurity(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature := "sha256=" + utils.SignPayload("s3cret", r.Header.Get(external.TimestampHeader), body)
+		if r.Header.Get("Authorization") != "Bearer t0ken" || r.Header.Get(external.SignatureHeader) != signature {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(validV1Payload))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+
+	conf := domain.OutboundSecurity{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, BearerToken: "t0ken", HMACSecret: "s3cret", RequireHTTPS: true}
+	security, err := external.NewSecurity(conf)
+	require.NoError(t, err)
+	resp, err := external.NewExternalRepository(server.URL, 1, external.WithSecurity(security)).
+		FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db"})
+	require.NoError(t, err)
+	assert.Equal(t, "s1", resp.Id)
+
+	// a wrong secret is rejected by the API
+	conf.HMACSecret = "other"
+	security, err = external.NewSecurity(conf)
+	require.NoError(t, err)
+	_, err = external.NewExternalRepository(server.URL, 1, external.WithSecurity(security)).
+		FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db"})
+	assertAppError(t, err, http.StatusBadRequest)
+
+	// without the client certificate the handshake fails
+	security, err = external.NewSecurity(domain.OutboundSecurity{CAFile: caFile})
+	require.NoError(t, err)
+	_, err = external.NewExternalRepository(server.URL, 1, external.WithSecurity(security)).
+		FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db"})
+	assert.ErrorIs(t, err, domain.ErrExternalServiceUnreachable)
+
+	// nor is the server trusted without the CA bundle
+	_, err = external.NewExternalRepository(server.URL, 1).FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db"})
+	assert.ErrorIs(t, err, domain.ErrExternalServiceUnreachable)
+}
+
+// Test a plain http endpoint is refused before any credentials are sent
+func TestExternalRepositoryRequireHTTPS(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	security, err := external.NewSecurity(domain.OutboundSecurity{RequireHTTPS: true})
+	require.NoError(t, err)
+	_, err = external.NewExternalRepository(server.URL, 1, external.WithSecurity(security)).
+		FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db"})
+	assertAppError(t, err, http.StatusInternalServerError)
+	assert.False(t, called)
+
+	_, err = external.NewSecurity(domain.OutboundSecurity{CertFile: "client.crt"})
+	assert.Error(t, err)
+}
+
+// Test a redirect of the upstream is not followed, the signed body never reaches the Location
+func TestExternalRepositoryRefusesRedirects(t *testing.T) {
+	var leaked atomic.Int32
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked.Add(1)
+		_, _ = w.Write([]byte(validV1Payload))
+	}))
+	defer elsewhere.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, elsewhere.URL, http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	security, err := external.NewSecurity(domain.OutboundSecurity{HMACSecret: "s3cret"})
+	require.NoError(t, err)
+	for _, repo := range []*external.ExternalRepository{
+		external.NewExternalRepository(server.URL, 2, external.WithSecurity(security)),
+		external.NewExternalRepository(server.URL, 2),
+	} {
+		_, err = repo.FetchSummaries(context.Background(), domain.RemoteDBDetails{Host: "db", Password: "secret"})
+		assertAppError(t, err, http.StatusBadGateway)
+	}
+	assert.Zero(t, leaked.Load())
+}
@@ -0,0 +1,54 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/handler"
+	"pg-summary-service/internal/repository/local"
+	"pg-summary-service/internal/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test two servers in one process serve their own dependencies
+func TestServersAreIndependent(t *testing.T) {
+	newServer := func(id string) *handler.Server {
+		repo := local.NewMemoryRepository()
+		_, err := repo.AddSummary(context.Background(), "a:db", conformanceSummary(id))
+		assert.NoError(t, err)
+		return handler.NewServer(handler.Services{Summary: service.NewSummaryService(new(MockExtRepo), repo)})
+	}
+	first, second := newServer("s1"), newServer("s2")
+
+	get := func(srv http.Handler, path string) int {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, get(first, "/v1/summaries/s1"))
+	assert.Equal(t, http.StatusNotFound, get(first, "/v1/summaries/s2"))
+	assert.Equal(t, http.StatusOK, get(second, "/v1/summaries/s2"))
+	assert.Equal(t, http.StatusNotFound, get(second, "/v1/summaries/s1"))
+
+	// the unversioned paths are only served when Legacy is enabled
+	assert.Equal(t, http.StatusNotFound, get(first, "/summaries/s1"))
+}
+
+// Test the legacy aliases are deprecated without dates, and only announce the dates an operator configured
+func TestLegacyRoutesWithoutDates(t *testing.T) {
+	repo := local.NewMemoryRepository()
+	_, err := repo.AddSummary(context.Background(), "a:db", conformanceSummary("s1"))
+	assert.NoError(t, err)
+	srv := handler.NewServer(handler.Services{Summary: service.NewSummaryService(new(MockExtRepo), repo),
+		Legacy: domain.LegacyRoutes{Enabled: true}})
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/summaries/s1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+	assert.Empty(t, rec.Header().Get("Sunset"))
+	assert.Equal(t, `</v1/summaries/s1>; rel="successor-version"`, rec.Header().Get("Link"))
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/metrics"
+	"pg-summary-service/internal/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Test LatestSnapshots reads the latest summaries with their tables in one call, not one per summary
+func TestLatestSnapshots(t *testing.T) {
+	mockExt := new(MockExtRepo)
+	mockLocal := new(MockLocalRepo)
+	svc := service.NewSummaryService(mockExt, mockLocal)
+
+	syncedAt := time.Date(2025, 9, 14, 7, 31, 29, 0, time.UTC)
+	snapshots := []domain.SourceSnapshot{{Source: "test:db", SummaryId: "s1", SyncedAt: syncedAt,
+		Tables: []domain.SummaryTable{{Id: "t1", SummaryId: "s1", Schema: "public", Name: "users"}}}}
+	mockLocal.On("GetLatestSnapshots", mock.Anything).Return(snapshots, nil).Once()
+
+	res, err := svc.LatestSnapshots(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, snapshots, res)
+
+	mockLocal.AssertExpectations(t)
+	mockLocal.AssertNotCalled(t, "GetSummaryTables", mock.Anything, mock.Anything)
+}
+
+// Test WriteSummaryGauges keeps the largest tables when the per source limit is hit
+func TestWriteSummaryGaugesLimits(t *testing.T) {
+	snapshots := []domain.SourceSnapshot{
+		{
+			Source:   `test:"db"`,
+			SyncedAt: time.Unix(1757835089, 0),
+			Tables: []domain.SummaryTable{
+				{Schema: "public", Name: "small", TotalRows: 1, SizeMB: 0.5},
+				{Schema: "public", Name: "big", TotalRows: 1000, SizeMB: 120},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := metrics.WriteSummaryGauges(&buf, snapshots, metrics.Limits{MaxTablesPerSource: 1})
+	assert.NoError(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE pg_summary_table_rows gauge\n")
+	assert.Contains(t, out, `pg_summary_last_sync_timestamp{source="test:\"db\""} 1757835089`)
+	assert.Contains(t, out, `pg_summary_table_size_mb{source="test:\"db\"",schema="public",table="big"} 120`)
+	assert.NotContains(t, out, `table="small"`)
+	assert.Contains(t, out, `pg_summary_tables_dropped{source="test:\"db\""} 1`)
+}
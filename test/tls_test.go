@@ -0,0 +1,138 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/server"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeServerCert(t *testing.T, dir string, serial int64) *x509.Certificate {
+	_, _, cert := writeCert(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	return cert
+}
+
+// serveTLS serves 200s with tlsConf on a random port and returns its address
+func serveTLS(t *testing.T, tlsConf *tls.Config) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{TLSConfig: tlsConf, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go func() { _ = srv.ServeTLS(ln, "", "") }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return ln.Addr().String()
+}
+
+// handshake connects to addr and returns the serial of the certificate served
+func handshake(t *testing.T, addr string, clientConf *tls.Config) (int64, error) {
+	conn, err := tls.Dial("tcp", addr, clientConf)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if err = conn.Handshake(); err != nil {
+		return 0, err
+	}
+	// a rejected client certificate only shows up on the first read with TLS 1.3
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	if err == nil {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = conn.Read(make([]byte, 1))
+	}
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), err
+}
+
+// Test the served certificate is swapped on a file change, a broken pair keeps the old one
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	writeServerCert(t, dir, 1)
+	reloader, err := server.NewCertReloader(dir+"/server.crt", dir+"/server.key")
+	require.NoError(t, err)
+	tlsConf, err := server.NewTLSConfig(domain.ServerTLS{MinVersion: "1.2"}, reloader)
+	require.NoError(t, err)
+	addr := serveTLS(t, tlsConf)
+
+	client := &tls.Config{InsecureSkipVerify: true}
+	serial, err := handshake(t, addr, client)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), serial)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, 10*time.Millisecond)
+
+	writeServerCert(t, dir, 2)
+	assert.Eventually(t, func() bool {
+		serial, err := handshake(t, addr, client)
+		return err == nil && serial == 2
+	}, 2*time.Second, 20*time.Millisecond)
+
+	// a key that does not match fails to load, the current certificate stays
+	_, otherKey, _ := writeCert(t, t.TempDir(), "other", &x509.Certificate{SerialNumber: big.NewInt(3)})
+	key, err := os.ReadFile(otherKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(dir+"/server.key", key, 0o600))
+	assert.Error(t, reloader.Reload())
+	serial, err = handshake(t, addr, client)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), serial)
+}
+
+// Test client certificates are verified against the CA and the min version is enforced
+func TestServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCert := writeServerCert(t, dir, 1)
+	certFile, keyFile, _ := writeClientCert(t, dir)
+
+	reloader, err := server.NewCertReloader(dir+"/server.crt", dir+"/server.key")
+	require.NoError(t, err)
+	tlsConf, err := server.NewTLSConfig(domain.ServerTLS{ClientCAFile: certFile, ClientAuth: "require", MinVersion: "1.3"}, reloader)
+	require.NoError(t, err)
+	addr := serveTLS(t, tlsConf)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert)
+	clientCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+
+	_, err = handshake(t, addr, &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}})
+	assert.NoError(t, err)
+	_, err = handshake(t, addr, &tls.Config{RootCAs: roots})
+	assert.Error(t, err)
+	_, err = handshake(t, addr, &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}, MaxVersion: tls.VersionTLS12})
+	assert.Error(t, err)
+
+	_, err = server.NewTLSConfig(domain.ServerTLS{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, reloader)
+	assert.Error(t, err)
+	_, err = server.NewTLSConfig(domain.ServerTLS{CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}}, reloader)
+	assert.NoError(t, err)
+}
+
+// Test plain http requests are redirected to the same url over https
+func TestRedirectHandler(t *testing.T) {
+	for port, want := range map[string]string{
+		"8443": "https://pg.example.com:8443/summaries?limit=5",
+		"443":  "https://pg.example.com/summaries?limit=5",
+	} {
+		rec := httptest.NewRecorder()
+		server.RedirectHandler(port).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://pg.example.com:8080/summaries?limit=5", nil))
+		assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+		assert.Equal(t, want, rec.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,210 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"pg-summary-service/internal/domain"
+	"pg-summary-service/internal/service"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWebhookStore keeps webhooks and deliveries in memory
+type fakeWebhookStore struct {
+	mu         sync.Mutex
+	hooks      []domain.Webhook
+	deliveries []domain.WebhookDelivery
+}
+
+func (f *fakeWebhookStore) AddWebhook(ctx context.Context, hook *domain.Webhook) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hooks = append(f.hooks, *hook)
+	return nil
+}
+
+func (f *fakeWebhookStore) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, h := range f.hooks {
+		if h.Id == id {
+			return &h, nil
+		}
+	}
+	return nil, domain.NewNotFoundError("webhook not found")
+}
+
+func (f *fakeWebhookStore) ListWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]domain.Webhook(nil), f.hooks...), nil
+}
+
+func (f *fakeWebhookStore) UpdateWebhook(ctx context.Context, hook *domain.Webhook) error {
+	return nil
+}
+
+func (f *fakeWebhookStore) DeleteWebhook(ctx context.Context, id string) error {
+	return nil
+}
+
+func (f *fakeWebhookStore) AddDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deliveries = append(f.deliveries, *delivery)
+	return nil
+}
+
+func (f *fakeWebhookStore) ListDeliveries(ctx context.Context, webhookId string, offset int, limit int) ([]domain.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]domain.WebhookDelivery(nil), f.deliveries...), nil
+}
+
+// recordingSink collects published events
+type recordingSink struct {
+	events []domain.Event
+}
+
+func (r *recordingSink) Publish(event domain.Event) {
+	r.events = append(r.events, event)
+}
+
+// Test webhook deliveries are signed and retried until the receiver accepts them
+func TestWebhookDeliverySignedAndRetried(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		calls     int
+		signature string
+		timestamp string
+		body      []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		signature = r.Header.Get("X-Webhook-Signature")
+		timestamp = r.Header.Get("X-Webhook-Timestamp")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	store := &fakeWebhookStore{}
+	ws := service.NewWebhookService(store, 3, time.Millisecond)
+
+	hook, err := ws.CreateWebhook(context.Background(), domain.WebhookRequest{
+		URL:    srv.URL,
+		Secret: "s3cret",
+		Events: []domain.EventType{domain.EventSyncSucceeded},
+	})
+	assert.NoError(t, err)
+	assert.True(t, hook.Active)
+
+	ws.Publish(domain.Event{Id: "e1", Type: domain.EventSyncSucceeded, SummaryId: "s1", Source: "test:db"})
+	ws.Publish(domain.Event{Id: "e2", Type: domain.EventSyncFailed, Source: "test:db"}) // not subscribed
+	assert.Eventually(t, func() bool {
+		deliveries, _ := store.ListDeliveries(context.Background(), hook.Id, 0, 10)
+		return len(deliveries) == 2
+	}, time.Second, 5*time.Millisecond)
+	ws.Close()
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, "sha256="+service.SignWebhookPayload("s3cret", timestamp, body), signature)
+	assert.Len(t, store.deliveries, 2)
+	assert.False(t, store.deliveries[0].Success)
+	assert.Equal(t, http.StatusInternalServerError, store.deliveries[0].StatusCode)
+	assert.True(t, store.deliveries[1].Success)
+	assert.Equal(t, 2, store.deliveries[1].Attempt)
+}
+
+// Test Publish racing Close never adds to a finished wait, and events published after Close are dropped
+func TestWebhookPublishAfterClose(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	store := &fakeWebhookStore{}
+	ws := service.NewWebhookService(store, 1, time.Millisecond)
+	_, err := ws.CreateWebhook(context.Background(), domain.WebhookRequest{URL: srv.URL, Events: []domain.EventType{domain.EventSyncSucceeded}})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ws.Publish(domain.Event{Id: fmt.Sprint(i), Type: domain.EventSyncSucceeded})
+		}()
+	}
+	ws.Close()
+	wg.Wait()
+	delivered := calls.Load()
+
+	ws.Publish(domain.Event{Id: "late", Type: domain.EventSyncSucceeded})
+	ws.Close()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, delivered, calls.Load())
+}
+
+// Test CreateWebhook rejects unknown event types
+func TestCreateWebhookInvalidEvent(t *testing.T) {
+	ws := service.NewWebhookService(&fakeWebhookStore{}, 1, time.Millisecond)
+
+	_, err := ws.CreateWebhook(context.Background(), domain.WebhookRequest{
+		URL:    "http://localhost:9000/hook",
+		Events: []domain.EventType{"sync.exploded"},
+	})
+	assert.EqualError(t, err, `unknown event type "sync.exploded"`)
+}
+
+// Test SyncSummary emits sync.succeeded and sync.failed
+func TestSyncSummaryPublishesEvents(t *testing.T) {
+	mockExt := new(MockExtRepo)
+	mockLocal := new(MockLocalRepo)
+	sink := &recordingSink{}
+	svc := service.NewSummaryService(mockExt, mockLocal, service.WithEventSinks(sink))
+
+	ok := domain.RemoteDBDetails{Host: "test", Port: 5432, User: "user", Password: "pass", DBName: "db"}
+	down := domain.RemoteDBDetails{Host: "down", Port: 5432, User: "user", Password: "pass", DBName: "db"}
+	extResp := &domain.ExternalSummaryResp{Id: "summary1"}
+
+	mockExt.On("FetchSummaries", mock.Anything, ok).Return(extResp, nil)
+	mockExt.On("FetchSummaries", mock.Anything, down).Return(nil, errors.New("external service down"))
+	mockLocal.On("AddSummary", mock.Anything, "test:db", extResp).Return(nil, nil)
+
+	_, err := svc.SyncSummary(context.Background(), ok)
+	assert.NoError(t, err)
+	_, err = svc.SyncSummary(context.Background(), down)
+	assert.Error(t, err)
+
+	var types []domain.EventType
+	for _, event := range sink.events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []domain.EventType{
+		domain.EventSyncStarted, domain.EventSyncFetched, domain.EventSyncSucceeded,
+		domain.EventSyncStarted, domain.EventSyncFailed,
+	}, types)
+	assert.Equal(t, "summary1", sink.events[2].SummaryId)
+	assert.Equal(t, "test:db", sink.events[2].Source)
+	assert.NotEmpty(t, sink.events[2].JobId)
+	assert.Equal(t, sink.events[0].JobId, sink.events[2].JobId, "events of one sync share the job id")
+	assert.NotEqual(t, sink.events[2].JobId, sink.events[4].JobId)
+	assert.Equal(t, "external service down", sink.events[4].Error)
+}